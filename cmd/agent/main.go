@@ -6,6 +6,14 @@ import (
 	"log"
 
 	"github.com/zetcan333/metrics-collector/internal/agent"
+	"github.com/zetcan333/metrics-collector/internal/agent/cgroup"
+	"github.com/zetcan333/metrics-collector/internal/agent/config"
+	"github.com/zetcan333/metrics-collector/internal/agent/execplugin"
+	"github.com/zetcan333/metrics-collector/internal/agent/logtail"
+	"github.com/zetcan333/metrics-collector/internal/agent/probe"
+	"github.com/zetcan333/metrics-collector/internal/agent/procstat"
+	"github.com/zetcan333/metrics-collector/internal/agent/runtimemetrics"
+	"github.com/zetcan333/metrics-collector/internal/agent/scrape"
 	"github.com/zetcan333/metrics-collector/internal/flags"
 )
 
@@ -21,6 +29,65 @@ func main() {
 	log.Println("Agent started...")
 	agent := agent.NewAgent(a.ServerURL, a.PollInterval, a.ReportInterval)
 
+	if a.IngestHTTP != "" {
+		agent.AddRunner(func(ctx context.Context) {
+			if err := agent.RunHTTPIngest(ctx, a.IngestHTTP); err != nil {
+				log.Fatalln("failed to start local HTTP ingestion:", err)
+			}
+		})
+	}
+	if a.IngestUDP != "" {
+		agent.AddRunner(func(ctx context.Context) {
+			if err := agent.RunUDPIngest(ctx, a.IngestUDP); err != nil {
+				log.Fatalln("failed to start local UDP ingestion:", err)
+			}
+		})
+	}
+
+	if a.ConfigFile != "" {
+		cfg, err := config.Load(a.ConfigFile)
+		if err != nil {
+			log.Fatalln("failed to load agent config:", err)
+		}
+		for _, e := range cfg.Exec {
+			agent.AddRunner(execplugin.New(e, agent).Run)
+		}
+		log.Println("Exec plugins:", len(cfg.Exec))
+		if len(cfg.Procstat) > 0 {
+			agent.AddCollector(procstat.New("/proc", cfg.Procstat))
+			log.Println("Process groups:", len(cfg.Procstat))
+		}
+		if cfg.Cgroup != nil {
+			agent.AddCollector(cgroup.New(cgroup.DefaultRoot, cgroup.DefaultSelfCgroup, cfg.Cgroup.Paths))
+			log.Println("Cgroup metrics enabled")
+		}
+		if cfg.Runtime != nil {
+			agent.AddCollector(runtimemetrics.New(cfg.Runtime.Prefixes))
+			if cfg.Runtime.MemStats != nil {
+				agent.MemStats = *cfg.Runtime.MemStats
+			}
+			log.Println("Runtime metrics enabled, MemStats:", agent.MemStats)
+		}
+		if len(cfg.Scrape) > 0 {
+			agent.AddCollector(scrape.New(cfg.Scrape))
+			log.Println("Scrape targets:", len(cfg.Scrape))
+		}
+		for _, p := range cfg.Probes {
+			agent.AddRunner(probe.New(p, agent).Run)
+		}
+		if len(cfg.Probes) > 0 {
+			log.Println("Probes:", len(cfg.Probes))
+		}
+		if cfg.LogTail != nil {
+			tail, err := logtail.New(cfg.LogTail)
+			if err != nil {
+				log.Fatalln("failed to init log tailing:", err)
+			}
+			agent.AddCollector(tail)
+			log.Println("Log files:", len(cfg.LogTail.Files))
+		}
+	}
+
 	agent.Start(ctx)
 
 	log.Println("Agent stoped")
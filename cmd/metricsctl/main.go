@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/zetcan333/metrics-collector/internal/ctl"
+	"github.com/zetcan333/metrics-collector/internal/flags"
+)
+
+func main() {
+	f := flags.NewCtlFlags()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client := ctl.NewClient(f.ServerURL, f.Key, f.Tenant, f.Token, f.AdminToken, f.Gzip)
+	cli := ctl.NewCLI(client, f.Output, os.Stdout, os.Stdin)
+
+	if err := cli.Run(ctx, f.Args); err != nil {
+		fmt.Fprintln(os.Stderr, "metricsctl:", err)
+		if errors.Is(err, ctl.ErrUsage) {
+			ctl.Usage(os.Stderr)
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
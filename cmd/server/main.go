@@ -2,16 +2,35 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
 
 	"github.com/zetcan333/metrics-collector/internal/flags"
 	"github.com/zetcan333/metrics-collector/internal/handlers"
+	"github.com/zetcan333/metrics-collector/internal/handlers/influx"
+	mwTenant "github.com/zetcan333/metrics-collector/internal/handlers/middleware/tenant"
+	"github.com/zetcan333/metrics-collector/internal/handlers/otlp"
 	"github.com/zetcan333/metrics-collector/internal/handlers/ping"
+	"github.com/zetcan333/metrics-collector/internal/handlers/promrw"
+	"github.com/zetcan333/metrics-collector/internal/ingest/graphite"
+	otlpIngest "github.com/zetcan333/metrics-collector/internal/ingest/otlp"
+	promrwIngest "github.com/zetcan333/metrics-collector/internal/ingest/promrw"
+	"github.com/zetcan333/metrics-collector/internal/ingest/statsd"
+	"github.com/zetcan333/metrics-collector/internal/models"
 	strg "github.com/zetcan333/metrics-collector/internal/repo/storage"
 	"github.com/zetcan333/metrics-collector/internal/repo/storage/mem"
 	"github.com/zetcan333/metrics-collector/internal/repo/storage/postgres"
 	"github.com/zetcan333/metrics-collector/internal/server"
 	"github.com/zetcan333/metrics-collector/internal/usecase"
+	"github.com/zetcan333/metrics-collector/internal/usecase/alerting"
 	"github.com/zetcan333/metrics-collector/internal/usecase/backup"
+	"github.com/zetcan333/metrics-collector/internal/usecase/expiry"
+	"github.com/zetcan333/metrics-collector/internal/usecase/relay"
+	"github.com/zetcan333/metrics-collector/internal/usecase/stream"
+	"github.com/zetcan333/metrics-collector/internal/usecase/transfer"
 	"go.uber.org/zap"
 )
 
@@ -52,16 +71,168 @@ func main() {
 		bkp = backup.NewBackupUsecase(storage)
 	}
 
-	serverUsecase := usecase.NewSeverUsecase(storage)
+	var tenantTokens map[string]string
+	if serverFlags.TenantsFile != "" {
+		tenantTokens, err = mwTenant.LoadTokens(serverFlags.TenantsFile)
+		if err != nil {
+			log.Sugar().Fatalln("failed to load tenant tokens:", err)
+		}
+		log.Sugar().Infoln("Tenant tokens loaded:", len(tenantTokens))
+		if serverFlags.AllowAnonymous {
+			log.Sugar().Warnln("anonymous access is allowed: requests without a token are served as the default tenant")
+		}
+	} else {
+		log.Sugar().Warnln("tenants file is not set: tenant is taken from X-Tenant-ID or ?tenant= without authentication, any client can read and write any tenant; use -tenants-file for multi-tenant deployments")
+	}
+	if serverFlags.AdminToken == "" {
+		log.Sugar().Infoln("admin token is not set: /admin/export and /admin/import are disabled")
+	}
+
+	var exp *expiry.ExpiryUsecase
+	if serverFlags.MetricsTTL > 0 {
+		exp = expiry.NewExpiryUsecase(storage, serverFlags.MetricsTTL)
+		log.Sugar().Infoln("Metrics TTL:", serverFlags.MetricsTTL)
+	}
+
+	if err := models.ValidateBounds(serverFlags.HistBuckets); err != nil {
+		log.Sugar().Fatalln("invalid histogram buckets:", err)
+	}
+
+	hub := stream.NewHub(stream.DefaultMaxPending)
+	observers := []usecase.UpdateObserver{hub}
+
+	relayCtx, stopRelay := context.WithCancel(ctx)
+	relayDone := make(chan struct{})
+	if len(serverFlags.RelayUpstreams) > 0 {
+		if serverFlags.RelayBatchSize <= 0 || serverFlags.RelayQueueSize <= 0 || serverFlags.RelayInterval <= 0 {
+			log.Sugar().Fatalln("relay batch size, queue size and interval must be positive")
+		}
+		var relayOpts []relay.Option
+		if serverFlags.RelayTokensFile != "" {
+			relayTokens, err := mwTenant.LoadTokens(serverFlags.RelayTokensFile)
+			if err != nil {
+				log.Sugar().Fatalln("failed to load relay tokens:", err)
+			}
+			relayOpts = append(relayOpts, relay.WithTenantTokens(relayTokens))
+		} else {
+			log.Sugar().Warnln("relay tokens file is not set: tenant is forwarded only as X-Tenant-ID, upstreams with -tenants-file will reject or ignore it")
+		}
+		r := relay.NewRelay(log, serverFlags.RelayUpstreams, serverFlags.RelayKey, serverFlags.RelayDC,
+			serverFlags.RelayQueueSize, serverFlags.RelayBatchSize, serverFlags.RelayInterval, relayOpts...)
+		observers = append(observers, r)
+		go func() {
+			r.Run(relayCtx)
+			close(relayDone)
+		}()
+		log.Sugar().Infoln("Relaying metrics to:", serverFlags.RelayUpstreams)
+	} else {
+		close(relayDone)
+	}
+
+	serverUsecase := usecase.NewSeverUsecase(storage,
+		usecase.WithHistogramBuckets(serverFlags.HistBuckets),
+		usecase.WithUpdateObservers(observers...),
+	)
 	handlers := handlers.NewServerHandler(log, serverUsecase)
-	server := server.NewServer(log, handlers, pingHandler, serverFlags, bkp)
+	var alerts *alerting.AlertingUsecase
+	if serverFlags.AlertRules != "" {
+		rules, err := alerting.LoadRules(serverFlags.AlertRules)
+		if err != nil {
+			log.Sugar().Fatalln("failed to load alerting rules:", err)
+		}
 
-	server.Start(ctx)
+		var notifier alerting.Notifier
+		if len(serverFlags.AlertWebhooks) > 0 {
+			webhooks := alerting.NewWebhookNotifier(log, serverFlags.AlertWebhooks)
+			go webhooks.Run(ctx)
+			notifier = webhooks
+		}
+		alerts = alerting.NewAlertingUsecase(storage, notifier, rules)
+		log.Sugar().Infoln("Alerting rules loaded:", len(rules))
+	}
+
+	// Приёмники сторонних протоколов пишут через usecase, поэтому останавливаются раньше ретранслятора.
+	// Ошибка запуска приёмника останавливает сервер штатно, с итоговой копией и закрытием хранилища.
+	runCtx, stopRun := context.WithCancel(ctx)
+	ingestCtx, stopIngest := context.WithCancel(ctx)
+	var ingest sync.WaitGroup
+	ingestErrs := make(chan error, 2)
+	if serverFlags.StatsdUDP != "" || serverFlags.StatsdTCP != "" {
+		if serverFlags.StatsdFlush <= 0 {
+			log.Sugar().Fatalln("statsd flush interval must be positive")
+		}
+		statsdServer := statsd.NewServer(log, statsd.NewAggregator(serverUsecase),
+			serverFlags.StatsdUDP, serverFlags.StatsdTCP, serverFlags.StatsdFlush)
+		ingest.Add(1)
+		go func() {
+			defer ingest.Done()
+			if err := statsdServer.Run(ingestCtx); err != nil {
+				ingestErrs <- fmt.Errorf("failed to start statsd listener: %w", err)
+				stopRun()
+			}
+		}()
+	}
+
+	if serverFlags.GraphiteAddr != "" {
+		var templates []graphite.Template
+		if serverFlags.GraphiteTemplates != "" {
+			templates, err = graphite.LoadTemplates(serverFlags.GraphiteTemplates)
+			if err != nil {
+				log.Sugar().Fatalln("failed to load graphite templates:", err)
+			}
+		}
+		converter, err := graphite.NewConverter(templates, serverFlags.GraphiteCounters)
+		if err != nil {
+			log.Sugar().Fatalln("invalid graphite counter rules:", err)
+		}
+		graphiteServer := graphite.NewServer(log, serverUsecase, converter, serverFlags.GraphiteAddr)
+		ingest.Add(1)
+		go func() {
+			defer ingest.Done()
+			if err := graphiteServer.Run(ingestCtx); err != nil {
+				ingestErrs <- fmt.Errorf("failed to start graphite listener: %w", err)
+				stopRun()
+			}
+		}()
+	}
+
+	remoteWrite, err := promrwIngest.NewConverter(serverFlags.PromCounters)
+	if err != nil {
+		log.Sugar().Fatalln("invalid remote write counter rules:", err)
+	}
+
+	server := server.NewServer(log, handlers, pingHandler, serverFlags, bkp, tenantTokens, exp, alerts, hub, transfer.NewTransferUsecase(serverUsecase),
+		server.WithRoute(http.MethodPost, "/write", influx.New(log, serverUsecase, serverFlags.InfluxCounters).Write),
+		server.WithRoute(http.MethodPost, "/v1/metrics", otlp.New(log, serverUsecase, otlpIngest.NewConverter(serverFlags.OTLPPrefixAttr)).Metrics),
+		server.WithRoute(http.MethodPost, "/api/v1/write", promrw.New(log, serverUsecase, remoteWrite).Write),
+	)
+
+	// Последний сброс StatsD и Graphite должен попасть в итоговую резервную копию
+	runErr := server.Start(runCtx, func() {
+		stopIngest()
+		ingest.Wait()
+	})
+	stopRun()
+	close(ingestErrs)
+	errs := []error{runErr}
+	for err := range ingestErrs {
+		errs = append(errs, err)
+	}
+
+	// Досылаем буфер ретранслятора до закрытия хранилища
+	stopRelay()
+	<-relayDone
 
 	if s, ok := storage.(*postgres.PgStorage); ok {
 		log.Sugar().Infoln("closing postgres pool")
 		s.Close()
 	}
+
+	if err := errors.Join(errs...); err != nil {
+		log.Sugar().Errorln(err)
+		log.Sync()
+		os.Exit(1)
+	}
 }
 
 func fallInMemory(log *zap.Logger) {
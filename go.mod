@@ -5,10 +5,13 @@ go 1.22.0
 require github.com/stretchr/testify v1.10.0
 
 require (
+	github.com/golang/snappy v1.0.0
 	github.com/jackc/pgerrcode v0.0.0-20240316143900-6e2875d9b438
 	github.com/jackc/pgx/v5 v5.7.4
 	github.com/spf13/pflag v1.0.6
+	go.opentelemetry.io/proto/otlp v1.3.1
 	go.uber.org/zap v1.27.0
+	google.golang.org/protobuf v1.34.1
 )
 
 require (
@@ -2,73 +2,82 @@ package agent
 
 import (
 	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"math/rand/v2"
-	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
 	"reflect"
 	"runtime"
-	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/zetcan333/metrics-collector/internal/lib/retry"
 	"github.com/zetcan333/metrics-collector/internal/models"
 )
 
-var (
-	delays      = []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second}
-	maxAttempts = 3
-)
+const gcPauseMetric = "GCPauseNs"
+
+// Границы корзин пауз GC в наносекундах: от 10µs до 100ms
+var gcPauseBounds = []float64{1e4, 2.5e4, 5e4, 1e5, 2.5e5, 5e5, 1e6, 2.5e6, 5e6, 1e7, 2.5e7, 5e7, 1e8}
 
 // Структура агента
 type Agent struct {
 	ServerURL      string
 	PollInterval   time.Duration
 	ReportInterval time.Duration
+	MemStats       bool // собирать runtime.MemStats; ReadMemStats останавливает мир на время чтения
 	Metrics        map[string]models.Metrics
 	PollCount      int64
+	lastNumGC      uint32
 	client         http.Client
+	ingested       map[string]bool // метрики из Ingest; Delta их счётчиков — приращение, вычитаемое после отправки
+	runners        []func(ctx context.Context)
+	collectors     []Collector
 	sync.RWMutex
 }
 
+// Collector — дополнительный источник метрик, опрашиваемый вместе с runtime на каждом PollInterval.
+// Метрики передаются в Ingest: counter возвращает приращение с прошлого опроса.
+type Collector interface {
+	Collect(ctx context.Context) ([]models.Metrics, error)
+}
+
+// MetricsSnapshot — снимок runtime.MemStats; теги unit и help попадают в метаданные метрик
 type MetricsSnapshot struct {
-	Alloc         float64
-	BuckHashSys   float64
-	Frees         float64
-	GCCPUFraction float64
-	GCSys         float64
-	HeapAlloc     float64
-	HeapIdle      float64
-	HeapInuse     float64
-	HeapObjects   float64
-	HeapReleased  float64
-	HeapSys       float64
-	LastGC        float64
-	Lookups       float64
-	MCacheInuse   float64
-	MCacheSys     float64
-	MSpanInuse    float64
-	MSpanSys      float64
-	Mallocs       float64
-	NextGC        float64
-	NumForcedGC   float64
-	NumGC         float64
-	OtherSys      float64
-	PauseTotalNs  float64
-	StackInuse    float64
-	StackSys      float64
-	Sys           float64
-	TotalAlloc    float64
-	RandomValue   float64
-	PollCount     int64
+	Alloc         float64 `unit:"bytes" help:"Bytes of allocated heap objects"`
+	BuckHashSys   float64 `unit:"bytes" help:"Bytes of memory in profiling bucket hash tables"`
+	Frees         float64 `unit:"count" help:"Cumulative count of heap objects freed"`
+	GCCPUFraction float64 `unit:"ratio" help:"Fraction of CPU time used by the GC since program start"`
+	GCSys         float64 `unit:"bytes" help:"Bytes of memory in garbage collection metadata"`
+	HeapAlloc     float64 `unit:"bytes" help:"Bytes of allocated heap objects"`
+	HeapIdle      float64 `unit:"bytes" help:"Bytes in idle (unused) heap spans"`
+	HeapInuse     float64 `unit:"bytes" help:"Bytes in in-use heap spans"`
+	HeapObjects   float64 `unit:"count" help:"Number of allocated heap objects"`
+	HeapReleased  float64 `unit:"bytes" help:"Bytes of physical memory returned to the OS"`
+	HeapSys       float64 `unit:"bytes" help:"Bytes of heap memory obtained from the OS"`
+	LastGC        float64 `unit:"ns" help:"Time the last GC finished, nanoseconds since the Unix epoch"`
+	Lookups       float64 `unit:"count" help:"Number of pointer lookups performed by the runtime"`
+	MCacheInuse   float64 `unit:"bytes" help:"Bytes of allocated mcache structures"`
+	MCacheSys     float64 `unit:"bytes" help:"Bytes of memory obtained from the OS for mcache structures"`
+	MSpanInuse    float64 `unit:"bytes" help:"Bytes of allocated mspan structures"`
+	MSpanSys      float64 `unit:"bytes" help:"Bytes of memory obtained from the OS for mspan structures"`
+	Mallocs       float64 `unit:"count" help:"Cumulative count of heap objects allocated"`
+	NextGC        float64 `unit:"bytes" help:"Target heap size of the next GC cycle"`
+	NumForcedGC   float64 `unit:"count" help:"Number of GC cycles forced by the application"`
+	NumGC         float64 `unit:"count" help:"Number of completed GC cycles"`
+	OtherSys      float64 `unit:"bytes" help:"Bytes of memory in miscellaneous off-heap runtime allocations"`
+	PauseTotalNs  float64 `unit:"ns" help:"Cumulative GC stop-the-world pause time"`
+	StackInuse    float64 `unit:"bytes" help:"Bytes in stack spans"`
+	StackSys      float64 `unit:"bytes" help:"Bytes of stack memory obtained from the OS"`
+	Sys           float64 `unit:"bytes" help:"Total bytes of memory obtained from the OS"`
+	TotalAlloc    float64 `unit:"bytes" help:"Cumulative bytes allocated for heap objects"`
+	RandomValue   float64 `unit:"ratio" help:"Random value in [0, 1)" source:"agent"`
+	PollCount     int64   `unit:"count" help:"Number of metric polls performed by the agent" source:"agent"`
 }
 
 // Конструктор агента
@@ -77,18 +86,34 @@ func NewAgent(serverURL string, pollInterval, reportInterval time.Duration) *Age
 		ServerURL:      serverURL,
 		PollInterval:   pollInterval,
 		ReportInterval: reportInterval,
+		MemStats:       true,
 		Metrics:        make(map[string]models.Metrics),
+		ingested:       make(map[string]bool),
 		client: http.Client{
 			Timeout: 15 * time.Second,
 		},
 	}
 }
 
+// AddCollector регистрирует источник метрик, см. Collector
+func (a *Agent) AddCollector(c Collector) {
+	a.collectors = append(a.collectors, c)
+}
+
+// AddRunner регистрирует фоновую задачу, которая запускается в Start и работает до отмены контекста
+func (a *Agent) AddRunner(run func(ctx context.Context)) {
+	a.runners = append(a.runners, run)
+}
+
 func (a *Agent) Start(ctx context.Context) {
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	for _, run := range a.runners {
+		go run(ctx)
+	}
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
@@ -99,6 +124,7 @@ func (a *Agent) Start(ctx context.Context) {
 			select {
 			case <-ticker.C:
 				a.CollectMetrics()
+				a.runCollectors(ctx)
 			case <-ctx.Done():
 				fmt.Println("Metrics collection stopped")
 				return
@@ -149,7 +175,7 @@ func (a *Agent) SendMetrics() error {
 			return fmt.Errorf("failed to encode metric %v", err)
 		}
 
-		compressedBody, err := compressData(body)
+		compressedBody, err := retry.Compress(body)
 		if err != nil {
 			return fmt.Errorf("failed to compress data: %v", err)
 		}
@@ -178,10 +204,8 @@ func (a *Agent) SendMetrics() error {
 
 // ACTUAL FOR CURRENT API
 func (a *Agent) SendMetricsBatch() error {
-	a.RLock()
-	defer a.RUnlock()
-
-	if len(a.Metrics) == 0 {
+	metrics := a.snapshotMetrics()
+	if len(metrics) == 0 {
 		return nil
 	}
 
@@ -192,43 +216,97 @@ func (a *Agent) SendMetricsBatch() error {
 
 	updateURL := baseURL.JoinPath("updates/")
 
-	metrics := make([]models.Metrics, 0, len(a.Metrics))
-	for _, metric := range a.Metrics {
-		metrics = append(metrics, metric)
-	}
-
 	body, err := json.Marshal(metrics)
 	if err != nil {
 		return fmt.Errorf("failed to encode metrics: %v", err)
 	}
 
-	compressedBody, err := compressData(body)
-	if err != nil {
-		return fmt.Errorf("failed to compress data: %v", err)
+	poster := retry.Poster{Client: &a.client, Gzip: true}
+	if _, err := poster.Post(context.Background(), updateURL.String(), body, nil); err != nil {
+		return err
 	}
 
-	req, err := http.NewRequest("POST", updateURL.String(), bytes.NewBuffer(compressedBody))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+	a.commitSent(metrics)
+	return nil
+}
+
+func (a *Agent) snapshotMetrics() []models.Metrics {
+	a.RLock()
+	defer a.RUnlock()
+
+	metrics := make([]models.Metrics, 0, len(a.Metrics))
+	for _, metric := range a.Metrics {
+		metrics = append(metrics, metric)
 	}
+	return metrics
+}
 
-	req.Header.Set("Content-Encoding", "gzip")
-	req.Header.Set("Content-Type", "application/json")
+// commitSent вычитает отправленные наблюдения гистограмм и приращения принятых через Ingest
+// счётчиков: сервер их уже учёл. Значения в a.Metrics не изменяются на месте, поэтому снимок остаётся корректным.
+func (a *Agent) commitSent(sent []models.Metrics) {
+	a.Lock()
+	defer a.Unlock()
 
-	return retry(maxAttempts, delays, func() error {
+	for _, metric := range sent {
+		if metric.MType == models.Counter && a.ingested[metric.ID] {
+			a.commitCounter(metric)
+			continue
+		}
+		if metric.MType != models.Histogram || metric.Histogram == nil {
+			continue
+		}
+		current, ok := a.Metrics[metric.ID]
+		if !ok || current.Histogram == nil {
+			continue
+		}
 
-		resp, err := a.client.Do(req)
-		if err != nil {
-			return fmt.Errorf("failed to send request: %v", err)
+		rest := &models.HistogramData{
+			Bounds: current.Histogram.Bounds,
+			Counts: make([]uint64, len(current.Histogram.Counts)),
+			Sum:    current.Histogram.Sum - metric.Histogram.Sum,
+			Count:  current.Histogram.Count - metric.Histogram.Count,
+		}
+		for i := range rest.Counts {
+			rest.Counts[i] = current.Histogram.Counts[i] - metric.Histogram.Counts[i]
 		}
-		resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("server returned status %d", resp.StatusCode)
+		if rest.Count == 0 {
+			delete(a.Metrics, metric.ID)
+			continue
 		}
+		current.Histogram = rest
+		a.Metrics[metric.ID] = current
+	}
+}
 
-		return nil
-	})
+func (a *Agent) commitCounter(sent models.Metrics) {
+	current, ok := a.Metrics[sent.ID]
+	if !ok || current.Delta == nil || sent.Delta == nil {
+		return
+	}
+	rest := *current.Delta - *sent.Delta
+	if rest == 0 {
+		delete(a.Metrics, sent.ID)
+		delete(a.ingested, sent.ID)
+		return
+	}
+	current.Delta = &rest
+	a.Metrics[sent.ID] = current
+}
+
+func (a *Agent) runCollectors(ctx context.Context) {
+	for _, c := range a.collectors {
+		metrics, err := c.Collect(ctx)
+		if err != nil {
+			fmt.Printf("Error collecting metrics: %v\n", err)
+		}
+		if len(metrics) == 0 {
+			continue
+		}
+		if err := a.Ingest(metrics); err != nil {
+			fmt.Printf("Error collecting metrics: %v\n", err)
+		}
+	}
 }
 
 // Сбор метрик из runtime
@@ -236,8 +314,15 @@ func (a *Agent) CollectMetrics() {
 	a.Lock()
 	defer a.Unlock()
 	a.PollCount++
+
+	var rtm runtime.MemStats
+	if a.MemStats {
+		runtime.ReadMemStats(&rtm)
+		a.collectGCPauses(&rtm)
+	}
+
 	snapshot := MetricsSnapshot{}
-	snapshot.collectFlat(a.PollCount)
+	snapshot.collectFlat(&rtm, a.PollCount)
 
 	v := reflect.ValueOf(snapshot)
 	t := v.Type()
@@ -245,24 +330,73 @@ func (a *Agent) CollectMetrics() {
 	for i := range v.NumField() {
 		field := v.Field(i)
 		name := t.Field(i).Name
+		meta := snapshotMeta(t.Field(i).Tag)
+		// Без MemStats остаются только собственные метрики агента
+		if !a.MemStats && meta.Source != "agent" {
+			continue
+		}
 
 		if name == "PollCount" {
 			delta := field.Int()
-			a.Metrics[name] = models.Metrics{ID: name, MType: models.Counter, Delta: &delta}
+			a.Metrics[name] = models.Metrics{ID: name, MType: models.Counter, Delta: &delta, Meta: meta}
 			continue
 		}
 
 		if field.Kind() == reflect.Float64 {
 			value := field.Float()
-			a.Metrics[name] = models.Metrics{ID: name, MType: models.Gauge, Value: &value}
+			a.Metrics[name] = models.Metrics{ID: name, MType: models.Gauge, Value: &value, Meta: meta}
 		}
 	}
 }
 
-func (m *MetricsSnapshot) collectFlat(pollCount int64) {
-	var rtm runtime.MemStats
-	runtime.ReadMemStats(&rtm)
+// snapshotMeta собирает метаданные из тегов поля MetricsSnapshot
+func snapshotMeta(tag reflect.StructTag) *models.MetricMeta {
+	source := tag.Get("source")
+	if source == "" {
+		source = "runtime.MemStats"
+	}
+	return &models.MetricMeta{
+		Unit:   tag.Get("unit"),
+		Help:   tag.Get("help"),
+		Source: source,
+	}
+}
 
+// collectGCPauses добавляет паузы GC, случившиеся с прошлого опроса, в гистограмму GCPauseNs.
+// Гистограмма копится до успешной отправки, см. commitSent.
+func (a *Agent) collectGCPauses(rtm *runtime.MemStats) {
+	if rtm.NumGC == a.lastNumGC {
+		return
+	}
+
+	// PauseNs — кольцевой буфер последних 256 пауз
+	first := a.lastNumGC
+	if rtm.NumGC-first > uint32(len(rtm.PauseNs)) {
+		first = rtm.NumGC - uint32(len(rtm.PauseNs))
+	}
+	pauses := make([]float64, 0, rtm.NumGC-first)
+	for n := first + 1; n <= rtm.NumGC; n++ {
+		pauses = append(pauses, float64(rtm.PauseNs[(n+255)%256]))
+	}
+	a.lastNumGC = rtm.NumGC
+
+	merged, err := models.MergeHistograms(a.Metrics[gcPauseMetric].Histogram, models.HistogramData{
+		Bounds:       gcPauseBounds,
+		Observations: pauses,
+	})
+	if err != nil {
+		fmt.Printf("Error collecting GC pauses: %v\n", err)
+		return
+	}
+	a.Metrics[gcPauseMetric] = models.Metrics{
+		ID:        gcPauseMetric,
+		MType:     models.Histogram,
+		Histogram: merged,
+		Meta:      &models.MetricMeta{Unit: "ns", Help: "Distribution of GC stop-the-world pause durations", Source: "runtime.MemStats"},
+	}
+}
+
+func (m *MetricsSnapshot) collectFlat(rtm *runtime.MemStats, pollCount int64) {
 	m.Alloc = float64(rtm.Alloc)
 	m.BuckHashSys = float64(rtm.BuckHashSys)
 	m.Frees = float64(rtm.Frees)
@@ -294,48 +428,3 @@ func (m *MetricsSnapshot) collectFlat(pollCount int64) {
 	m.RandomValue = rand.Float64()
 	m.PollCount = pollCount
 }
-
-func compressData(data []byte) ([]byte, error) {
-	var buf bytes.Buffer
-	gz := gzip.NewWriter(&buf)
-
-	if _, err := gz.Write(data); err != nil {
-		return nil, err
-	}
-	if err := gz.Close(); err != nil {
-		return nil, err
-	}
-
-	return buf.Bytes(), nil
-}
-
-func retry(maxAttempts int, delays []time.Duration, fn func() error) error {
-
-	for attempt := 0; attempt < maxAttempts; attempt++ {
-		err := fn()
-
-		switch {
-		case err == nil:
-			return nil
-		case !isRetriableError(err):
-			return err
-		}
-		fmt.Printf("retrying to send, attempt: %d\n", attempt+1)
-		<-time.After(delays[attempt])
-	}
-	return fmt.Errorf("max attempts reached")
-}
-
-func isRetriableError(err error) bool {
-	var netErr net.Error
-
-	if errors.As(err, &netErr) && netErr.Timeout() {
-		return true
-	}
-
-	if strings.Contains(err.Error(), "dial tcp") {
-		return true
-	}
-
-	return false
-}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -35,6 +37,22 @@ func TestCollectMetrics(t *testing.T) {
 	assert.Equal(t, prevPollCount+1, *a.Metrics["PollCount"].Delta, "PollCount должен увеличиваться")
 }
 
+func TestCollectMetricsMeta(t *testing.T) {
+	a := agent.NewAgent("http://localhost:8080", 2*time.Second, 10*time.Second)
+	a.CollectMetrics()
+
+	// Каждое поле MetricsSnapshot должно нести единицу измерения и описание
+	for name, metric := range a.Metrics {
+		require.NotNil(t, metric.Meta, "У метрики %s должны быть метаданные", name)
+		assert.NotEmpty(t, metric.Meta.Unit, "У метрики %s должна быть единица измерения", name)
+		assert.NotEmpty(t, metric.Meta.Help, "У метрики %s должно быть описание", name)
+	}
+
+	assert.Equal(t, "ns", a.Metrics["PauseTotalNs"].Meta.Unit)
+	assert.Equal(t, "bytes", a.Metrics["HeapAlloc"].Meta.Unit)
+	assert.Equal(t, "runtime.MemStats", a.Metrics["HeapAlloc"].Meta.Source)
+}
+
 func TestSendMetrics(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Contains(t, r.URL.Path, "/update", "Неправильный URL")
@@ -92,7 +110,7 @@ func TestSendMetricsBatch(t *testing.T) {
 		require.NoError(t, err, "Ошибка декодирования JSON")
 
 		require.Len(t, receivedMetrics, 2, "Должно быть 2 метрики")
-		assert.Equal(t, expectedMetrics, receivedMetrics, "Метрики не совпадают")
+		assert.ElementsMatch(t, expectedMetrics, receivedMetrics, "Метрики не совпадают")
 
 		w.WriteHeader(http.StatusOK)
 	}))
@@ -107,3 +125,74 @@ func TestSendMetricsBatch(t *testing.T) {
 	err := a.SendMetricsBatch()
 	assert.NoError(t, err, "Не должно быть ошибки")
 }
+
+func TestCollectGCPauses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	a := agent.NewAgent(server.URL, time.Minute, time.Minute)
+	runtime.GC()
+	a.CollectMetrics()
+
+	pauses, ok := a.Metrics["GCPauseNs"]
+	require.True(t, ok, "Должна быть гистограмма пауз GC")
+	assert.Equal(t, models.Histogram, pauses.MType)
+	require.NotNil(t, pauses.Histogram)
+	assert.GreaterOrEqual(t, pauses.Histogram.Count, uint64(1), "Должна быть хотя бы одна пауза")
+
+	// После успешной отправки отправленные наблюдения не отправляются повторно
+	require.NoError(t, a.SendMetricsBatch())
+	assert.NotContains(t, a.Metrics, "GCPauseNs")
+}
+
+func TestIngestForwardsDeltasOnce(t *testing.T) {
+	var received []models.Metrics
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gzReader, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		var metrics []models.Metrics
+		require.NoError(t, json.NewDecoder(gzReader).Decode(&metrics))
+		received = metrics
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	a := agent.NewAgent(server.URL, time.Minute, time.Minute)
+	a.CollectMetrics()
+	ingest := httptest.NewServer(a.IngestHandler())
+	defer ingest.Close()
+
+	post := func(path, body string) int {
+		resp, err := http.Post(ingest.URL+path, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, post("/updates/", `[{"id":"orders","type":"counter","delta":2},{"id":"orders","type":"counter","delta":3},{"id":"queue","type":"gauge","value":7}]`))
+	assert.Equal(t, http.StatusBadRequest, post("/update/", `{"id":"HeapAlloc","type":"gauge","value":1}`), "Метрики агента не перезаписываются")
+	assert.Equal(t, http.StatusBadRequest, post("/update/", `{"id":"queue","type":"counter","delta":1}`))
+
+	byID := func() map[string]models.Metrics {
+		m := make(map[string]models.Metrics)
+		for _, metric := range received {
+			m[metric.ID] = metric
+		}
+		return m
+	}
+
+	require.NoError(t, a.SendMetricsBatch())
+	assert.Equal(t, int64(5), *byID()["orders"].Delta)
+	assert.Equal(t, 7.0, *byID()["queue"].Value)
+
+	// Отправленные приращения не повторяются, новые уходят в следующем цикле
+	assert.Equal(t, http.StatusOK, post("/update/", `{"id":"orders","type":"counter","delta":4}`))
+	require.NoError(t, a.SendMetricsBatch())
+	assert.Equal(t, int64(4), *byID()["orders"].Delta)
+
+	require.NoError(t, a.SendMetricsBatch())
+	assert.NotContains(t, byID(), "orders")
+	assert.Contains(t, byID(), "queue")
+}
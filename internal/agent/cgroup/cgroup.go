@@ -0,0 +1,246 @@
+package cgroup
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/zetcan333/metrics-collector/internal/ingest/cumulative"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+const (
+	DefaultRoot       = "/sys/fs/cgroup"
+	DefaultSelfCgroup = "/proc/self/cgroup"
+)
+
+const source = "cgroup"
+
+// Имена метрик; у всех есть метка cgroup с путём относительно корня cgroupfs
+const (
+	MetricMemory         = "cgroup_memory_bytes"
+	MetricMemoryLimit    = "cgroup_memory_limit_bytes"
+	MetricCPUUsage       = "cgroup_cpu_usage_usec_total"
+	MetricCPUUser        = "cgroup_cpu_user_usec_total"
+	MetricCPUSystem      = "cgroup_cpu_system_usec_total"
+	MetricCPUThrottled   = "cgroup_cpu_throttled_usec_total"
+	MetricCPUThrottledNr = "cgroup_cpu_throttled_periods_total"
+	MetricIORead         = "cgroup_io_read_bytes_total"
+	MetricIOWrite        = "cgroup_io_write_bytes_total"
+	MetricIOReads        = "cgroup_io_reads_total"
+	MetricIOWrites       = "cgroup_io_writes_total"
+	MetricPids           = "cgroup_pids"
+	MetricPidsLimit      = "cgroup_pids_limit"
+)
+
+var meta = map[string]*models.MetricMeta{
+	MetricMemory:         {Unit: "bytes", Help: "Memory used by the cgroup (memory.current)", Source: source},
+	MetricMemoryLimit:    {Unit: "bytes", Help: "Memory limit of the cgroup (memory.max)", Source: source},
+	MetricCPUUsage:       {Unit: "us", Help: "Total CPU time (cpu.stat usage_usec)", Source: source},
+	MetricCPUUser:        {Unit: "us", Help: "User CPU time (cpu.stat user_usec)", Source: source},
+	MetricCPUSystem:      {Unit: "us", Help: "System CPU time (cpu.stat system_usec)", Source: source},
+	MetricCPUThrottled:   {Unit: "us", Help: "Time throttled by the CPU limit (cpu.stat throttled_usec)", Source: source},
+	MetricCPUThrottledNr: {Unit: "count", Help: "Periods throttled by the CPU limit (cpu.stat nr_throttled)", Source: source},
+	MetricIORead:         {Unit: "bytes", Help: "Bytes read per device (io.stat rbytes)", Source: source},
+	MetricIOWrite:        {Unit: "bytes", Help: "Bytes written per device (io.stat wbytes)", Source: source},
+	MetricIOReads:        {Unit: "count", Help: "Read operations per device (io.stat rios)", Source: source},
+	MetricIOWrites:       {Unit: "count", Help: "Write operations per device (io.stat wios)", Source: source},
+	MetricPids:           {Unit: "count", Help: "Number of processes (pids.current)", Source: source},
+	MetricPidsLimit:      {Unit: "count", Help: "Process limit (pids.max)", Source: source},
+}
+
+var (
+	cpuStatMetrics = map[string]string{
+		"usage_usec":     MetricCPUUsage,
+		"user_usec":      MetricCPUUser,
+		"system_usec":    MetricCPUSystem,
+		"throttled_usec": MetricCPUThrottled,
+		"nr_throttled":   MetricCPUThrottledNr,
+	}
+	ioStatMetrics = map[string]string{
+		"rbytes": MetricIORead,
+		"wbytes": MetricIOWrite,
+		"rios":   MetricIOReads,
+		"wios":   MetricIOWrites,
+	}
+)
+
+// Collector читает файлы cgroup v2. Значения из cpu.stat и io.stat накопительные
+// и передаются как приращения counter; файлы выключенных контроллеров пропускаются.
+type Collector struct {
+	root       string
+	selfCgroup string
+	paths      []string
+	counters   *cumulative.Counters
+}
+
+// New создаёт сборщик. root — точка монтирования cgroupfs, selfCgroup — файл /proc/self/cgroup,
+// по которому определяется своя cgroup при пустом paths.
+func New(root, selfCgroup string, paths []string) *Collector {
+	return &Collector{root: root, selfCgroup: selfCgroup, paths: paths, counters: cumulative.NewCounters()}
+}
+
+func (c *Collector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	paths := c.paths
+	if len(paths) == 0 {
+		self, err := c.ownCgroup()
+		if err != nil {
+			return nil, err
+		}
+		paths = []string{self}
+	}
+
+	var (
+		metrics []models.Metrics
+		errs    []error
+	)
+	for _, p := range paths {
+		rel := c.relative(p)
+		m, err := c.collect(rel)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cgroup %s: %w", rel, err))
+			continue
+		}
+		metrics = append(metrics, m...)
+	}
+	return metrics, errors.Join(errs...)
+}
+
+// relative приводит путь к виду относительно корня cgroupfs: /system.slice/nginx.service
+func (c *Collector) relative(p string) string {
+	p = filepath.Clean("/" + p)
+	if rel, err := filepath.Rel(c.root, p); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.Clean("/" + rel)
+	}
+	return p
+}
+
+// ownCgroup возвращает путь из строки "0::<path>" единой иерархии cgroup v2
+func (c *Collector) ownCgroup() (string, error) {
+	data, err := os.ReadFile(c.selfCgroup)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup v2 entry in %s", c.selfCgroup)
+}
+
+func (c *Collector) collect(rel string) ([]models.Metrics, error) {
+	dir := filepath.Join(c.root, rel)
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	var metrics []models.Metrics
+	labels := map[string]string{"cgroup": rel}
+	gauge := func(name string, value float64) {
+		metrics = append(metrics, models.Metrics{ID: models.FormatID(name, labels), MType: models.Gauge, Value: &value, Meta: meta[name]})
+	}
+	counter := func(name string, counterLabels map[string]string, value float64) {
+		id := models.FormatID(name, counterLabels)
+		if delta, ok := c.counters.Delta(id, 0, value); ok {
+			metrics = append(metrics, models.Metrics{ID: id, MType: models.Counter, Delta: &delta, Meta: meta[name]})
+		}
+	}
+
+	for file, name := range map[string]string{
+		"memory.current": MetricMemory,
+		"memory.max":     MetricMemoryLimit,
+		"pids.current":   MetricPids,
+		"pids.max":       MetricPidsLimit,
+	} {
+		// "max" — лимита нет
+		if v, ok := readValue(filepath.Join(dir, file)); ok {
+			gauge(name, v)
+		}
+	}
+
+	if stat, err := readFlatKeyed(filepath.Join(dir, "cpu.stat")); err == nil {
+		for key, name := range cpuStatMetrics {
+			if v, ok := stat[key]; ok {
+				counter(name, labels, v)
+			}
+		}
+	}
+
+	if err := readIOStat(filepath.Join(dir, "io.stat"), func(device string, stat map[string]float64) {
+		devLabels := map[string]string{"cgroup": rel, "device": device}
+		for key, name := range ioStatMetrics {
+			if v, ok := stat[key]; ok {
+				counter(name, devLabels, v)
+			}
+		}
+	}); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return metrics, err
+	}
+	return metrics, nil
+}
+
+func readValue(path string) (float64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	return v, err == nil
+}
+
+// readFlatKeyed читает файл формата "ключ значение" по строке, как cpu.stat
+func readFlatKeyed(path string) (map[string]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]float64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseFloat(fields[1], 64); err == nil {
+			values[fields[0]] = v
+		}
+	}
+	return values, scanner.Err()
+}
+
+// readIOStat читает строки вида "8:0 rbytes=1 wbytes=2 rios=3 wios=4 dbytes=0 dios=0"
+func readIOStat(path string, fn func(device string, stat map[string]float64)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		stat := make(map[string]float64, len(fields)-1)
+		for _, kv := range fields[1:] {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				continue
+			}
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				stat[k] = n
+			}
+		}
+		fn(fields[0], stat)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,85 @@
+package cgroup_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/agent/cgroup"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+}
+
+func byID(metrics []models.Metrics) map[string]models.Metrics {
+	res := make(map[string]models.Metrics)
+	for _, m := range metrics {
+		res[m.ID] = m
+	}
+	return res
+}
+
+func TestCollectOwnCgroup(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "system.slice", "app.service")
+	writeFiles(t, dir, map[string]string{
+		"memory.current": "104857600\n",
+		"memory.max":     "max\n",
+		"pids.current":   "12\n",
+		"pids.max":       "100\n",
+		"cpu.stat":       "usage_usec 5000\nuser_usec 3000\nsystem_usec 2000\nnr_periods 10\nnr_throttled 1\nthrottled_usec 700\n",
+		"io.stat":        "8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n",
+	})
+	self := filepath.Join(t.TempDir(), "cgroup")
+	require.NoError(t, os.WriteFile(self, []byte("0::/system.slice/app.service\n"), 0o644))
+
+	c := cgroup.New(root, self, nil)
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	m := byID(metrics)
+
+	const label = `{cgroup="/system.slice/app.service"}`
+	assert.Equal(t, 104857600.0, *m["cgroup_memory_bytes"+label].Value)
+	assert.NotContains(t, m, "cgroup_memory_limit_bytes"+label, "Лимит max не отправляется")
+	assert.Equal(t, 100.0, *m["cgroup_pids_limit"+label].Value)
+	assert.NotContains(t, m, "cgroup_cpu_usage_usec_total"+label, "Первое значение — точка отсчёта")
+
+	// Накопительные значения передаются приращениями
+	writeFiles(t, dir, map[string]string{
+		"cpu.stat": "usage_usec 5600\nuser_usec 3000\nsystem_usec 2600\nnr_throttled 2\nthrottled_usec 700\n",
+		"io.stat":  "8:0 rbytes=4096 wbytes=9192 rios=1 wios=3 dbytes=0 dios=0\n",
+	})
+	metrics, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	m = byID(metrics)
+	assert.Equal(t, int64(600), *m["cgroup_cpu_usage_usec_total"+label].Delta)
+	assert.Equal(t, int64(1), *m["cgroup_cpu_throttled_periods_total"+label].Delta)
+	assert.Equal(t, int64(1000), *m[`cgroup_io_write_bytes_total{cgroup="/system.slice/app.service",device="8:0"}`].Delta)
+	assert.NotContains(t, m, "cgroup_cpu_user_usec_total"+label)
+
+	// Новый коллектор после перезапуска только запоминает счётчики cgroup, а не шлёт их итог как дельту
+	metrics, err = cgroup.New(root, self, nil).Collect(context.Background())
+	require.NoError(t, err)
+	assert.NotContains(t, byID(metrics), "cgroup_cpu_usage_usec_total"+label)
+}
+
+func TestCollectConfiguredPaths(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, filepath.Join(root, "a"), map[string]string{"memory.current": "1\n"})
+
+	// Абсолютный путь в cgroupfs и путь относительно корня равнозначны; отсутствующий каталог — ошибка
+	c := cgroup.New(root, "/nonexistent", []string{filepath.Join(root, "a"), "missing"})
+	metrics, err := c.Collect(context.Background())
+	assert.ErrorContains(t, err, "cgroup /missing")
+	require.Len(t, metrics, 1)
+	assert.Equal(t, `cgroup_memory_bytes{cgroup="/a"}`, metrics[0].ID)
+}
@@ -0,0 +1,311 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"time"
+)
+
+// Config — файл конфигурации агента (флаг -c или CONFIG) с настройками дополнительных сборщиков
+type Config struct {
+	Exec     []Exec     `json:"exec"`
+	Procstat []Procstat `json:"procstat"`
+	Cgroup   *Cgroup    `json:"cgroup"`
+	Runtime  *Runtime   `json:"runtime_metrics"`
+	Scrape   []Scrape   `json:"scrape"`
+	Probes   []Probe    `json:"probes"`
+	LogTail  *LogTail   `json:"logtail"`
+}
+
+// Exec — внешняя команда, печатающая метрики в stdout
+type Exec struct {
+	Name     string   `json:"name"`
+	Command  []string `json:"command"` // программа и аргументы, без оболочки
+	Interval Duration `json:"interval"`
+	Timeout  Duration `json:"timeout"`
+}
+
+// Procstat — группа процессов, отбираемых по имени (comm), pid-файлу или регулярному выражению по cmdline.
+// Name становится меткой process или, при Prefix, префиксом имён метрик.
+type Procstat struct {
+	Name    string `json:"name"`
+	Exe     string `json:"exe"`
+	Pidfile string `json:"pidfile"`
+	Cmdline string `json:"cmdline"`
+	Prefix  bool   `json:"prefix"`
+}
+
+// Cgroup включает метрики cgroup v2. Paths — каталоги cgroup (абсолютные в cgroupfs
+// или относительно её корня); пустой список — собственная cgroup агента.
+type Cgroup struct {
+	Paths []string `json:"paths"`
+}
+
+// Runtime включает метрики пакета runtime/metrics с именами, начинающимися с одного
+// из Prefixes ("/sched/", "/gc/pauses"); пустой список — все. MemStats: false отключает
+// прежний набор runtime.MemStats и его stop-the-world чтение.
+type Runtime struct {
+	Prefixes []string `json:"prefixes"`
+	MemStats *bool    `json:"memstats"`
+}
+
+// Scrape — HTTP-эндпоинт в текстовом формате Prometheus, опрашиваемый на каждом PollInterval.
+// FlattenLabels переносит метки в имя (node_cpu_cpu_0_mode_idle) вместо синтаксиса name{k="v"}.
+type Scrape struct {
+	Name          string   `json:"name"`
+	URL           string   `json:"url"`
+	Timeout       Duration `json:"timeout"`
+	Prefix        string   `json:"prefix"`
+	FlattenLabels bool     `json:"flatten_labels"`
+}
+
+// Probe — синтетическая проверка доступности. Для http Target — URL, запрос GET успешен
+// при коде из ExpectStatus (по умолчанию любой 2xx) и теле, совпадающем с ExpectBody.
+// Для tcp Target — host:port, успех — установленное соединение, при TLS — и рукопожатие.
+type Probe struct {
+	Name               string   `json:"name"`
+	Type               string   `json:"type"`
+	Target             string   `json:"target"`
+	Interval           Duration `json:"interval"`
+	Timeout            Duration `json:"timeout"`
+	ExpectStatus       []int    `json:"expect_status"`
+	ExpectBody         string   `json:"expect_body"`
+	TLS                bool     `json:"tls"`
+	InsecureSkipVerify bool     `json:"insecure_skip_verify"`
+}
+
+// LogTail — чтение журналов. StateFile хранит смещения в файлах, чтобы после
+// перезапуска агента строки не учитывались повторно; пустой путь — без сохранения.
+type LogTail struct {
+	StateFile string    `json:"state_file"`
+	Files     []LogFile `json:"files"`
+}
+
+// LogFile — отслеживаемый файл; Name становится меткой log. Новый файл без сохранённого
+// смещения читается с конца, при FromBeginning — с начала.
+type LogFile struct {
+	Name          string    `json:"name"`
+	Path          string    `json:"path"`
+	FromBeginning bool      `json:"from_beginning"`
+	Rules         []LogRule `json:"rules"`
+}
+
+// LogRule увеличивает счётчик Counter на каждую строку, совпавшую с Match, и выставляет
+// gauge из именованных групп: Gauges отображает имя группы в имя метрики.
+type LogRule struct {
+	Match   string            `json:"match"`
+	Counter string            `json:"counter"`
+	Gauges  map[string]string `json:"gauges"`
+}
+
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+)
+
+const (
+	DefaultExecInterval = time.Minute
+	DefaultExecTimeout  = 10 * time.Second
+
+	DefaultScrapeTimeout = 5 * time.Second
+
+	DefaultProbeInterval = 30 * time.Second
+	DefaultProbeTimeout  = 10 * time.Second
+)
+
+// Duration читается из JSON строкой в формате time.ParseDuration: "30s", "5m"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Load читает конфигурацию; неизвестные поля считаются ошибкой, чтобы опечатки не проходили молча
+func Load(path string) (*Config, error) {
+	const op = "internal.agent.config.Load"
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &cfg, nil
+}
+
+// validate проверяет значения и подставляет значения по умолчанию
+func (c *Config) validate() error {
+	names := make(map[string]bool)
+	for i := range c.Exec {
+		e := &c.Exec[i]
+		if e.Name == "" || len(e.Command) == 0 {
+			return fmt.Errorf("exec[%d]: name and command are required", i)
+		}
+		if names[e.Name] {
+			return fmt.Errorf("exec[%d]: duplicate name %q", i, e.Name)
+		}
+		names[e.Name] = true
+
+		if e.Interval < 0 || e.Timeout < 0 {
+			return fmt.Errorf("exec %q: interval and timeout must not be negative", e.Name)
+		}
+		if e.Interval == 0 {
+			e.Interval = Duration(DefaultExecInterval)
+		}
+		if e.Timeout == 0 {
+			e.Timeout = Duration(min(DefaultExecTimeout, time.Duration(e.Interval)))
+		}
+	}
+
+	names = make(map[string]bool)
+	for i, p := range c.Procstat {
+		if p.Name == "" {
+			return fmt.Errorf("procstat[%d]: name is required", i)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("procstat[%d]: duplicate name %q", i, p.Name)
+		}
+		names[p.Name] = true
+
+		set := 0
+		for _, v := range []string{p.Exe, p.Pidfile, p.Cmdline} {
+			if v != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return fmt.Errorf("procstat %q: exactly one of exe, pidfile and cmdline is required", p.Name)
+		}
+		if p.Cmdline != "" {
+			if _, err := regexp.Compile(p.Cmdline); err != nil {
+				return fmt.Errorf("procstat %q: %w", p.Name, err)
+			}
+		}
+	}
+
+	names = make(map[string]bool)
+	for i := range c.Scrape {
+		sc := &c.Scrape[i]
+		if sc.Name == "" || sc.URL == "" {
+			return fmt.Errorf("scrape[%d]: name and url are required", i)
+		}
+		if names[sc.Name] {
+			return fmt.Errorf("scrape[%d]: duplicate name %q", i, sc.Name)
+		}
+		names[sc.Name] = true
+
+		if u, err := url.Parse(sc.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("scrape %q: url must be http(s)", sc.Name)
+		}
+		if sc.Timeout < 0 {
+			return fmt.Errorf("scrape %q: timeout must not be negative", sc.Name)
+		}
+		if sc.Timeout == 0 {
+			sc.Timeout = Duration(DefaultScrapeTimeout)
+		}
+	}
+
+	names = make(map[string]bool)
+	for i := range c.Probes {
+		p := &c.Probes[i]
+		if p.Name == "" || p.Target == "" {
+			return fmt.Errorf("probes[%d]: name and target are required", i)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("probes[%d]: duplicate name %q", i, p.Name)
+		}
+		names[p.Name] = true
+
+		switch p.Type {
+		case ProbeHTTP:
+			if u, err := url.Parse(p.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("probe %q: target must be an http(s) url", p.Name)
+			}
+			for _, code := range p.ExpectStatus {
+				if code < 100 || code > 599 {
+					return fmt.Errorf("probe %q: invalid expected status %d", p.Name, code)
+				}
+			}
+			if _, err := regexp.Compile(p.ExpectBody); err != nil {
+				return fmt.Errorf("probe %q: %w", p.Name, err)
+			}
+		case ProbeTCP:
+			if _, _, err := net.SplitHostPort(p.Target); err != nil {
+				return fmt.Errorf("probe %q: %w", p.Name, err)
+			}
+			if len(p.ExpectStatus) > 0 || p.ExpectBody != "" {
+				return fmt.Errorf("probe %q: expect_status and expect_body apply to http probes only", p.Name)
+			}
+		default:
+			return fmt.Errorf("probe %q: type must be %q or %q", p.Name, ProbeHTTP, ProbeTCP)
+		}
+
+		if p.Interval < 0 || p.Timeout < 0 {
+			return fmt.Errorf("probe %q: interval and timeout must not be negative", p.Name)
+		}
+		if p.Interval == 0 {
+			p.Interval = Duration(DefaultProbeInterval)
+		}
+		if p.Timeout == 0 {
+			p.Timeout = Duration(min(DefaultProbeTimeout, time.Duration(p.Interval)))
+		}
+	}
+
+	if c.LogTail != nil {
+		names = make(map[string]bool)
+		for i, f := range c.LogTail.Files {
+			if f.Name == "" || f.Path == "" {
+				return fmt.Errorf("logtail.files[%d]: name and path are required", i)
+			}
+			if names[f.Name] {
+				return fmt.Errorf("logtail.files[%d]: duplicate name %q", i, f.Name)
+			}
+			names[f.Name] = true
+
+			if len(f.Rules) == 0 {
+				return fmt.Errorf("logtail %q: at least one rule is required", f.Name)
+			}
+			for j, r := range f.Rules {
+				re, err := regexp.Compile(r.Match)
+				if err != nil {
+					return fmt.Errorf("logtail %q: rules[%d]: %w", f.Name, j, err)
+				}
+				if r.Counter == "" && len(r.Gauges) == 0 {
+					return fmt.Errorf("logtail %q: rules[%d]: counter or gauges is required", f.Name, j)
+				}
+				for group, metric := range r.Gauges {
+					if re.SubexpIndex(group) < 0 || metric == "" {
+						return fmt.Errorf("logtail %q: rules[%d]: gauge %q needs a named group in match and a metric name", f.Name, j, group)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package execplugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/agent/config"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+// ErrorsMetric — счётчик неудачных запусков с меткой plugin
+const ErrorsMetric = "exec_plugin_errors"
+
+const source = "exec"
+
+// Ingester принимает разобранный вывод плагина; agent.Agent.Ingest проверяет метрики перед отправкой
+type Ingester interface {
+	Ingest(metrics []models.Metrics) error
+}
+
+// Plugin периодически запускает команду и передаёт напечатанные ею метрики агенту
+type Plugin struct {
+	cfg      config.Exec
+	ingester Ingester
+}
+
+func New(cfg config.Exec, ingester Ingester) *Plugin {
+	return &Plugin{cfg: cfg, ingester: ingester}
+}
+
+// Run запускает команду сразу и затем раз в интервал до отмены контекста
+func (p *Plugin) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(p.cfg.Interval))
+	defer ticker.Stop()
+	for {
+		if err := p.Collect(ctx); err != nil && ctx.Err() == nil {
+			fmt.Printf("Exec plugin %s failed: %v\n", p.cfg.Name, err)
+			p.countError()
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Collect выполняет команду один раз. При ненулевом коде выхода или тайм-ауте
+// ничего не передаётся; при ошибках разбора корректные строки всё равно передаются.
+func (p *Plugin) Collect(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(p.cfg.Timeout))
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.cfg.Command[0], p.cfg.Command[1:]...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %s", time.Duration(p.cfg.Timeout))
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+
+	metrics, parseErr := Parse(stdout.Bytes())
+	for i := range metrics {
+		if metrics[i].Meta == nil {
+			metrics[i].Meta = &models.MetricMeta{Source: source}
+		}
+	}
+	if len(metrics) > 0 {
+		if err := p.ingester.Ingest(metrics); err != nil {
+			return errors.Join(parseErr, err)
+		}
+	}
+	return parseErr
+}
+
+func (p *Plugin) countError() {
+	one := int64(1)
+	id := models.FormatID(ErrorsMetric, map[string]string{"plugin": p.cfg.Name})
+	if err := p.ingester.Ingest([]models.Metrics{{ID: id, MType: models.Counter, Delta: &one}}); err != nil {
+		fmt.Printf("Error counting exec plugin failure: %v\n", err)
+	}
+}
+
+// Parse разбирает вывод команды. JSON (объекты или массивы models.Metrics) распознаётся
+// по первому символу, иначе ожидаются строки "name type value":
+//
+//	# комментарий
+//	disk_free{mount="/"} gauge 1.5e9
+//	backups_done counter 1
+//	backup_seconds histogram 12.5
+//
+// Имя может содержать пробелы внутри меток: тип и значение — два последних поля.
+func Parse(out []byte) ([]models.Metrics, error) {
+	trimmed := bytes.TrimSpace(out)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return parseJSON(trimmed)
+	}
+
+	var (
+		metrics []models.Metrics
+		errs    []error
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m, err := parseLine(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", n, err))
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return metrics, errors.Join(errs...)
+}
+
+func parseLine(line string) (models.Metrics, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return models.Metrics{}, errors.New(`expected "name type value"`)
+	}
+	rawValue, mtype := fields[len(fields)-1], fields[len(fields)-2]
+	rest := strings.TrimSpace(line[:strings.LastIndex(line, rawValue)])
+	name := strings.TrimSpace(rest[:strings.LastIndex(rest, mtype)])
+
+	m := models.Metrics{ID: name, MType: mtype}
+	switch mtype {
+	case models.Gauge:
+		v, err := strconv.ParseFloat(rawValue, 64)
+		if err != nil {
+			return models.Metrics{}, fmt.Errorf("invalid gauge value %q", rawValue)
+		}
+		m.Value = &v
+	case models.Counter:
+		v, err := strconv.ParseInt(rawValue, 10, 64)
+		if err != nil {
+			return models.Metrics{}, fmt.Errorf("invalid counter value %q", rawValue)
+		}
+		m.Delta = &v
+	case models.Histogram:
+		v, err := strconv.ParseFloat(rawValue, 64)
+		if err != nil {
+			return models.Metrics{}, fmt.Errorf("invalid histogram observation %q", rawValue)
+		}
+		m.Histogram = &models.HistogramData{Observations: []float64{v}}
+	default:
+		return models.Metrics{}, fmt.Errorf("unknown metric type %q", mtype)
+	}
+	return m, nil
+}
+
+func parseJSON(out []byte) ([]models.Metrics, error) {
+	var metrics []models.Metrics
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return metrics, nil
+			}
+			return metrics, fmt.Errorf("invalid JSON output: %w", err)
+		}
+		if raw[0] == '[' {
+			var batch []models.Metrics
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				return metrics, fmt.Errorf("invalid JSON output: %w", err)
+			}
+			metrics = append(metrics, batch...)
+			continue
+		}
+		var m models.Metrics
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return metrics, fmt.Errorf("invalid JSON output: %w", err)
+		}
+		metrics = append(metrics, m)
+	}
+}
@@ -0,0 +1,88 @@
+package execplugin_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/agent/config"
+	"github.com/zetcan333/metrics-collector/internal/agent/execplugin"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+type recorder struct {
+	mu      sync.Mutex
+	metrics []models.Metrics
+}
+
+func (r *recorder) Ingest(metrics []models.Metrics) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, metrics...)
+	return nil
+}
+
+func (r *recorder) byID() map[string]models.Metrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make(map[string]models.Metrics)
+	for _, m := range r.metrics {
+		res[m.ID] = m
+	}
+	return res
+}
+
+func TestParse(t *testing.T) {
+	metrics, err := execplugin.Parse([]byte(`
+# проверка диска
+disk_free{mount="/my disk"} gauge 1.5e9
+backups_done counter 2
+backup_seconds histogram 12.5
+broken line
+`))
+	require.Len(t, metrics, 3)
+	assert.ErrorContains(t, err, "line 6")
+
+	assert.Equal(t, `disk_free{mount="/my disk"}`, metrics[0].ID)
+	assert.Equal(t, 1.5e9, *metrics[0].Value)
+	assert.Equal(t, int64(2), *metrics[1].Delta)
+	assert.Equal(t, []float64{12.5}, metrics[2].Histogram.Observations)
+
+	metrics, err = execplugin.Parse([]byte(`{"id":"a","type":"gauge","value":1}
+[{"id":"b","type":"counter","delta":3}]`))
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+	assert.Equal(t, "b", metrics[1].ID)
+}
+
+func TestRunCountsFailures(t *testing.T) {
+	ok := &recorder{}
+	p := execplugin.New(config.Exec{
+		Name:     "ok",
+		Command:  []string{"sh", "-c", "echo 'queue gauge 7'"},
+		Interval: config.Duration(time.Hour),
+		Timeout:  config.Duration(time.Second),
+	}, ok)
+	require.NoError(t, p.Collect(context.Background()))
+	assert.Equal(t, 7.0, *ok.byID()["queue"].Value)
+
+	failing := &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, cfg := range []config.Exec{
+		{Name: "exit", Command: []string{"sh", "-c", "echo 'x gauge 1'; exit 3"}},
+		{Name: "slow", Command: []string{"sleep", "5"}},
+	} {
+		cfg.Interval, cfg.Timeout = config.Duration(time.Hour), config.Duration(50*time.Millisecond)
+		go execplugin.New(cfg, failing).Run(ctx)
+	}
+
+	require.Eventually(t, func() bool { return len(failing.byID()) == 2 }, 2*time.Second, 10*time.Millisecond)
+	metrics := failing.byID()
+	assert.Equal(t, int64(1), *metrics[`exec_plugin_errors{plugin="exit"}`].Delta)
+	assert.Equal(t, int64(1), *metrics[`exec_plugin_errors{plugin="slow"}`].Delta)
+	assert.NotContains(t, metrics, "x", "Вывод завершившейся с ошибкой команды не передаётся")
+}
@@ -0,0 +1,226 @@
+package agent
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/pkg/myerrors"
+)
+
+const (
+	maxIngestBody   = 4 << 20
+	maxIngestPacket = 65535
+)
+
+var (
+	errTypeConflict = errors.New("metric already exists with another type")
+	errOwnMetric    = errors.New("metric is collected by the agent itself")
+)
+
+// Ingest добавляет метрики соседних приложений в a.Metrics. Они уходят на сервер
+// в обычном цикле SendMetricsBatch: gauge заменяет значение, приращения counter
+// и наблюдения гистограмм копятся до успешной отправки. Пачка принимается целиком или не принимается.
+func (a *Agent) Ingest(metrics []models.Metrics) error {
+	for i := range metrics {
+		if err := validateIngest(&metrics[i]); err != nil {
+			return fmt.Errorf("metric %q: %w", metrics[i].ID, err)
+		}
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	merged := make(map[string]models.Metrics, len(metrics))
+	for _, m := range metrics {
+		current, ok := merged[m.ID]
+		if !ok {
+			current, ok = a.Metrics[m.ID]
+			if ok && !a.ingested[m.ID] {
+				return fmt.Errorf("metric %q: %w", m.ID, errOwnMetric)
+			}
+		}
+		if ok && current.MType != m.MType {
+			return fmt.Errorf("metric %q: %w", m.ID, errTypeConflict)
+		}
+		// Значения не меняются на месте: снимок для отправки мог забрать те же указатели
+		switch m.MType {
+		case models.Gauge:
+			value := *m.Value
+			current = models.Metrics{ID: m.ID, MType: m.MType, Value: &value, Meta: m.Meta}
+		case models.Counter:
+			delta := *m.Delta
+			if ok && current.Delta != nil {
+				delta += *current.Delta
+			}
+			current = models.Metrics{ID: m.ID, MType: m.MType, Delta: &delta, Meta: m.Meta}
+		case models.Histogram:
+			var stored *models.HistogramData
+			if ok {
+				stored = current.Histogram
+			}
+			h, err := models.MergeHistograms(stored, *m.Histogram)
+			if err != nil {
+				return fmt.Errorf("metric %q: %w", m.ID, err)
+			}
+			current = models.Metrics{ID: m.ID, MType: m.MType, Histogram: h, Meta: m.Meta}
+		}
+		merged[m.ID] = current
+	}
+
+	for id, m := range merged {
+		a.Metrics[id] = m
+		a.ingested[id] = true
+	}
+	return nil
+}
+
+func validateIngest(m *models.Metrics) error {
+	if m.ID == "" {
+		return errors.New("empty metric id")
+	}
+	switch m.MType {
+	case models.Gauge:
+		if m.Value == nil || math.IsNaN(*m.Value) || math.IsInf(*m.Value, 0) {
+			return myerrors.ErrInvalidGaugeValue
+		}
+	case models.Counter:
+		if m.Delta == nil {
+			return myerrors.ErrInvalidCounterValue
+		}
+	case models.Histogram:
+		if m.Histogram == nil {
+			return myerrors.ErrInvalidHistogramValue
+		}
+		if len(m.Histogram.Bounds) == 0 {
+			m.Histogram.Bounds = models.DefaultHistogramBounds
+		}
+		return m.Histogram.Validate()
+	default:
+		return myerrors.ErrInvalidMetricType
+	}
+	return nil
+}
+
+// IngestHandler принимает метрики в формате API сервера: POST /update/ с одной метрикой
+// и POST /updates/ с массивом, тело может быть сжато gzip
+func (a *Agent) IngestHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /update/", func(w http.ResponseWriter, r *http.Request) {
+		a.serveIngest(w, r, false)
+	})
+	mux.HandleFunc("POST /updates/", func(w http.ResponseWriter, r *http.Request) {
+		a.serveIngest(w, r, true)
+	})
+	return mux
+}
+
+func (a *Agent) serveIngest(w http.ResponseWriter, r *http.Request, batch bool) {
+	body := io.Reader(http.MaxBytesReader(w, r.Body, maxIngestBody))
+	if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			http.Error(w, "Failed to read gzip data", http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	var metrics []models.Metrics
+	if batch {
+		if err := json.NewDecoder(body).Decode(&metrics); err != nil {
+			http.Error(w, "invalid JSON format", http.StatusBadRequest)
+			return
+		}
+	} else {
+		var metric models.Metrics
+		if err := json.NewDecoder(body).Decode(&metric); err != nil {
+			http.Error(w, "invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		metrics = append(metrics, metric)
+	}
+
+	if err := a.Ingest(metrics); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// RunHTTPIngest слушает addr до отмены контекста
+func (a *Agent) RunHTTPIngest(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Local HTTP ingestion on", ln.Addr())
+
+	srv := &http.Server{Handler: a.IngestHandler(), ReadHeaderTimeout: 5 * time.Second}
+	stop := context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	})
+	defer stop()
+
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// RunUDPIngest принимает датаграммы с JSON-метрикой или массивом метрик до отмены контекста
+func (a *Agent) RunUDPIngest(ctx context.Context, addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Local UDP ingestion on", conn.LocalAddr())
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	buf := make([]byte, maxIngestPacket)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		metrics, err := decodePacket(buf[:n])
+		if err == nil {
+			err = a.Ingest(metrics)
+		}
+		if err != nil {
+			fmt.Printf("Error ingesting UDP packet: %v\n", err)
+		}
+	}
+}
+
+func decodePacket(packet []byte) ([]models.Metrics, error) {
+	var metrics []models.Metrics
+	if strings.HasPrefix(strings.TrimSpace(string(packet)), "[") {
+		if err := json.Unmarshal(packet, &metrics); err != nil {
+			return nil, fmt.Errorf("invalid JSON format: %w", err)
+		}
+		return metrics, nil
+	}
+	var metric models.Metrics
+	if err := json.Unmarshal(packet, &metric); err != nil {
+		return nil, fmt.Errorf("invalid JSON format: %w", err)
+	}
+	return append(metrics, metric), nil
+}
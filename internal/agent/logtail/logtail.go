@@ -0,0 +1,267 @@
+package logtail
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zetcan333/metrics-collector/internal/agent/config"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+const source = "logtail"
+
+// headSize — сколько байт начала файла хранится отпечатком, чтобы после перезапуска
+// отличить прежний файл от нового, появившегося на его месте при ротации
+const headSize = 1024
+
+var logMeta = &models.MetricMeta{Source: source}
+
+type rule struct {
+	re      *regexp.Regexp
+	counter string
+	gauges  map[string]string
+}
+
+// tailer следит за одним файлом. Файл остаётся открытым между опросами: после
+// переименования при ротации сначала дочитывается старый файл, затем открывается новый.
+type tailer struct {
+	cfg    config.LogFile
+	rules  []rule
+	file   *os.File
+	offset int64
+	opened bool // была ли попытка открыть файл; от неё зависит, читать ли новый файл с начала
+}
+
+// fileState — сохранённая позиция в файле
+type fileState struct {
+	Offset int64  `json:"offset"`
+	Head   string `json:"head"`
+}
+
+// Collector читает дописанные в журналы строки на каждом опросе. Счётчики передаются
+// приращениями за опрос, gauge — последним значением из группы.
+type Collector struct {
+	stateFile string
+	tailers   []*tailer
+	state     map[string]fileState
+}
+
+// New создаёт сборщик и читает сохранённые смещения; cfg должен пройти проверку config.Load
+func New(cfg *config.LogTail) (*Collector, error) {
+	const op = "internal.agent.logtail.New"
+
+	c := &Collector{stateFile: cfg.StateFile, state: make(map[string]fileState)}
+	for _, f := range cfg.Files {
+		t := &tailer{cfg: f}
+		for _, r := range f.Rules {
+			t.rules = append(t.rules, rule{re: regexp.MustCompile(r.Match), counter: r.Counter, gauges: r.Gauges})
+		}
+		c.tailers = append(c.tailers, t)
+	}
+
+	if c.stateFile != "" {
+		data, err := os.ReadFile(c.stateFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &c.state); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", op, c.stateFile, err)
+			}
+		}
+	}
+	return c, nil
+}
+
+func (c *Collector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	var (
+		metrics []models.Metrics
+		errs    []error
+	)
+	for _, t := range c.tailers {
+		m, err := t.collect(c.state[t.cfg.Path])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("logtail %s: %w", t.cfg.Name, err))
+		}
+		metrics = append(metrics, m...)
+	}
+	if err := c.saveState(); err != nil {
+		errs = append(errs, err)
+	}
+	return metrics, errors.Join(errs...)
+}
+
+func (t *tailer) collect(saved fileState) ([]models.Metrics, error) {
+	counts := make(map[string]int64)
+	gauges := make(map[string]float64)
+	labels := map[string]string{"log": t.cfg.Name}
+	match := func(line string) {
+		for _, r := range t.rules {
+			groups := r.re.FindStringSubmatch(line)
+			if groups == nil {
+				continue
+			}
+			if r.counter != "" {
+				counts[models.FormatID(r.counter, labels)]++
+			}
+			for group, metric := range r.gauges {
+				if v, err := strconv.ParseFloat(groups[r.re.SubexpIndex(group)], 64); err == nil {
+					gauges[models.FormatID(metric, labels)] = v
+				}
+			}
+		}
+	}
+
+	err := t.follow(saved, match)
+
+	var metrics []models.Metrics
+	for id, n := range counts {
+		metrics = append(metrics, models.Metrics{ID: id, MType: models.Counter, Delta: &n, Meta: logMeta})
+	}
+	for id, v := range gauges {
+		metrics = append(metrics, models.Metrics{ID: id, MType: models.Gauge, Value: &v, Meta: logMeta})
+	}
+	return metrics, err
+}
+
+// follow дочитывает открытый файл и переходит на новый, если путь теперь указывает на другой файл
+func (t *tailer) follow(saved fileState, fn func(line string)) error {
+	if t.file == nil {
+		if err := t.open(saved); err != nil || t.file == nil {
+			return err
+		}
+	}
+
+	info, err := t.file.Stat()
+	if err != nil {
+		return err
+	}
+	// Размер меньше смещения — файл обрезан (copytruncate)
+	if info.Size() < t.offset {
+		t.offset = 0
+	}
+	if err := t.read(fn); err != nil {
+		return err
+	}
+
+	current, err := os.Stat(t.cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Старый файл переименован, новый ещё не создан
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if os.SameFile(info, current) {
+		return nil
+	}
+
+	t.file.Close()
+	t.file = nil
+	if err := t.open(fileState{}); err != nil || t.file == nil {
+		return err
+	}
+	return t.read(fn)
+}
+
+// open открывает файл по пути. Сохранённое смещение используется, если начало файла
+// совпадает с отпечатком; файл, найденный при первом опросе без сохранённого смещения,
+// читается с конца, если не задан FromBeginning.
+func (t *tailer) open(saved fileState) error {
+	first := !t.opened
+	t.opened = true
+
+	f, err := os.Open(t.cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	t.file, t.offset = f, 0
+	switch {
+	case saved.Head != "" && saved.Offset <= info.Size() && head(f, saved.Offset) == saved.Head:
+		t.offset = saved.Offset
+	case first && saved.Head == "" && !t.cfg.FromBeginning:
+		t.offset = info.Size()
+	}
+	return nil
+}
+
+// read передаёт полные строки после смещения; незаконченная последняя строка
+// остаётся до следующего опроса
+func (t *tailer) read(fn func(line string)) error {
+	r := bufio.NewReader(io.NewSectionReader(t.file, t.offset, math.MaxInt64-t.offset))
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		t.offset += int64(len(line))
+		fn(strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// head возвращает отпечаток первых min(offset, headSize) байт файла
+func head(f *os.File, offset int64) string {
+	buf := make([]byte, min(offset, headSize))
+	n, _ := f.ReadAt(buf, 0)
+	sum := sha256.Sum256(buf[:n])
+	return hex.EncodeToString(sum[:])
+}
+
+// saveState записывает смещения через временный файл, чтобы сбой не оставил его обрезанным
+func (c *Collector) saveState() error {
+	const op = "internal.agent.logtail.saveState"
+
+	if c.stateFile == "" {
+		return nil
+	}
+	for _, t := range c.tailers {
+		if t.file != nil {
+			c.state[t.cfg.Path] = fileState{Offset: t.offset, Head: head(t.file, t.offset)}
+		}
+	}
+
+	data, err := json.Marshal(c.state)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.stateFile), filepath.Base(c.stateFile)+".*")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := os.Rename(tmp.Name(), c.stateFile); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
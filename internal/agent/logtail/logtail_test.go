@@ -0,0 +1,119 @@
+package logtail_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/agent/config"
+	"github.com/zetcan333/metrics-collector/internal/agent/logtail"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+const (
+	errorsID  = `app_errors_total{log="app"}`
+	latencyID = `app_latency_ms{log="app"}`
+)
+
+func byID(metrics []models.Metrics) map[string]models.Metrics {
+	res := make(map[string]models.Metrics)
+	for _, m := range metrics {
+		res[m.ID] = m
+	}
+	return res
+}
+
+func appendLines(t *testing.T, path, lines string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(lines)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func collect(t *testing.T, c *logtail.Collector) map[string]models.Metrics {
+	t.Helper()
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	return byID(metrics)
+}
+
+func TestCollectFollowsRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendLines(t, path, "level=error old\n")
+
+	cfg := &config.LogTail{
+		StateFile: filepath.Join(dir, "state.json"),
+		Files: []config.LogFile{{
+			Name: "app",
+			Path: path,
+			Rules: []config.LogRule{
+				{Match: `level=error`, Counter: "app_errors_total"},
+				{Match: `latency=(?P<ms>\d+)ms`, Gauges: map[string]string{"ms": "app_latency_ms"}},
+			},
+		}},
+	}
+	c, err := logtail.New(cfg)
+	require.NoError(t, err)
+
+	// Строки, записанные до первого опроса, не учитываются
+	assert.Empty(t, collect(t, c))
+
+	appendLines(t, path, "level=error a\nlevel=info latency=12ms\nlevel=error latency=30ms\nlevel=error partial")
+	m := collect(t, c)
+	assert.Equal(t, int64(2), *m[errorsID].Delta)
+	assert.Equal(t, 30.0, *m[latencyID].Value)
+
+	// Незаконченная строка учитывается после перевода строки; затем файл переименовывается
+	appendLines(t, path, " line\n")
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendLines(t, path+".1", "level=error late\n")
+	appendLines(t, path, "level=error new\n")
+	m = collect(t, c)
+	assert.Equal(t, int64(3), *m[errorsID].Delta)
+
+	// Обрезание на месте (copytruncate)
+	require.NoError(t, os.Truncate(path, 0))
+	appendLines(t, path, "latency=5ms\n")
+	m = collect(t, c)
+	assert.NotContains(t, m, errorsID)
+	assert.Equal(t, 5.0, *m[latencyID].Value)
+
+	// После перезапуска чтение продолжается с сохранённого смещения
+	appendLines(t, path, "level=error after restart\n")
+	c, err = logtail.New(cfg)
+	require.NoError(t, err)
+	m = collect(t, c)
+	assert.Equal(t, int64(1), *m[errorsID].Delta)
+
+	// Файл, заменённый при остановленном агенте, читается с начала
+	require.NoError(t, os.Remove(path))
+	appendLines(t, path, "level=error replaced\nlevel=error replaced\n")
+	c, err = logtail.New(cfg)
+	require.NoError(t, err)
+	m = collect(t, c)
+	assert.Equal(t, int64(2), *m[errorsID].Delta)
+}
+
+func TestCollectFromBeginning(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	c, err := logtail.New(&config.LogTail{Files: []config.LogFile{{
+		Name:          "app",
+		Path:          path,
+		FromBeginning: true,
+		Rules:         []config.LogRule{{Match: `level=error`, Counter: "app_errors_total"}},
+	}}})
+	require.NoError(t, err)
+
+	// Файла ещё нет — не ошибка; появившийся позже файл читается целиком
+	assert.Empty(t, collect(t, c))
+	appendLines(t, path, "level=error one\n")
+	assert.Equal(t, int64(1), *collect(t, c)[errorsID].Delta)
+}
@@ -0,0 +1,213 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/agent/config"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+const source = "probe"
+
+// Имена метрик; у всех есть метка probe с именем проверки
+const (
+	MetricSuccess    = "probe_success"
+	MetricDuration   = "probe_duration_seconds"
+	MetricStatusCode = "probe_http_status_code"
+	MetricTLSExpiry  = "probe_tls_cert_expiry_seconds"
+	MetricFailures   = "probe_failures_total"
+)
+
+var meta = map[string]*models.MetricMeta{
+	MetricSuccess:    {Help: "Whether the last probe succeeded", Source: source},
+	MetricDuration:   {Unit: "seconds", Help: "Duration of the last probe", Source: source},
+	MetricStatusCode: {Help: "HTTP status code of the last probe response", Source: source},
+	MetricTLSExpiry:  {Unit: "seconds", Help: "Time until the earliest peer certificate expires", Source: source},
+	MetricFailures:   {Unit: "count", Help: "Number of failed probes", Source: source},
+}
+
+// maxBodySize ограничивает часть тела ответа, проверяемую регулярным выражением
+const maxBodySize = 1 << 20
+
+// Ingester принимает результаты проверок; в агенте это agent.Agent.Ingest
+type Ingester interface {
+	Ingest(metrics []models.Metrics) error
+}
+
+// Probe периодически выполняет проверку и передаёт её результат агенту
+type Probe struct {
+	cfg      config.Probe
+	ingester Ingester
+	body     *regexp.Regexp
+	client   *http.Client
+}
+
+// New создаёт проверку; cfg должен пройти проверку config.Load
+func New(cfg config.Probe, ingester Ingester) *Probe {
+	p := &Probe{cfg: cfg, ingester: ingester}
+	if cfg.ExpectBody != "" {
+		p.body = regexp.MustCompile(cfg.ExpectBody)
+	}
+	if cfg.Type == config.ProbeHTTP {
+		// Без keep-alive каждая проверка заново устанавливает соединение и TLS
+		p.client = &http.Client{Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify},
+			DisableKeepAlives: true,
+		}}
+	}
+	return p
+}
+
+// Run выполняет проверку сразу и затем раз в интервал до отмены контекста
+func (p *Probe) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(p.cfg.Interval))
+	defer ticker.Stop()
+	for {
+		if err := p.Collect(ctx); err != nil && ctx.Err() == nil {
+			fmt.Printf("Probe %s failed: %v\n", p.cfg.Name, err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// result — измерения одной проверки; нулевые поля не передаются
+type result struct {
+	status int
+	certs  []*x509.Certificate
+}
+
+// Collect выполняет проверку один раз и передаёт метрики. Возвращает причину неудачи проверки
+// или ошибку передачи метрик.
+func (p *Probe) Collect(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(p.cfg.Timeout))
+	defer cancel()
+
+	start := time.Now()
+	var (
+		res      result
+		probeErr error
+	)
+	switch p.cfg.Type {
+	case config.ProbeHTTP:
+		res, probeErr = p.probeHTTP(ctx)
+	case config.ProbeTCP:
+		res, probeErr = p.probeTCP(ctx)
+	default:
+		probeErr = fmt.Errorf("unknown probe type %q", p.cfg.Type)
+	}
+	duration := time.Since(start).Seconds()
+
+	labels := map[string]string{"probe": p.cfg.Name}
+	var metrics []models.Metrics
+	gauge := func(name string, value float64) {
+		metrics = append(metrics, models.Metrics{ID: models.FormatID(name, labels), MType: models.Gauge, Value: &value, Meta: meta[name]})
+	}
+
+	success := 1.0
+	if probeErr != nil {
+		success = 0
+		one := int64(1)
+		metrics = append(metrics, models.Metrics{ID: models.FormatID(MetricFailures, labels), MType: models.Counter, Delta: &one, Meta: meta[MetricFailures]})
+	}
+	gauge(MetricSuccess, success)
+	gauge(MetricDuration, duration)
+	if res.status != 0 {
+		gauge(MetricStatusCode, float64(res.status))
+	}
+	if expiry, ok := earliestExpiry(res.certs); ok {
+		gauge(MetricTLSExpiry, time.Until(expiry).Seconds())
+	}
+
+	if err := p.ingester.Ingest(metrics); err != nil {
+		return errors.Join(probeErr, err)
+	}
+	return probeErr
+}
+
+func (p *Probe) probeHTTP(ctx context.Context) (result, error) {
+	var res result
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.Target, nil)
+	if err != nil {
+		return res, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return res, err
+	}
+	defer resp.Body.Close()
+
+	res.status = resp.StatusCode
+	if resp.TLS != nil {
+		res.certs = resp.TLS.PeerCertificates
+	}
+	if !p.statusExpected(resp.StatusCode) {
+		return res, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if p.body != nil {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+		if err != nil {
+			return res, err
+		}
+		if !p.body.Match(body) {
+			return res, fmt.Errorf("body does not match %q", p.cfg.ExpectBody)
+		}
+	}
+	return res, nil
+}
+
+func (p *Probe) statusExpected(code int) bool {
+	if len(p.cfg.ExpectStatus) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, c := range p.cfg.ExpectStatus {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Probe) probeTCP(ctx context.Context) (result, error) {
+	var res result
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.cfg.Target)
+	if err != nil {
+		return res, err
+	}
+	defer conn.Close()
+	if !p.cfg.TLS {
+		return res, nil
+	}
+
+	host, _, _ := net.SplitHostPort(p.cfg.Target)
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: p.cfg.InsecureSkipVerify})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return res, err
+	}
+	res.certs = tlsConn.ConnectionState().PeerCertificates
+	return res, nil
+}
+
+func earliestExpiry(certs []*x509.Certificate) (time.Time, bool) {
+	var earliest time.Time
+	for _, c := range certs {
+		if earliest.IsZero() || c.NotAfter.Before(earliest) {
+			earliest = c.NotAfter
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
@@ -0,0 +1,111 @@
+package probe_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/agent/config"
+	"github.com/zetcan333/metrics-collector/internal/agent/probe"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+type recorder struct {
+	mu      sync.Mutex
+	metrics []models.Metrics
+}
+
+func (r *recorder) Ingest(metrics []models.Metrics) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, metrics...)
+	return nil
+}
+
+func (r *recorder) byID() map[string]models.Metrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make(map[string]models.Metrics)
+	for _, m := range r.metrics {
+		res[m.ID] = m
+	}
+	return res
+}
+
+func run(t *testing.T, cfg config.Probe) (map[string]models.Metrics, error) {
+	t.Helper()
+	if cfg.Timeout == 0 {
+		cfg.Timeout = config.Duration(time.Second)
+	}
+	r := &recorder{}
+	err := probe.New(cfg, r).Collect(context.Background())
+	return r.byID(), err
+}
+
+func TestHTTPProbe(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer srv.Close()
+
+	m, err := run(t, config.Probe{Name: "api", Type: config.ProbeHTTP, Target: srv.URL, ExpectBody: `"status":"ok"`, InsecureSkipVerify: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, *m[`probe_success{probe="api"}`].Value)
+	assert.Equal(t, 200.0, *m[`probe_http_status_code{probe="api"}`].Value)
+	assert.Greater(t, *m[`probe_duration_seconds{probe="api"}`].Value, 0.0)
+	assert.Greater(t, *m[`probe_tls_cert_expiry_seconds{probe="api"}`].Value, 0.0)
+	assert.NotContains(t, m, `probe_failures_total{probe="api"}`)
+
+	// Неверное тело, неожиданный код и непроверенный сертификат — неудачи
+	for _, cfg := range []config.Probe{
+		{Name: "api", Type: config.ProbeHTTP, Target: srv.URL, ExpectBody: "degraded", InsecureSkipVerify: true},
+		{Name: "api", Type: config.ProbeHTTP, Target: srv.URL + "/missing", InsecureSkipVerify: true},
+		{Name: "api", Type: config.ProbeHTTP, Target: srv.URL},
+	} {
+		m, err := run(t, cfg)
+		require.Error(t, err, cfg.Target)
+		assert.Equal(t, 0.0, *m[`probe_success{probe="api"}`].Value)
+		assert.Equal(t, int64(1), *m[`probe_failures_total{probe="api"}`].Delta)
+	}
+
+	m, err = run(t, config.Probe{Name: "api", Type: config.ProbeHTTP, Target: srv.URL + "/missing", ExpectStatus: []int{404}, InsecureSkipVerify: true})
+	require.NoError(t, err)
+	assert.Equal(t, 404.0, *m[`probe_http_status_code{probe="api"}`].Value)
+}
+
+func TestTCPProbe(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "https://")
+
+	m, err := run(t, config.Probe{Name: "db", Type: config.ProbeTCP, Target: addr})
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, *m[`probe_success{probe="db"}`].Value)
+	assert.NotContains(t, m, `probe_tls_cert_expiry_seconds{probe="db"}`)
+
+	m, err = run(t, config.Probe{Name: "db", Type: config.ProbeTCP, Target: addr, TLS: true, InsecureSkipVerify: true})
+	require.NoError(t, err)
+	assert.Greater(t, *m[`probe_tls_cert_expiry_seconds{probe="db"}`].Value, 0.0)
+
+	// Закрытый порт
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closed := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	m, err = run(t, config.Probe{Name: "db", Type: config.ProbeTCP, Target: closed})
+	require.Error(t, err)
+	assert.Equal(t, 0.0, *m[`probe_success{probe="db"}`].Value)
+	assert.Equal(t, int64(1), *m[`probe_failures_total{probe="db"}`].Delta)
+}
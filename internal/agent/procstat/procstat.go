@@ -0,0 +1,287 @@
+package procstat
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zetcan333/metrics-collector/internal/agent/config"
+	"github.com/zetcan333/metrics-collector/internal/ingest/cumulative"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+// clockTicks — USER_HZ, в котором /proc/<pid>/stat считает процессорное время; на Linux всегда 100
+const clockTicks = 100
+
+const source = "procfs"
+
+// Имена метрик; в режиме Prefix перед ними ставится имя группы и точка
+const (
+	MetricCount      = "process_count"
+	MetricCPU        = "process_cpu_seconds_total"
+	MetricRSS        = "process_resident_memory_bytes"
+	MetricThreads    = "process_threads"
+	MetricFDs        = "process_open_fds"
+	MetricReadBytes  = "process_io_read_bytes_total"
+	MetricWriteBytes = "process_io_write_bytes_total"
+)
+
+var meta = map[string]*models.MetricMeta{
+	MetricCount:      {Unit: "count", Help: "Number of matched processes", Source: source},
+	MetricCPU:        {Unit: "seconds", Help: "User and system CPU time", Source: source},
+	MetricRSS:        {Unit: "bytes", Help: "Resident set size", Source: source},
+	MetricThreads:    {Unit: "count", Help: "Number of threads", Source: source},
+	MetricFDs:        {Unit: "count", Help: "Number of open file descriptors", Source: source},
+	MetricReadBytes:  {Unit: "bytes", Help: "Bytes read from storage", Source: source},
+	MetricWriteBytes: {Unit: "bytes", Help: "Bytes written to storage", Source: source},
+}
+
+// Collector собирает метрики процессов из procfs для групп из конфигурации
+type Collector struct {
+	procRoot string
+	groups   []group
+	counters *cumulative.Counters
+	scanned  bool // первый обход выполнен: процесс, которого тогда не было, запущен позже
+}
+
+type group struct {
+	cfg     config.Procstat
+	cmdline *regexp.Regexp
+}
+
+// New создаёт сборщик; procRoot — точка монтирования procfs, обычно /proc
+func New(procRoot string, groups []config.Procstat) *Collector {
+	c := &Collector{procRoot: procRoot, counters: cumulative.NewCounters()}
+	for _, cfg := range groups {
+		g := group{cfg: cfg}
+		if cfg.Cmdline != "" {
+			g.cmdline = regexp.MustCompile(cfg.Cmdline)
+		}
+		c.groups = append(c.groups, g)
+	}
+	return c
+}
+
+// process — снимок процесса; поля, недоступные по правам, остаются nil
+type process struct {
+	pid        int
+	start      uint64 // время запуска в тиках от загрузки, отличает процесс от переиспользованного pid
+	cpuSeconds float64
+	rss        float64
+	threads    float64
+	fds        *float64
+	readBytes  *float64
+	writeBytes *float64
+}
+
+// Collect возвращает gauge и приращения counter для всех групп. Процессы, завершившиеся
+// во время чтения, пропускаются; ошибки групп собираются, не прерывая остальные.
+func (c *Collector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	var (
+		metrics []models.Metrics
+		errs    []error
+	)
+	for _, g := range c.groups {
+		pids, err := c.match(g)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("procstat %s: %w", g.cfg.Name, err))
+		}
+
+		var procs []process
+		for _, pid := range pids {
+			p, err := c.read(pid)
+			if err != nil {
+				continue
+			}
+			procs = append(procs, p)
+		}
+		metrics = append(metrics, c.metrics(g.cfg, procs)...)
+	}
+	c.scanned = true
+	return metrics, errors.Join(errs...)
+}
+
+func (c *Collector) match(g group) ([]int, error) {
+	if g.cfg.Pidfile != "" {
+		data, err := os.ReadFile(g.cfg.Pidfile)
+		if err != nil {
+			return nil, err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pidfile %s: %w", g.cfg.Pidfile, err)
+		}
+		return []int{pid}, nil
+	}
+
+	entries, err := os.ReadDir(c.procRoot)
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(c.procRoot, e.Name())
+		if g.cmdline != nil {
+			data, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+			if err == nil && g.cmdline.MatchString(strings.TrimSpace(strings.ReplaceAll(string(data), "\x00", " "))) {
+				pids = append(pids, pid)
+			}
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "comm"))
+		if err == nil && strings.TrimSpace(string(data)) == g.cfg.Exe {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+func (c *Collector) read(pid int) (process, error) {
+	dir := filepath.Join(c.procRoot, strconv.Itoa(pid))
+	p := process{pid: pid}
+
+	if err := p.readStat(filepath.Join(dir, "stat")); err != nil {
+		return process{}, err
+	}
+	if err := p.readStatus(filepath.Join(dir, "status")); err != nil {
+		return process{}, err
+	}
+
+	// fd и io другого пользователя без прав не читаются — эти метрики просто не отправляются
+	if entries, err := os.ReadDir(filepath.Join(dir, "fd")); err == nil {
+		n := float64(len(entries))
+		p.fds = &n
+	}
+	if io, err := readKeyValues(filepath.Join(dir, "io")); err == nil {
+		if v, ok := io["read_bytes"]; ok {
+			p.readBytes = &v
+		}
+		if v, ok := io["write_bytes"]; ok {
+			p.writeBytes = &v
+		}
+	}
+	return p, nil
+}
+
+// readStat читает utime, stime и starttime. Имя процесса в скобках может содержать
+// пробелы и скобки, поэтому поля отсчитываются от последней ')'.
+func (p *process) readStat(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return fmt.Errorf("invalid %s", path)
+	}
+	// После ')' идут поля с 3-го: state(3) ... utime(14) stime(15) ... starttime(22)
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 20 {
+		return fmt.Errorf("invalid %s", path)
+	}
+	utime, err1 := strconv.ParseUint(fields[11], 10, 64)
+	stime, err2 := strconv.ParseUint(fields[12], 10, 64)
+	start, err3 := strconv.ParseUint(fields[19], 10, 64)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return fmt.Errorf("invalid %s: %w", path, err)
+	}
+	p.cpuSeconds = float64(utime+stime) / clockTicks
+	p.start = start
+	return nil
+}
+
+func (p *process) readStatus(path string) error {
+	status, err := readKeyValues(path)
+	if err != nil {
+		return err
+	}
+	// VmRSS отсутствует у потоков ядра
+	p.rss = status["VmRSS"] * 1024
+	p.threads = status["Threads"]
+	return nil
+}
+
+// readKeyValues читает строки "ключ: число [единица]"
+func readKeyValues(path string) (map[string]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]float64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		if v, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			values[strings.TrimSpace(key)] = v
+		}
+	}
+	return values, scanner.Err()
+}
+
+func (c *Collector) metrics(cfg config.Procstat, procs []process) []models.Metrics {
+	id := func(name string, pid int) string {
+		labels := map[string]string{}
+		if cfg.Prefix {
+			name = cfg.Name + "." + name
+		} else {
+			labels["process"] = cfg.Name
+		}
+		if pid > 0 {
+			labels["pid"] = strconv.Itoa(pid)
+		}
+		return models.FormatID(name, labels)
+	}
+
+	var metrics []models.Metrics
+	gauge := func(name string, pid int, value float64) {
+		metrics = append(metrics, models.Metrics{ID: id(name, pid), MType: models.Gauge, Value: &value, Meta: meta[name]})
+	}
+	counter := func(name string, p process, value float64) {
+		metricID := id(name, p.pid)
+		// Процесс, появившийся после первого обхода, запущен на наших глазах, и его счётчики
+		// начались с нуля; процессы первого обхода (в том числе после перезапуска агента) — точка отсчёта
+		deltaFn := c.counters.Delta
+		if c.scanned {
+			deltaFn = c.counters.DeltaFromZero
+		}
+		if delta, ok := deltaFn(metricID, p.start, value); ok {
+			metrics = append(metrics, models.Metrics{ID: metricID, MType: models.Counter, Delta: &delta, Meta: meta[name]})
+		}
+	}
+
+	gauge(MetricCount, 0, float64(len(procs)))
+	for _, p := range procs {
+		gauge(MetricRSS, p.pid, p.rss)
+		gauge(MetricThreads, p.pid, p.threads)
+		counter(MetricCPU, p, p.cpuSeconds)
+		if p.fds != nil {
+			gauge(MetricFDs, p.pid, *p.fds)
+		}
+		if p.readBytes != nil {
+			counter(MetricReadBytes, p, *p.readBytes)
+		}
+		if p.writeBytes != nil {
+			counter(MetricWriteBytes, p, *p.writeBytes)
+		}
+	}
+	return metrics
+}
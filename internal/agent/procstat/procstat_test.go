@@ -0,0 +1,97 @@
+package procstat_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/agent/config"
+	"github.com/zetcan333/metrics-collector/internal/agent/procstat"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+// writeProc создаёт /proc/<pid> с utime+stime в тиках и временем запуска start
+func writeProc(t *testing.T, root string, pid int, comm, cmdline string, ticks, start uint64) {
+	t.Helper()
+	dir := filepath.Join(root, strconv.Itoa(pid))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "fd"), 0o755))
+
+	stat := fmt.Sprintf("%d (%s) S 1 1 1 0 -1 4194560 100 0 0 0 %d %d 0 0 20 0 2 0 %d 1000000 500 18446744073709551615\n",
+		pid, comm, ticks, 0, start)
+	files := map[string]string{
+		"stat":    stat,
+		"comm":    comm + "\n",
+		"cmdline": cmdline,
+		"status":  "Name:\t" + comm + "\nVmRSS:\t    2048 kB\nThreads:\t2\n",
+		"io":      "rchar: 100\nread_bytes: 4096\nwrite_bytes: 8192\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	for i := range 3 {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "fd", strconv.Itoa(i)), nil, 0o644))
+	}
+}
+
+func byID(metrics []models.Metrics) map[string]models.Metrics {
+	res := make(map[string]models.Metrics)
+	for _, m := range metrics {
+		res[m.ID] = m
+	}
+	return res
+}
+
+func TestCollect(t *testing.T) {
+	root := t.TempDir()
+	writeProc(t, root, 10, "nginx", "nginx: master\x00", 250, 1000)
+	writeProc(t, root, 11, "nginx", "nginx: worker\x00", 100, 1001)
+	writeProc(t, root, 20, "my app (v2)", "/usr/bin/python3\x00app.py\x00--serve\x00", 50, 2000)
+
+	pidfile := filepath.Join(t.TempDir(), "app.pid")
+	require.NoError(t, os.WriteFile(pidfile, []byte("20\n"), 0o644))
+
+	c := procstat.New(root, []config.Procstat{
+		{Name: "nginx", Exe: "nginx"},
+		{Name: "app", Cmdline: `app\.py --serve`, Prefix: true},
+		{Name: "app_pid", Pidfile: pidfile},
+	})
+
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	m := byID(metrics)
+
+	assert.Equal(t, 2.0, *m[`process_count{process="nginx"}`].Value)
+	assert.Equal(t, 2048.0*1024, *m[`process_resident_memory_bytes{pid="10",process="nginx"}`].Value)
+	assert.Equal(t, 3.0, *m[`process_open_fds{pid="11",process="nginx"}`].Value)
+	assert.NotContains(t, m, `process_cpu_seconds_total{pid="10",process="nginx"}`, "Первый обход — точка отсчёта")
+
+	assert.Equal(t, 1.0, *m[`app.process_count`].Value, "Имя группы как префикс")
+	assert.Equal(t, 2.0, *m[`app.process_threads{pid="20"}`].Value)
+	assert.Equal(t, 1.0, *m[`process_count{process="app_pid"}`].Value)
+
+	// Второй опрос: перезапуск с тем же pid и новый процесс считаются с нуля
+	writeProc(t, root, 10, "nginx", "nginx: master\x00", 400, 1000)
+	writeProc(t, root, 11, "nginx", "nginx: worker\x00", 30, 5000)
+	writeProc(t, root, 12, "nginx", "nginx: worker\x00", 200, 6000)
+	metrics, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	m = byID(metrics)
+
+	assert.Equal(t, int64(1), *m[`process_cpu_seconds_total{pid="10",process="nginx"}`].Delta)
+	assert.NotContains(t, m, `process_io_read_bytes_total{pid="10",process="nginx"}`, "Без изменений приращения нет")
+	assert.Equal(t, int64(8192), *m[`process_io_write_bytes_total{pid="11",process="nginx"}`].Delta)
+	assert.Equal(t, int64(2), *m[`process_cpu_seconds_total{pid="12",process="nginx"}`].Delta)
+
+	// Процесс, уже работавший до перезапуска агента, не отправляет своё процессорное время целиком
+	restarted := procstat.New(root, []config.Procstat{{Name: "nginx", Exe: "nginx"}})
+	metrics, err = restarted.Collect(context.Background())
+	require.NoError(t, err)
+	for _, metric := range metrics {
+		assert.NotEqual(t, models.Counter, metric.MType, metric.ID)
+	}
+}
@@ -0,0 +1,157 @@
+package runtimemetrics
+
+import (
+	"context"
+	"math"
+	"runtime/metrics"
+	"slices"
+	"strings"
+
+	"github.com/zetcan333/metrics-collector/internal/ingest/cumulative"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+const source = "runtime/metrics"
+
+// Collector читает метрики пакета runtime/metrics без остановки мира.
+// Накопительные значения передаются приращениями counter, гистограммы — приращениями корзин.
+type Collector struct {
+	samples  []metrics.Sample
+	names    map[string]string // имя в runtime -> ID метрики
+	meta     map[string]*models.MetricMeta
+	cumul    map[string]bool // накопительные числовые метрики, отправляются как counter
+	counters *cumulative.Counters
+	lastHist map[string][]uint64
+}
+
+// New выбирает метрики, имена которых начинаются с одного из prefixes; пустой список — все
+func New(prefixes []string) *Collector {
+	c := &Collector{
+		names:    make(map[string]string),
+		meta:     make(map[string]*models.MetricMeta),
+		cumul:    make(map[string]bool),
+		counters: cumulative.NewCounters(),
+		lastHist: make(map[string][]uint64),
+	}
+	for _, d := range metrics.All() {
+		if !matches(d.Name, prefixes) {
+			continue
+		}
+		name, unit := MetricName(d.Name)
+		c.samples = append(c.samples, metrics.Sample{Name: d.Name})
+		c.names[d.Name] = name
+		c.meta[d.Name] = &models.MetricMeta{Unit: unit, Help: d.Description, Source: source}
+		c.cumul[d.Name] = d.Cumulative
+	}
+	return c
+}
+
+func matches(name string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// MetricName переводит имя runtime/metrics в ID метрики и единицу. Единица входит в ID,
+// чтобы не совпали /gc/heap/allocs:bytes и /gc/heap/allocs:objects, но не повторяется:
+// "/sched/goroutines:goroutines" -> "go_sched_goroutines", "goroutines"
+func MetricName(name string) (string, string) {
+	path, unit, _ := strings.Cut(name, ":")
+	if !strings.HasSuffix(path, "/"+unit) {
+		path += "/" + unit
+	}
+	id := "go" + strings.Map(func(r rune) rune {
+		if r == '/' || r == '-' || r == '.' || r == '*' {
+			return '_'
+		}
+		return r
+	}, path)
+	return id, unit
+}
+
+func (c *Collector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	metrics.Read(c.samples)
+
+	var result []models.Metrics
+	for _, s := range c.samples {
+		id, meta := c.names[s.Name], c.meta[s.Name]
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			result = c.appendNumber(result, id, meta, float64(s.Value.Uint64()), c.cumul[s.Name])
+		case metrics.KindFloat64:
+			result = c.appendNumber(result, id, meta, s.Value.Float64(), c.cumul[s.Name])
+		case metrics.KindFloat64Histogram:
+			if h := c.histogramDelta(id, s.Value.Float64Histogram()); h != nil {
+				result = append(result, models.Metrics{ID: id, MType: models.Histogram, Histogram: h, Meta: meta})
+			}
+		}
+	}
+	return result, nil
+}
+
+func (c *Collector) appendNumber(result []models.Metrics, id string, meta *models.MetricMeta, value float64, cumulative bool) []models.Metrics {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return result
+	}
+	if !cumulative {
+		return append(result, models.Metrics{ID: id, MType: models.Gauge, Value: &value, Meta: meta})
+	}
+	// Метрики runtime принадлежат самому агенту и начинаются с нуля при его запуске
+	if delta, ok := c.counters.DeltaFromZero(id, 0, value); ok {
+		result = append(result, models.Metrics{ID: id, MType: models.Counter, Delta: &delta, Meta: meta})
+	}
+	return result
+}
+
+// histogramDelta переводит накопительную гистограмму runtime в приращение с прошлого опроса.
+// Корзина runtime [Buckets[i], Buckets[i+1]) соответствует корзине с верхней границей Buckets[i+1].
+// Сумма в runtime/metrics не хранится и оценивается по серединам корзин.
+func (c *Collector) histogramDelta(id string, rh *metrics.Float64Histogram) *models.HistogramData {
+	n := len(rh.Counts)
+	if n == 0 {
+		return nil
+	}
+	bounds := rh.Buckets[1:n]
+	if !math.IsInf(rh.Buckets[n], 1) {
+		bounds = rh.Buckets[1 : n+1]
+	}
+
+	h := &models.HistogramData{Bounds: slices.Clone(bounds), Counts: make([]uint64, len(bounds)+1)}
+	last := c.lastHist[id]
+	for i, count := range rh.Counts {
+		if len(last) == n && count >= last[i] {
+			count -= last[i]
+		}
+		if count == 0 {
+			continue
+		}
+		h.Counts[i] += count
+		h.Count += count
+		h.Sum += float64(count) * bucketMidpoint(rh.Buckets[i], rh.Buckets[i+1])
+	}
+	c.lastHist[id] = append(last[:0], rh.Counts...)
+
+	if h.Count == 0 {
+		return nil
+	}
+	return h
+}
+
+func bucketMidpoint(lo, hi float64) float64 {
+	switch {
+	case math.IsInf(lo, -1) && math.IsInf(hi, 1):
+		return 0
+	case math.IsInf(lo, -1):
+		return hi
+	case math.IsInf(hi, 1):
+		return lo
+	default:
+		return (lo + hi) / 2
+	}
+}
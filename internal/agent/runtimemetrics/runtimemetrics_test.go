@@ -0,0 +1,77 @@
+package runtimemetrics_test
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/agent"
+	"github.com/zetcan333/metrics-collector/internal/agent/runtimemetrics"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+func byID(metrics []models.Metrics) map[string]models.Metrics {
+	res := make(map[string]models.Metrics)
+	for _, m := range metrics {
+		res[m.ID] = m
+	}
+	return res
+}
+
+func TestMetricName(t *testing.T) {
+	name, unit := runtimemetrics.MetricName("/sched/latencies:seconds")
+	assert.Equal(t, "go_sched_latencies_seconds", name)
+	assert.Equal(t, "seconds", unit)
+
+	name, _ = runtimemetrics.MetricName("/sched/goroutines:goroutines")
+	assert.Equal(t, "go_sched_goroutines", name)
+
+	name, _ = runtimemetrics.MetricName("/gc/heap/allocs:objects")
+	assert.Equal(t, "go_gc_heap_allocs_objects", name)
+}
+
+func TestCollect(t *testing.T) {
+	c := runtimemetrics.New([]string{"/sched/goroutines:", "/gc/cycles/total:", "/gc/pauses:"})
+
+	runtime.GC()
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	m := byID(metrics)
+	require.Len(t, m, 3)
+
+	goroutines := m["go_sched_goroutines"]
+	assert.Equal(t, models.Gauge, goroutines.MType)
+	assert.GreaterOrEqual(t, *goroutines.Value, 1.0)
+	assert.Equal(t, "goroutines", goroutines.Meta.Unit)
+	assert.NotEmpty(t, goroutines.Meta.Help)
+
+	cycles := m["go_gc_cycles_total_gc_cycles"]
+	assert.Equal(t, models.Counter, cycles.MType)
+	require.NoError(t, m["go_gc_pauses_seconds"].Histogram.Validate())
+
+	// Во втором опросе только приращения с прошлого
+	runtime.GC()
+	metrics, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	m = byID(metrics)
+	assert.GreaterOrEqual(t, *m["go_gc_cycles_total_gc_cycles"].Delta, int64(1))
+	assert.GreaterOrEqual(t, m["go_gc_pauses_seconds"].Histogram.Count, uint64(1), "За цикл GC бывает несколько пауз")
+}
+
+func TestAgentWithoutMemStats(t *testing.T) {
+	a := agent.NewAgent("http://localhost:8080", time.Minute, time.Minute)
+	a.MemStats = false
+	a.CollectMetrics()
+	assert.Contains(t, a.Metrics, "PollCount")
+	assert.NotContains(t, a.Metrics, "HeapAlloc")
+	assert.NotContains(t, a.Metrics, "GCPauseNs")
+
+	// Все метрики runtime/metrics проходят проверку агента
+	metrics, err := runtimemetrics.New(nil).Collect(context.Background())
+	require.NoError(t, err)
+	assert.Greater(t, len(metrics), 20)
+	require.NoError(t, a.Ingest(metrics))
+}
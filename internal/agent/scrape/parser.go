@@ -0,0 +1,199 @@
+package scrape
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidLine = errors.New("invalid exposition line")
+
+// Типы семейств из строк # TYPE
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+	TypeSummary   = "summary"
+	TypeUntyped   = "untyped"
+)
+
+// Sample — одна строка значения текстового формата Prometheus.
+// Type и Help берутся из комментариев семейства, к которому относится строка.
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	Type   string
+	Help   string
+}
+
+// Суффиксы строк, принадлежащих семейству с другим именем: foo_bucket у гистограммы foo
+// или foo_total у счётчика foo в стиле OpenMetrics
+var familySuffixes = []string{"_total", "_created", "_bucket", "_count", "_sum", "_gcount", "_gsum"}
+
+// Parse разбирает текстовый формат экспозиции Prometheus. Метки времени игнорируются;
+// строки без # TYPE получают тип untyped.
+func Parse(r io.Reader) ([]Sample, error) {
+	var (
+		samples []Sample
+		types   = make(map[string]string)
+		helps   = make(map[string]string)
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			parseComment(line, types, helps)
+			continue
+		}
+
+		s, err := parseSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		family := familyOf(s.Name, types)
+		s.Type = TypeUntyped
+		if t, ok := types[family]; ok {
+			s.Type = t
+		}
+		s.Help = helps[family]
+		samples = append(samples, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// parseComment запоминает # TYPE и # HELP; прочие комментарии пропускаются
+func parseComment(line string, types, helps map[string]string) {
+	fields := strings.SplitN(strings.TrimSpace(line[1:]), " ", 3)
+	if len(fields) < 3 {
+		return
+	}
+	switch fields[0] {
+	case "TYPE":
+		types[fields[1]] = strings.ToLower(strings.TrimSpace(fields[2]))
+	case "HELP":
+		helps[fields[1]] = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(fields[2])
+	}
+}
+
+func familyOf(name string, types map[string]string) string {
+	if _, ok := types[name]; ok {
+		return name
+	}
+	for _, suffix := range familySuffixes {
+		if base, ok := strings.CutSuffix(name, suffix); ok {
+			if _, ok := types[base]; ok {
+				return base
+			}
+		}
+	}
+	return name
+}
+
+// parseSample разбирает строку вида name{key="value",...} value [timestamp]
+func parseSample(line string) (Sample, error) {
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return Sample{}, ErrInvalidLine
+	}
+	s := Sample{Name: line[:end]}
+	if !validName(s.Name) {
+		return Sample{}, fmt.Errorf("%w: bad metric name %q", ErrInvalidLine, s.Name)
+	}
+
+	rest := line[end:]
+	if rest[0] == '{' {
+		labels, tail, err := parseLabels(rest[1:])
+		if err != nil {
+			return Sample{}, err
+		}
+		s.Labels, rest = labels, tail
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return Sample{}, fmt.Errorf("%w: %q", ErrInvalidLine, line)
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Sample{}, fmt.Errorf("%w: bad value %q", ErrInvalidLine, fields[0])
+	}
+	s.Value = v
+	return s, nil
+}
+
+// parseLabels читает метки до закрывающей скобки и возвращает остаток строки
+func parseLabels(s string) (map[string]string, string, error) {
+	labels := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return nil, "", fmt.Errorf("%w: unterminated labels", ErrInvalidLine)
+		}
+		if s[0] == '}' {
+			return labels, s[1:], nil
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, "", fmt.Errorf("%w: bad label", ErrInvalidLine)
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		if key == "" || s == "" || s[0] != '"' {
+			return nil, "", fmt.Errorf("%w: bad label %q", ErrInvalidLine, key)
+		}
+
+		var value strings.Builder
+		i, closed := 1, false
+		for ; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			if c == '"' {
+				closed = true
+				break
+			}
+			value.WriteByte(c)
+		}
+		if !closed {
+			return nil, "", fmt.Errorf("%w: unterminated label value", ErrInvalidLine)
+		}
+		labels[key] = value.String()
+
+		s = strings.TrimLeft(s[i+1:], " \t")
+		if strings.HasPrefix(s, ",") {
+			s = s[1:]
+		}
+	}
+}
+
+func validName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == '_' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+		return false
+	}
+	return true
+}
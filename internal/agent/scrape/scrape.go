@@ -0,0 +1,161 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/agent/config"
+	"github.com/zetcan333/metrics-collector/internal/ingest/cumulative"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+const source = "scrape"
+
+// MetricUp — 1, если последний опрос цели удался; метка scrape с именем цели
+const MetricUp = "scrape_up"
+
+var upMeta = &models.MetricMeta{Help: "Whether the last scrape of the target succeeded", Source: source}
+
+const acceptHeader = "text/plain;version=0.0.4;q=1,*/*;q=0.1"
+
+// Collector опрашивает HTTP-эндпоинты экспортеров Prometheus. Счётчики передаются
+// приращениями, gauge и untyped — значениями; гистограммы и summary пропускаются.
+type Collector struct {
+	targets  []config.Scrape
+	client   *http.Client
+	counters *cumulative.Counters
+}
+
+func New(targets []config.Scrape) *Collector {
+	return &Collector{targets: targets, client: &http.Client{}, counters: cumulative.NewCounters()}
+}
+
+// Collect опрашивает цели параллельно, чтобы медленный экспортер не задерживал остальные
+func (c *Collector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	results := make([][]models.Metrics, len(c.targets))
+	errs := make([]error, len(c.targets))
+
+	var wg sync.WaitGroup
+	for i, target := range c.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			metrics, err := c.scrape(ctx, target)
+			up := 1.0
+			if err != nil {
+				up = 0
+				errs[i] = fmt.Errorf("scrape %s: %w", target.Name, err)
+			}
+			results[i] = append(metrics, models.Metrics{
+				ID:    models.FormatID(MetricUp, map[string]string{"scrape": target.Name}),
+				MType: models.Gauge,
+				Value: &up,
+				Meta:  upMeta,
+			})
+		}()
+	}
+	wg.Wait()
+
+	var metrics []models.Metrics
+	for _, m := range results {
+		metrics = append(metrics, m...)
+	}
+	return metrics, errors.Join(errs...)
+}
+
+func (c *Collector) scrape(ctx context.Context, target config.Scrape) ([]models.Metrics, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(target.Timeout))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", acceptHeader)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	samples, err := Parse(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return c.convert(target, samples), nil
+}
+
+func (c *Collector) convert(target config.Scrape, samples []Sample) []models.Metrics {
+	var metrics []models.Metrics
+	for _, s := range samples {
+		if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+			continue
+		}
+
+		id := metricID(target, s)
+		var m *models.MetricMeta
+		if s.Help != "" {
+			m = &models.MetricMeta{Help: s.Help, Source: source}
+		}
+
+		switch s.Type {
+		case TypeCounter:
+			// _created — время создания счётчика в OpenMetrics, а не значение
+			if strings.HasSuffix(s.Name, "_created") {
+				continue
+			}
+			if delta, ok := c.counters.Delta(target.Name+"|"+id, 0, s.Value); ok {
+				metrics = append(metrics, models.Metrics{ID: id, MType: models.Counter, Delta: &delta, Meta: m})
+			}
+		case TypeGauge, TypeUntyped:
+			value := s.Value
+			metrics = append(metrics, models.Metrics{ID: id, MType: models.Gauge, Value: &value, Meta: m})
+		}
+	}
+	return metrics
+}
+
+// metricID собирает ID с префиксом цели: name{k="v"} или, при FlattenLabels, name_k_v
+func metricID(target config.Scrape, s Sample) string {
+	name := target.Prefix + s.Name
+	if !target.FlattenLabels {
+		return models.FormatID(name, s.Labels)
+	}
+
+	keys := make([]string, 0, len(s.Labels))
+	for key := range s.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	for _, key := range keys {
+		b.WriteByte('_')
+		b.WriteString(key)
+		b.WriteByte('_')
+		b.WriteString(sanitize(s.Labels[key]))
+	}
+	return b.String()
+}
+
+// sanitize заменяет символы, недопустимые в имени метрики, на подчёркивание
+func sanitize(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, value)
+}
@@ -0,0 +1,111 @@
+package scrape_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/agent/config"
+	"github.com/zetcan333/metrics-collector/internal/agent/scrape"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+func byID(metrics []models.Metrics) map[string]models.Metrics {
+	res := make(map[string]models.Metrics)
+	for _, m := range metrics {
+		res[m.ID] = m
+	}
+	return res
+}
+
+func TestParse(t *testing.T) {
+	samples, err := scrape.Parse(strings.NewReader(`# HELP http_requests_total Total requests.
+# TYPE http_requests_total counter
+http_requests_total{method="get",path="/a\"b"} 10 1700000000000
+http_requests_total{ method = "post" , } 2
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 1.5
+temperature NaN
+`))
+	require.NoError(t, err)
+	require.Len(t, samples, 5)
+
+	assert.Equal(t, scrape.Sample{
+		Name:   "http_requests_total",
+		Labels: map[string]string{"method": "get", "path": `/a"b`},
+		Value:  10,
+		Type:   scrape.TypeCounter,
+		Help:   "Total requests.",
+	}, samples[0])
+	assert.Equal(t, map[string]string{"method": "post"}, samples[1].Labels)
+	assert.Equal(t, scrape.TypeHistogram, samples[2].Type)
+	assert.Equal(t, scrape.TypeHistogram, samples[3].Type)
+	assert.Equal(t, scrape.TypeUntyped, samples[4].Type)
+
+	for _, line := range []string{"1bad 1", "name{a=\"b\" 1", "name{a=b} 1", "name", "name x"} {
+		_, err := scrape.Parse(strings.NewReader(line))
+		assert.ErrorIs(t, err, scrape.ErrInvalidLine, line)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	requests := 10
+	exporter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `# TYPE requests_total counter
+requests_total{code="200"} %d
+# TYPE queue_depth gauge
+queue_depth{queue="mail.out"} 7
+# TYPE latency summary
+latency{quantile="0.5"} 0.2
+latency_count 4
+uptime 120
+broken +Inf
+`, requests)
+	}))
+	defer exporter.Close()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	c := scrape.New([]config.Scrape{
+		{Name: "app", URL: exporter.URL, Timeout: config.Duration(time.Second)},
+		{Name: "flat", URL: exporter.URL, Timeout: config.Duration(time.Second), Prefix: "node_", FlattenLabels: true},
+		{Name: "down", URL: down.URL, Timeout: config.Duration(time.Second)},
+	})
+
+	metrics, err := c.Collect(context.Background())
+	require.ErrorContains(t, err, "scrape down")
+	m := byID(metrics)
+
+	assert.NotContains(t, m, `requests_total{code="200"}`, "Первое значение — точка отсчёта")
+	assert.Equal(t, 7.0, *m[`queue_depth{queue="mail.out"}`].Value)
+	assert.Equal(t, 120.0, *m["uptime"].Value)
+	assert.NotContains(t, m, "node_requests_total_code_200")
+	assert.Equal(t, 7.0, *m["node_queue_depth_queue_mail_out"].Value)
+	assert.NotContains(t, m, "latency_count", "summary не передаётся")
+	assert.NotContains(t, m, "broken")
+
+	assert.Equal(t, 1.0, *m[`scrape_up{scrape="app"}`].Value)
+	assert.Equal(t, 0.0, *m[`scrape_up{scrape="down"}`].Value)
+
+	// Счётчики передаются приращениями, сброс экспортера — полным значением
+	requests = 15
+	metrics, err = c.Collect(context.Background())
+	require.Error(t, err)
+	m = byID(metrics)
+	assert.Equal(t, int64(5), *m[`requests_total{code="200"}`].Delta)
+	assert.Equal(t, int64(5), *m["node_requests_total_code_200"].Delta)
+
+	requests = 3
+	metrics, _ = c.Collect(context.Background())
+	assert.Equal(t, int64(3), *byID(metrics)[`requests_total{code="200"}`].Delta)
+}
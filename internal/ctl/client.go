@@ -0,0 +1,268 @@
+package ctl
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	mwAdmin "github.com/zetcan333/metrics-collector/internal/handlers/middleware/admin"
+	"github.com/zetcan333/metrics-collector/internal/lib/retry"
+	"github.com/zetcan333/metrics-collector/internal/lib/sign"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+// maxStreamLine — наибольшая строка события /stream: гистограмма с множеством корзин
+// не помещается в стандартный буфер bufio.Scanner в 64 КБ
+const maxStreamLine = 16 << 20
+
+// Client — HTTP-клиент API сервера метрик
+type Client struct {
+	baseURL    string
+	key        string
+	tenant     string
+	token      string
+	adminToken string
+	gzip       bool
+	http       *http.Client
+}
+
+func NewClient(baseURL, key, tenant, token, adminToken string, gzip bool) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		key:        key,
+		tenant:     tenant,
+		token:      token,
+		adminToken: adminToken,
+		gzip:       gzip,
+		http:       &http.Client{},
+	}
+}
+
+// do отправляет запрос: тело подписывается ключом и при необходимости сжимается
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body []byte) (*http.Response, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	payload := body
+	if c.gzip && body != nil {
+		var err error
+		if payload, err = retry.Compress(body); err != nil {
+			return nil, fmt.Errorf("failed to compress body: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+		if c.gzip {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		if c.key != "" {
+			req.Header.Set(sign.Header, sign.Sum(body, c.key))
+		}
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	if c.tenant != "" {
+		req.Header.Set("X-Tenant-ID", c.tenant)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.adminToken != "" && strings.HasPrefix(path, "/admin/") {
+		req.Header.Set(mwAdmin.HeaderToken, c.adminToken)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := readBody(resp)
+		return nil, fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// readBody читает ответ, распаковывая gzip, если сервер сжал его
+func readBody(resp *http.Response) ([]byte, error) {
+	var r io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress response: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return io.ReadAll(r)
+}
+
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, in, out any) (*http.Response, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	resp, err := c.do(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		data, err := readBody(resp)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, out); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// Get возвращает метрику через POST /value/
+func (c *Client) Get(ctx context.Context, metricType, name string) (models.Metrics, error) {
+	var metric models.Metrics
+	_, err := c.doJSON(ctx, http.MethodPost, "/value/", nil, models.Metrics{ID: name, MType: metricType}, &metric)
+	return metric, err
+}
+
+// Update отправляет одну метрику через POST /update/ и возвращает сохранённое значение
+func (c *Client) Update(ctx context.Context, metric models.Metrics) (models.Metrics, error) {
+	var updated models.Metrics
+	_, err := c.doJSON(ctx, http.MethodPost, "/update/", nil, metric, &updated)
+	return updated, err
+}
+
+// UpdateBatch отправляет метрики пачкой через POST /updates/
+func (c *Client) UpdateBatch(ctx context.Context, metrics []models.Metrics) error {
+	_, err := c.doJSON(ctx, http.MethodPost, "/updates/", nil, metrics, nil)
+	return err
+}
+
+// Replace заменяет все метрики арендатора через POST /admin/import?mode=replace;
+// требует токен администратора
+func (c *Client) Replace(ctx context.Context, metrics []models.Metrics) error {
+	if c.adminToken == "" {
+		return errors.New("admin token is required to replace metrics")
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, metric := range metrics {
+		if err := enc.Encode(metric); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	query := url.Values{"mode": {"replace"}, "format": {"ndjson"}}
+	resp, err := c.do(ctx, http.MethodPost, "/admin/import", query, body.Bytes())
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// List возвращает страницу метрик и курсор следующей страницы
+func (c *Client) List(ctx context.Context, filter models.MetricsFilter) ([]models.Metrics, string, error) {
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"type": filter.Type, "prefix": filter.Prefix, "match": filter.Match, "cursor": filter.Cursor,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", fmt.Sprint(filter.Limit))
+	}
+
+	var metrics []models.Metrics
+	resp, err := c.doJSON(ctx, http.MethodGet, "/api/metrics", query, nil, &metrics)
+	if err != nil {
+		return nil, "", err
+	}
+	return metrics, resp.Header.Get("X-Next-Cursor"), nil
+}
+
+// ListAll проходит по всем страницам выборки
+func (c *Client) ListAll(ctx context.Context, filter models.MetricsFilter, pageSize int) ([]models.Metrics, error) {
+	filter.Limit = pageSize
+	var all []models.Metrics
+	for {
+		page, next, err := c.List(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if next == "" {
+			return all, nil
+		}
+		filter.Cursor = next
+	}
+}
+
+// Watch читает поток /stream и вызывает fn для каждого обновления, пока не отменён ctx
+func (c *Client) Watch(ctx context.Context, metricType, match string, fn func(models.Metrics) error) error {
+	query := url.Values{}
+	if metricType != "" {
+		query.Set("type", metricType)
+	}
+	if match != "" {
+		query.Set("match", match)
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, "/stream", query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var r io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to decompress stream: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var metric models.Metrics
+		if err := json.Unmarshal([]byte(data), &metric); err != nil {
+			return fmt.Errorf("failed to decode update: %w", err)
+		}
+		if err := fn(metric); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("stream interrupted: %w", err)
+	}
+	return nil
+}
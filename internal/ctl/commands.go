@@ -0,0 +1,287 @@
+package ctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/zetcan333/metrics-collector/internal/lib/format/float"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+const (
+	pageSize  = 500
+	batchSize = 500
+)
+
+const usage = `Usage: metricsctl [global flags] <command> [args]
+
+Commands:
+  get <type> <name>               print a metric
+  set <name> <value>              set a gauge
+  inc <name> [delta]              increment a counter (default delta 1)
+  list [--type --prefix --match]  list metrics
+  watch [--type --match]          print updates as they arrive
+  export [file]                   write all metrics as JSON (stdout by default)
+  import [--merge] [file]         restore a JSON export (stdin by default): replaces all
+                                  metrics of the tenant, needs --admin-token; with --merge
+                                  the metrics are sent as updates and counters and
+                                  histograms are ADDED to the stored values
+`
+
+var ErrUsage = errors.New("invalid usage")
+
+// CLI выполняет подкоманды metricsctl
+type CLI struct {
+	client *Client
+	output string
+	out    io.Writer
+	in     io.Reader
+}
+
+func NewCLI(client *Client, output string, out io.Writer, in io.Reader) *CLI {
+	return &CLI{client: client, output: output, out: out, in: in}
+}
+
+func Usage(w io.Writer) {
+	fmt.Fprint(w, usage)
+	fmt.Fprintln(w, "\nGlobal flags:")
+	pflag.CommandLine.SetOutput(w)
+	pflag.PrintDefaults()
+}
+
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: command required", ErrUsage)
+	}
+	if c.output != "table" && c.output != "json" {
+		return fmt.Errorf("%w: unknown output format %q", ErrUsage, c.output)
+	}
+
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "get":
+		return c.get(ctx, args)
+	case "set":
+		return c.set(ctx, args)
+	case "inc":
+		return c.inc(ctx, args)
+	case "list":
+		return c.list(ctx, args)
+	case "watch":
+		return c.watch(ctx, args)
+	case "export":
+		return c.export(ctx, args)
+	case "import":
+		return c.importMetrics(ctx, args)
+	default:
+		return fmt.Errorf("%w: unknown command %q", ErrUsage, cmd)
+	}
+}
+
+func (c *CLI) get(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("%w: get <type> <name>", ErrUsage)
+	}
+	metric, err := c.client.Get(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	return c.print([]models.Metrics{metric})
+}
+
+func (c *CLI) set(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("%w: set <name> <value>", ErrUsage)
+	}
+	value, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid gauge value %q", ErrUsage, args[1])
+	}
+	metric, err := c.client.Update(ctx, models.Metrics{ID: args[0], MType: models.Gauge, Value: &value})
+	if err != nil {
+		return err
+	}
+	return c.print([]models.Metrics{metric})
+}
+
+func (c *CLI) inc(ctx context.Context, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("%w: inc <name> [delta]", ErrUsage)
+	}
+	delta := int64(1)
+	if len(args) == 2 {
+		var err error
+		if delta, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return fmt.Errorf("%w: invalid counter delta %q", ErrUsage, args[1])
+		}
+	}
+	metric, err := c.client.Update(ctx, models.Metrics{ID: args[0], MType: models.Counter, Delta: &delta})
+	if err != nil {
+		return err
+	}
+	return c.print([]models.Metrics{metric})
+}
+
+func (c *CLI) list(ctx context.Context, args []string) error {
+	var filter models.MetricsFilter
+	fs := pflag.NewFlagSet("list", pflag.ContinueOnError)
+	fs.StringVar(&filter.Type, "type", "", "Metric type")
+	fs.StringVar(&filter.Prefix, "prefix", "", "Name prefix")
+	fs.StringVar(&filter.Match, "match", "", "Name glob or re:<regexp>")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+
+	metrics, err := c.client.ListAll(ctx, filter, pageSize)
+	if err != nil {
+		return err
+	}
+	return c.print(metrics)
+}
+
+func (c *CLI) watch(ctx context.Context, args []string) error {
+	var metricType, match string
+	fs := pflag.NewFlagSet("watch", pflag.ContinueOnError)
+	fs.StringVar(&metricType, "type", "", "Comma-separated metric types")
+	fs.StringVar(&match, "match", "", "Name glob")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+
+	enc := json.NewEncoder(c.out)
+	return c.client.Watch(ctx, metricType, match, func(metric models.Metrics) error {
+		if c.output == "json" {
+			return enc.Encode(metric)
+		}
+		_, err := fmt.Fprintf(c.out, "%s\t%s\t%s\t%s\n", timestamp(metric.UpdatedAt), metric.MType, metric.ID, formatValue(metric))
+		return err
+	})
+}
+
+func (c *CLI) export(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("%w: export [file]", ErrUsage)
+	}
+	metrics, err := c.client.ListAll(ctx, models.MetricsFilter{}, pageSize)
+	if err != nil {
+		return err
+	}
+
+	out := c.out
+	if len(args) == 1 {
+		f, err := os.Create(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to create export file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(metrics)
+}
+
+// importMetrics восстанавливает выгрузку export. Экспорт содержит итоги счётчиков, а /updates/
+// считает их дельтами, поэтому по умолчанию метрики арендатора заменяются целиком, а
+// сложение с текущими значениями включается явно флагом --merge.
+func (c *CLI) importMetrics(ctx context.Context, args []string) error {
+	var merge bool
+	fs := pflag.NewFlagSet("import", pflag.ContinueOnError)
+	fs.BoolVar(&merge, "merge", false, "Send metrics as updates: counters and histograms are added to the stored values")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+	args = fs.Args()
+	if len(args) > 1 {
+		return fmt.Errorf("%w: import [--merge] [file]", ErrUsage)
+	}
+	in := c.in
+	if len(args) == 1 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open import file: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var metrics []models.Metrics
+	if err := json.NewDecoder(in).Decode(&metrics); err != nil {
+		return fmt.Errorf("failed to decode import file: %w", err)
+	}
+	for i := range metrics {
+		// Время обновления и квантили вычисляет сервер
+		metrics[i].UpdatedAt = nil
+		if metrics[i].Histogram != nil {
+			metrics[i].Histogram.Quantiles = nil
+		}
+	}
+
+	if !merge {
+		if err := c.client.Replace(ctx, metrics); err != nil {
+			return fmt.Errorf("failed to import metrics: %w", err)
+		}
+		fmt.Fprintf(c.out, "imported %d metrics\n", len(metrics))
+		return nil
+	}
+
+	for start := 0; start < len(metrics); start += batchSize {
+		end := min(start+batchSize, len(metrics))
+		if err := c.client.UpdateBatch(ctx, metrics[start:end]); err != nil {
+			return fmt.Errorf("failed to import metrics %d-%d: %w", start, end-1, err)
+		}
+	}
+	fmt.Fprintf(c.out, "imported %d metrics\n", len(metrics))
+	return nil
+}
+
+func (c *CLI) print(metrics []models.Metrics) error {
+	if c.output == "json" {
+		enc := json.NewEncoder(c.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(metrics)
+	}
+
+	tw := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tVALUE\tUPDATED")
+	for _, metric := range metrics {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", metric.ID, metric.MType, formatValue(metric), timestamp(metric.UpdatedAt))
+	}
+	return tw.Flush()
+}
+
+func formatValue(metric models.Metrics) string {
+	switch {
+	case metric.Value != nil:
+		return float.FormatFloat(*metric.Value)
+	case metric.Delta != nil:
+		return strconv.FormatInt(*metric.Delta, 10)
+	case metric.Histogram != nil:
+		h := metric.Histogram
+		s := fmt.Sprintf("count=%d sum=%s", h.Count, float.FormatFloat(h.Sum))
+		if v, ok := h.Quantiles["0.5"]; ok {
+			s += " p50=" + float.FormatFloat(v)
+		}
+		if v, ok := h.Quantiles["0.99"]; ok {
+			s += " p99=" + float.FormatFloat(v)
+		}
+		return s
+	default:
+		return ""
+	}
+}
+
+func timestamp(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
@@ -0,0 +1,136 @@
+package ctl_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/ctl"
+	"github.com/zetcan333/metrics-collector/internal/lib/sign"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+func TestImportSignedBatches(t *testing.T) {
+	var batches []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/updates/", r.URL.Path)
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "teamA", r.Header.Get("X-Tenant-ID"))
+
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		assert.Equal(t, sign.Sum(body, "secret"), r.Header.Get(sign.Header))
+
+		var metrics []models.Metrics
+		require.NoError(t, json.Unmarshal(body, &metrics))
+		batches = append(batches, len(metrics))
+	}))
+	defer srv.Close()
+
+	metrics := make([]models.Metrics, 1200)
+	for i := range metrics {
+		v := float64(i)
+		metrics[i] = models.Metrics{ID: fmt.Sprintf("m%d", i), MType: models.Gauge, Value: &v}
+	}
+	input, err := json.Marshal(metrics)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	cli := ctl.NewCLI(ctl.NewClient(srv.URL, "secret", "teamA", "", "", true), "table", &out, bytes.NewReader(input))
+	require.NoError(t, cli.Run(context.Background(), []string{"import", "--merge"}))
+
+	assert.Equal(t, []int{500, 500, 200}, batches)
+	assert.Equal(t, "imported 1200 metrics\n", out.String())
+}
+
+func TestImportReplacesByDefault(t *testing.T) {
+	var got []models.Metrics
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/admin/import", r.URL.Path)
+		assert.Equal(t, "replace", r.URL.Query().Get("mode"))
+		assert.Equal(t, "admin", r.Header.Get("X-Admin-Token"))
+
+		dec := json.NewDecoder(r.Body)
+		for dec.More() {
+			var metric models.Metrics
+			require.NoError(t, dec.Decode(&metric))
+			got = append(got, metric)
+		}
+	}))
+	defer srv.Close()
+
+	input := `[{"id":"Hits","type":"counter","delta":7}]`
+
+	// Без токена администратора замена невозможна, а сложить итоги молча нельзя
+	cli := ctl.NewCLI(ctl.NewClient(srv.URL, "", "", "", "", false), "table", io.Discard, strings.NewReader(input))
+	require.Error(t, cli.Run(context.Background(), []string{"import"}))
+
+	var out bytes.Buffer
+	cli = ctl.NewCLI(ctl.NewClient(srv.URL, "", "", "", "admin", false), "table", &out, strings.NewReader(input))
+	require.NoError(t, cli.Run(context.Background(), []string{"import"}))
+
+	require.Len(t, got, 1)
+	assert.Equal(t, int64(7), *got[0].Delta)
+	assert.Equal(t, "imported 1 metrics\n", out.String())
+}
+
+func TestWatchLongEvent(t *testing.T) {
+	// Гистограмма с тысячами корзин даёт строку события больше 64 КБ
+	bounds := make([]float64, 20000)
+	for i := range bounds {
+		bounds[i] = float64(i)
+	}
+	data, err := json.Marshal(models.Metrics{ID: "Latency", MType: models.Histogram, Histogram: &models.HistogramData{Bounds: bounds}})
+	require.NoError(t, err)
+	require.Greater(t, len(data), 64*1024)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}))
+	defer srv.Close()
+
+	var ids []string
+	err = ctl.NewClient(srv.URL, "", "", "", "", false).Watch(context.Background(), "", "", func(metric models.Metrics) error {
+		ids = append(ids, metric.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Latency"}, ids)
+}
+
+func TestListFollowsCursor(t *testing.T) {
+	pages := map[string]string{
+		"":  `[{"id":"A","type":"counter","delta":1}]`,
+		"A": `[{"id":"B","type":"gauge","value":0.5}]`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cursor := r.URL.Query().Get("cursor")
+		if cursor == "" {
+			w.Header().Set("X-Next-Cursor", "A")
+		}
+		w.Write([]byte(pages[cursor]))
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	cli := ctl.NewCLI(ctl.NewClient(srv.URL, "", "", "", "", false), "table", &out, nil)
+	require.NoError(t, cli.Run(context.Background(), []string{"list"}))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[1], "A")
+	assert.Contains(t, lines[2], "0.5")
+
+	assert.ErrorIs(t, cli.Run(context.Background(), []string{"nope"}), ctl.ErrUsage)
+}
@@ -3,9 +3,11 @@ package flags
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/pflag"
+	"github.com/zetcan333/metrics-collector/internal/models"
 )
 
 type AgentFlags struct {
@@ -13,15 +15,55 @@ type AgentFlags struct {
 	PollInterval   time.Duration
 	ReportInterval time.Duration
 	Key            string
+	IngestHTTP     string
+	IngestUDP      string
+	ConfigFile     string
+}
+
+// CtlFlags — глобальные флаги metricsctl; Args — подкоманда и её аргументы
+type CtlFlags struct {
+	ServerURL  string
+	Key        string
+	Tenant     string
+	Token      string
+	AdminToken string
+	Output     string
+	Gzip       bool
+	Args       []string
 }
 
 type ServerFlags struct {
-	Address         string
-	StoreInterval   time.Duration
-	FileStoragePath string
-	Restore         bool
-	DataBaseDSN     string
-	Key             string
+	Address           string
+	StoreInterval     time.Duration
+	FileStoragePath   string
+	Restore           bool
+	DataBaseDSN       string
+	Key               string
+	TenantsFile       string
+	AllowAnonymous    bool
+	AdminToken        string
+	MetricsTTL        time.Duration
+	TTLSweepPeriod    time.Duration
+	HistBuckets       []float64
+	AlertRules        string
+	AlertInterval     time.Duration
+	AlertWebhooks     []string
+	RelayUpstreams    []string
+	RelayKey          string
+	RelayTokensFile   string
+	RelayDC           string
+	RelayQueueSize    int
+	RelayBatchSize    int
+	RelayInterval     time.Duration
+	StatsdUDP         string
+	StatsdTCP         string
+	StatsdFlush       time.Duration
+	InfluxCounters    bool
+	OTLPPrefixAttr    string
+	PromCounters      []string
+	GraphiteAddr      string
+	GraphiteTemplates string
+	GraphiteCounters  []string
 }
 
 const (
@@ -33,6 +75,15 @@ const (
 	defaultRestore         = false
 	defaultDataBaseDSN     = ""
 	defaultKey             = ""
+	defaultTenantsFile     = ""
+	defaultMetricsTTLSec   = 0
+	defaultTTLSweepSec     = 60
+	defaultAlertRules      = ""
+	defaultAlertSec        = 15
+	defaultRelayQueueSize  = 10000
+	defaultRelayBatchSize  = 500
+	defaultRelaySec        = 5
+	defaultStatsdFlushSec  = 10
 )
 
 func NewAgentFlags() *AgentFlags {
@@ -41,6 +92,9 @@ func NewAgentFlags() *AgentFlags {
 	pollSecPtr := pflag.IntP("p", "p", getEnvOrDefaultInt("POLL_INTERVAL", defaultPollSec), "Set poll interval")
 	reportSecPtr := pflag.IntP("r", "r", getEnvOrDefaultInt("REPORT_INTERVAL", defaultReportSec), "Set report interval")
 	keyPtr := pflag.StringP("k", "k", getEnvOrDefaultString("KEY", defaultKey), "Set key")
+	ingestHTTPPtr := pflag.String("ingest-http", getEnvOrDefaultString("INGEST_HTTP_ADDRESS", ""), "Local HTTP address (e.g. 127.0.0.1:8081) accepting metrics from co-located apps, empty disables")
+	ingestUDPPtr := pflag.String("ingest-udp", getEnvOrDefaultString("INGEST_UDP_ADDRESS", ""), "Local UDP address accepting JSON metrics from co-located apps, empty disables")
+	configPtr := pflag.StringP("c", "c", getEnvOrDefaultString("CONFIG", ""), "Path to the agent JSON config with additional collectors")
 
 	pflag.Parse() // Парсим все флаги разом
 
@@ -50,6 +104,9 @@ func NewAgentFlags() *AgentFlags {
 		PollInterval:   time.Duration(*pollSecPtr) * time.Second,
 		ReportInterval: time.Duration(*reportSecPtr) * time.Second,
 		Key:            *keyPtr,
+		IngestHTTP:     *ingestHTTPPtr,
+		IngestUDP:      *ingestUDPPtr,
+		ConfigFile:     *configPtr,
 	}
 }
 
@@ -61,17 +118,101 @@ func NewServerFlags() *ServerFlags {
 	restorePtr := pflag.BoolP("r", "r", getEnvOrDefaultBool("RESTORE", defaultRestore), "Use for load db from file")
 	dbDSNPtr := pflag.StringP("d", "d", getEnvOrDefaultString("DATABASE_DSN", defaultDataBaseDSN), "Connect postgres via DSN")
 	keyPtr := pflag.StringP("k", "k", getEnvOrDefaultString("KEY", defaultKey), "Set key")
+	tenantsFilePtr := pflag.String("tenants-file", getEnvOrDefaultString("TENANTS_FILE", defaultTenantsFile), "File with \"<token> <tenant>\" lines, enables token-based tenants. Without it the tenant is taken from X-Tenant-ID or ?tenant= unauthenticated, so any client can write to any tenant; set it for multi-tenant deployments")
+	adminTokenPtr := pflag.String("admin-token", getEnvOrDefaultString("ADMIN_TOKEN", ""), "Token required in the X-Admin-Token header for /admin/export and /admin/import, empty disables these routes")
+	allowAnonymousPtr := pflag.Bool("allow-anonymous", getEnvOrDefaultBool("ALLOW_ANONYMOUS", false), "With -tenants-file, serve requests without a bearer token as the default tenant instead of rejecting them with 401")
+
+	metricsTTLSecPtr := pflag.Int("metrics-ttl", getEnvOrDefaultInt("METRICS_TTL", defaultMetricsTTLSec), "Delete metrics not updated for this many seconds, 0 disables")
+	histBucketsPtr := pflag.Float64Slice("histogram-buckets", getEnvOrDefaultFloats("HISTOGRAM_BUCKETS", models.DefaultHistogramBounds), "Default upper bounds of histogram buckets")
+	alertRulesPtr := pflag.String("alert-rules", getEnvOrDefaultString("ALERT_RULES", defaultAlertRules), "File with alerting rules, empty disables alerting")
+	alertSecPtr := pflag.Int("alert-interval", getEnvOrDefaultInt("ALERT_INTERVAL", defaultAlertSec), "Alerting rules evaluation interval in seconds")
+	alertWebhooksPtr := pflag.StringSlice("alert-webhooks", getEnvOrDefaultStrings("ALERT_WEBHOOKS", nil), "Comma-separated webhook URLs for alert notifications")
+	ttlSweepSecPtr := pflag.Int("ttl-sweep-interval", getEnvOrDefaultInt("TTL_SWEEP_INTERVAL", defaultTTLSweepSec), "Interval in seconds between stale metrics sweeps")
+	relayUpstreamsPtr := pflag.StringSlice("relay-upstreams", getEnvOrDefaultStrings("RELAY_UPSTREAMS", nil), "Comma-separated upstream collectors to forward accepted metrics to")
+	relayKeyPtr := pflag.String("relay-key", getEnvOrDefaultString("RELAY_KEY", defaultKey), "Key for signing requests to upstream collectors")
+	relayTokensPtr := pflag.String("relay-tokens-file", getEnvOrDefaultString("RELAY_TOKENS_FILE", ""), "File with \"<token> <tenant>\" lines for upstreams running with -tenants-file; tenants without a token are not forwarded. Without it the tenant is sent only as X-Tenant-ID, which token-mode upstreams ignore")
+	relayDCPtr := pflag.String("relay-dc", getEnvOrDefaultString("RELAY_DC", ""), "Value of the dc label added to forwarded metrics, empty disables")
+	relayQueuePtr := pflag.Int("relay-queue-size", getEnvOrDefaultInt("RELAY_QUEUE_SIZE", defaultRelayQueueSize), "Max updates buffered per upstream, excess is dropped")
+	relayBatchPtr := pflag.Int("relay-batch-size", getEnvOrDefaultInt("RELAY_BATCH_SIZE", defaultRelayBatchSize), "Max metrics per forwarded batch")
+	relaySecPtr := pflag.Int("relay-interval", getEnvOrDefaultInt("RELAY_INTERVAL", defaultRelaySec), "Interval in seconds between forwarded batches")
+	statsdUDPPtr := pflag.String("statsd-udp", getEnvOrDefaultString("STATSD_UDP_ADDRESS", ""), "UDP address of the StatsD listener, empty disables")
+	statsdTCPPtr := pflag.String("statsd-tcp", getEnvOrDefaultString("STATSD_TCP_ADDRESS", ""), "TCP address of the StatsD listener, empty disables")
+	statsdFlushSecPtr := pflag.Int("statsd-flush-interval", getEnvOrDefaultInt("STATSD_FLUSH_INTERVAL", defaultStatsdFlushSec), "Interval in seconds between writes of aggregated StatsD metrics")
+	influxCountersPtr := pflag.Bool("influx-counter-mode", getEnvOrDefaultBool("INFLUX_COUNTER_MODE", false), "Store integer line protocol fields (i suffix) as counters instead of gauges; values are treated as cumulative totals, like Telegraf sends them")
+	otlpPrefixAttrPtr := pflag.String("otlp-prefix-attribute", getEnvOrDefaultString("OTLP_PREFIX_ATTRIBUTE", ""), "OTLP resource attribute whose value prefixes metric names (e.g. service.name), empty keeps all attributes as labels")
+	promCountersPtr := pflag.StringSlice("remote-write-counters", getEnvOrDefaultStrings("REMOTE_WRITE_COUNTERS", nil), "Comma-separated name patterns (glob or re:<regexp>) of remote write series stored as counters, e.g. *_total")
+	graphiteAddrPtr := pflag.String("graphite-address", getEnvOrDefaultString("GRAPHITE_ADDRESS", ""), "TCP address of the Graphite plaintext listener, empty disables")
+	graphiteTmplPtr := pflag.String("graphite-templates", getEnvOrDefaultString("GRAPHITE_TEMPLATES", ""), "Path to a file with Graphite templates extracting tags from metric paths")
+	graphiteCounterPtr := pflag.StringSlice("graphite-counters", getEnvOrDefaultStrings("GRAPHITE_COUNTERS", nil), "Comma-separated name patterns (glob or re:<regexp>) of Graphite metrics stored as counter increments")
 
 	pflag.Parse()
 
 	return &ServerFlags{
-		Address:         *addrPtr,
-		StoreInterval:   time.Duration(*storeSecPtr) * time.Second,
-		FileStoragePath: *filePathPtr,
-		Restore:         *restorePtr,
-		DataBaseDSN:     *dbDSNPtr,
-		Key:             *keyPtr,
+		Address:           *addrPtr,
+		StoreInterval:     time.Duration(*storeSecPtr) * time.Second,
+		FileStoragePath:   *filePathPtr,
+		Restore:           *restorePtr,
+		DataBaseDSN:       *dbDSNPtr,
+		Key:               *keyPtr,
+		TenantsFile:       *tenantsFilePtr,
+		AllowAnonymous:    *allowAnonymousPtr,
+		AdminToken:        *adminTokenPtr,
+		MetricsTTL:        time.Duration(*metricsTTLSecPtr) * time.Second,
+		TTLSweepPeriod:    time.Duration(*ttlSweepSecPtr) * time.Second,
+		HistBuckets:       *histBucketsPtr,
+		AlertRules:        *alertRulesPtr,
+		AlertInterval:     time.Duration(*alertSecPtr) * time.Second,
+		AlertWebhooks:     *alertWebhooksPtr,
+		RelayUpstreams:    *relayUpstreamsPtr,
+		RelayKey:          *relayKeyPtr,
+		RelayTokensFile:   *relayTokensPtr,
+		RelayDC:           *relayDCPtr,
+		RelayQueueSize:    *relayQueuePtr,
+		RelayBatchSize:    *relayBatchPtr,
+		RelayInterval:     time.Duration(*relaySecPtr) * time.Second,
+		StatsdUDP:         *statsdUDPPtr,
+		StatsdTCP:         *statsdTCPPtr,
+		StatsdFlush:       time.Duration(*statsdFlushSecPtr) * time.Second,
+		InfluxCounters:    *influxCountersPtr,
+		OTLPPrefixAttr:    *otlpPrefixAttrPtr,
+		PromCounters:      *promCountersPtr,
+		GraphiteAddr:      *graphiteAddrPtr,
+		GraphiteTemplates: *graphiteTmplPtr,
+		GraphiteCounters:  *graphiteCounterPtr,
+	}
+}
+
+func NewCtlFlags() *CtlFlags {
+	addrPtr := pflag.StringP("a", "a", getEnvOrDefaultString("ADDRESS", defaultAddress), "Address and port of the server or its base URL (http:// is added when the scheme is missing)")
+	keyPtr := pflag.StringP("k", "k", getEnvOrDefaultString("KEY", defaultKey), "Key for HMAC-SHA256 request signing")
+	tenantPtr := pflag.String("tenant", getEnvOrDefaultString("TENANT", ""), "Tenant ID (X-Tenant-ID header)")
+	tokenPtr := pflag.String("token", getEnvOrDefaultString("TOKEN", ""), "Bearer token of the tenant")
+	adminTokenPtr := pflag.String("admin-token", getEnvOrDefaultString("ADMIN_TOKEN", ""), "Server admin token (X-Admin-Token header), required by import without --merge")
+	outputPtr := pflag.StringP("o", "o", getEnvOrDefaultString("OUTPUT", "table"), "Output format: table or json")
+	gzipPtr := pflag.Bool("gzip", getEnvOrDefaultBool("GZIP", true), "Compress request bodies")
+
+	// Флаги после имени подкоманды разбирает сама подкоманда
+	pflag.CommandLine.SetInterspersed(false)
+	pflag.Parse()
+
+	return &CtlFlags{
+		ServerURL:  withScheme(*addrPtr),
+		Key:        *keyPtr,
+		Tenant:     *tenantPtr,
+		Token:      *tokenPtr,
+		AdminToken: *adminTokenPtr,
+		Output:     *outputPtr,
+		Gzip:       *gzipPtr,
+		Args:       pflag.Args(),
+	}
+}
+
+// withScheme дополняет адрес вида host:port схемой http://, URL со схемой не меняется
+func withScheme(addr string) string {
+	if strings.Contains(addr, "://") {
+		return addr
 	}
+	return "http://" + addr
 }
 
 func getEnvOrDefaultString(envVar string, defaultValue string) string {
@@ -90,6 +231,36 @@ func getEnvOrDefaultBool(envVar string, defaultValue bool) bool {
 	return defaultValue
 }
 
+func getEnvOrDefaultStrings(envVar string, defaultValue []string) []string {
+	value, ok := os.LookupEnv(envVar)
+	if !ok {
+		return defaultValue
+	}
+	var parsed []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parsed = append(parsed, part)
+		}
+	}
+	return parsed
+}
+
+func getEnvOrDefaultFloats(envVar string, defaultValue []float64) []float64 {
+	value, ok := os.LookupEnv(envVar)
+	if !ok {
+		return defaultValue
+	}
+	var parsed []float64
+	for _, part := range strings.Split(value, ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return defaultValue
+		}
+		parsed = append(parsed, f)
+	}
+	return parsed
+}
+
 func getEnvOrDefaultInt(envVar string, defaultValue int) int {
 	if value, ok := os.LookupEnv(envVar); ok {
 		if parsedValue, err := strconv.Atoi(value); err == nil {
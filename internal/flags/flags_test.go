@@ -0,0 +1,34 @@
+package flags_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/zetcan333/metrics-collector/internal/flags"
+)
+
+func TestNewCtlFlagsServerURL(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{addr: "localhost:8080", want: "http://localhost:8080"},
+		{addr: "https://metrics.example.com:8443", want: "https://metrics.example.com:8443"},
+		{addr: "http://10.0.0.1:8080/", want: "http://10.0.0.1:8080/"},
+	}
+
+	args := os.Args
+	t.Cleanup(func() { os.Args = args })
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			pflag.CommandLine = pflag.NewFlagSet("metricsctl", pflag.ContinueOnError)
+			os.Args = []string{"metricsctl", "-a", tt.addr, "list", "-t", "gauge"}
+
+			f := flags.NewCtlFlags()
+			assert.Equal(t, tt.want, f.ServerURL)
+			assert.Equal(t, []string{"list", "-t", "gauge"}, f.Args)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/internal/usecase/transfer"
+	"github.com/zetcan333/metrics-collector/pkg/myerrors"
+	"go.uber.org/zap"
+)
+
+// maxImportSize ограничивает тело импорта: файл целиком декодируется в память перед записью
+const maxImportSize = 64 << 20
+
+type Transfer interface {
+	Export(ctx context.Context, fn func(models.Metrics) error) error
+	Import(ctx context.Context, metrics []models.Metrics, mode transfer.Mode) (int, error)
+}
+
+type AdminHandler struct {
+	log      *zap.Logger
+	transfer Transfer
+}
+
+func New(log *zap.Logger, t Transfer) *AdminHandler {
+	return &AdminHandler{log: log, transfer: t}
+}
+
+// Export потоково отдаёт все метрики арендатора: ?format=ndjson (по умолчанию) или csv
+func (h *AdminHandler) Export(w http.ResponseWriter, r *http.Request) {
+	format, err := requestFormat(r, r.Header.Get("Accept"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var enc encoder
+	if format == formatCSV {
+		w.Header().Set("Content-Type", "text/csv")
+		enc = newCSVEncoder(w)
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc = newNDJSONEncoder(w)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=metrics.%s", format))
+
+	// После первой записи статус уже отправлен, поэтому ошибку можно только залогировать
+	err = h.transfer.Export(r.Context(), enc.Encode)
+	if err == nil {
+		err = enc.Flush()
+	}
+	if err != nil {
+		h.log.Sugar().Errorln("failed to export metrics", zap.Error(err))
+	}
+}
+
+// Import принимает NDJSON или CSV (по ?format= или Content-Type) и пишет метрики;
+// ?mode=merge (по умолчанию) или replace. Тело ограничено maxImportSize.
+// Пустой импорт в режиме replace отклоняется, чтобы не стереть арендатора по ошибке.
+func (h *AdminHandler) Import(w http.ResponseWriter, r *http.Request) {
+	format, err := requestFormat(r, r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mode := transfer.Mode(r.URL.Query().Get("mode"))
+	switch mode {
+	case "":
+		mode = transfer.ModeMerge
+	case transfer.ModeMerge, transfer.ModeReplace:
+	default:
+		http.Error(w, "invalid mode, expected merge or replace", http.StatusBadRequest)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportSize)
+	var metrics []models.Metrics
+	if format == formatCSV {
+		metrics, err = decodeCSV(body)
+	} else {
+		metrics, err = decodeNDJSON(body)
+	}
+	if err != nil {
+		if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+			http.Error(w, fmt.Sprintf("import data exceeds %d bytes", maxErr.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid import data: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	written, err := h.transfer.Import(r.Context(), metrics, mode)
+	if err != nil {
+		if isBadRequest(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.log.Sugar().Errorln("failed to import metrics", zap.Error(err))
+		http.Error(w, fmt.Sprintf("import failed after %d metrics", written), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Imported %d metrics\n", written)
+}
+
+func requestFormat(r *http.Request, contentType string) (string, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		if strings.Contains(contentType, "csv") {
+			return formatCSV, nil
+		}
+		return formatNDJSON, nil
+	}
+	if format != formatNDJSON && format != formatCSV {
+		return "", errors.New("invalid format, expected ndjson or csv")
+	}
+	return format, nil
+}
+
+func isBadRequest(err error) bool {
+	return errors.Is(err, transfer.ErrEmptyReplace) ||
+		errors.Is(err, myerrors.ErrInvalidMetricType) ||
+		errors.Is(err, myerrors.ErrInvalidGaugeValue) ||
+		errors.Is(err, myerrors.ErrInvalidCounterValue) ||
+		errors.Is(err, myerrors.ErrInvalidHistogramValue)
+}
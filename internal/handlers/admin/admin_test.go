@@ -0,0 +1,107 @@
+package admin_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/handlers/admin"
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+	"github.com/zetcan333/metrics-collector/internal/lib/zapdiscard"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/internal/repo/storage/mem"
+	"github.com/zetcan333/metrics-collector/internal/usecase"
+	"github.com/zetcan333/metrics-collector/internal/usecase/transfer"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	teamA := tenant.WithTenant(ctx, "teamA")
+	uc := usecase.NewSeverUsecase(mem.NewStorage())
+	h := admin.New(zapdiscard.NewDiscardLogger(), transfer.NewTransferUsecase(uc))
+
+	require.NoError(t, uc.UpdateMetric(ctx, "gauge", "Alloc", "0.000123"))
+	require.NoError(t, uc.UpdateMetric(ctx, "counter", "Polls", "5"))
+	require.NoError(t, uc.UpdateMetric(ctx, "histogram", "Latency", "0.3"))
+	require.NoError(t, uc.UpdateMetric(teamA, "counter", "Stale", "1"))
+
+	for _, format := range []string{"csv", "ndjson"} {
+		t.Run(format, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.Export(rr, httptest.NewRequest(http.MethodGet, "/admin/export?format="+format, nil))
+			require.Equal(t, http.StatusOK, rr.Code)
+			exported := rr.Body.String()
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/import?mode=replace&format="+format, strings.NewReader(exported))
+			rr = httptest.NewRecorder()
+			h.Import(rr, req.WithContext(teamA))
+			require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+			assert.Equal(t, "Imported 3 metrics\n", rr.Body.String())
+
+			list, _, err := uc.ListMetrics(teamA, models.MetricsFilter{})
+			require.NoError(t, err)
+			require.Len(t, list, 3)
+			assert.Equal(t, "Alloc", list[0].ID)
+			assert.Equal(t, 0.000123, *list[0].Value)
+			assert.Equal(t, uint64(1), list[1].Histogram.Count)
+			assert.Equal(t, int64(5), *list[2].Delta)
+
+			// merge прибавляет счётчики к сохранённым значениям
+			req = httptest.NewRequest(http.MethodPost, "/admin/import?format="+format, strings.NewReader(exported))
+			rr = httptest.NewRecorder()
+			h.Import(rr, req.WithContext(teamA))
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			polls, err := uc.GetMetric(teamA, "counter", "Polls")
+			require.NoError(t, err)
+			assert.Equal(t, "10", polls)
+		})
+	}
+}
+
+func TestImportInvalidKeepsMetrics(t *testing.T) {
+	ctx := context.Background()
+	uc := usecase.NewSeverUsecase(mem.NewStorage())
+	h := admin.New(zapdiscard.NewDiscardLogger(), transfer.NewTransferUsecase(uc))
+	require.NoError(t, uc.UpdateMetric(ctx, "gauge", "Alloc", "1"))
+
+	body := `{"id":"A","type":"gauge","value":1}
+{"id":"B","type":"gauge"}
+`
+	rr := httptest.NewRecorder()
+	h.Import(rr, httptest.NewRequest(http.MethodPost, "/admin/import?mode=replace", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	h.Import(rr, httptest.NewRequest(http.MethodPost, "/admin/import?mode=wipe", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	value, err := uc.GetMetric(ctx, "gauge", "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+}
+
+func TestImportEmptyReplaceKeepsMetrics(t *testing.T) {
+	ctx := context.Background()
+	uc := usecase.NewSeverUsecase(mem.NewStorage())
+	h := admin.New(zapdiscard.NewDiscardLogger(), transfer.NewTransferUsecase(uc))
+	require.NoError(t, uc.UpdateMetric(ctx, "gauge", "Alloc", "1"))
+
+	for _, format := range []string{"ndjson", "csv"} {
+		body := ""
+		if format == "csv" {
+			body = "id,type,value,delta,histogram,unit,help,source,updated_at\n"
+		}
+		rr := httptest.NewRecorder()
+		h.Import(rr, httptest.NewRequest(http.MethodPost, "/admin/import?mode=replace&format="+format, strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, format)
+	}
+
+	value, err := uc.GetMetric(ctx, "gauge", "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+}
@@ -0,0 +1,181 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/lib/format/float"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+const (
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+)
+
+var csvHeader = []string{"id", "type", "value", "delta", "histogram", "unit", "help", "source", "updated_at"}
+
+// encoder пишет метрики в выбранном формате
+type encoder interface {
+	Encode(metric models.Metrics) error
+	Flush() error
+}
+
+type ndjsonEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONEncoder(w io.Writer) *ndjsonEncoder {
+	bw := bufio.NewWriter(w)
+	return &ndjsonEncoder{w: bw, enc: json.NewEncoder(bw)}
+}
+
+func (e *ndjsonEncoder) Encode(metric models.Metrics) error {
+	return e.enc.Encode(metric)
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) Encode(metric models.Metrics) error {
+	if !e.header {
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+		e.header = true
+	}
+
+	record := make([]string, len(csvHeader))
+	record[0], record[1] = metric.ID, metric.MType
+	if metric.Value != nil {
+		record[2] = float.FormatFloat(*metric.Value)
+	}
+	if metric.Delta != nil {
+		record[3] = strconv.FormatInt(*metric.Delta, 10)
+	}
+	if metric.Histogram != nil {
+		h := *metric.Histogram
+		h.Quantiles = nil
+		data, err := json.Marshal(h)
+		if err != nil {
+			return err
+		}
+		record[4] = string(data)
+	}
+	if metric.Meta != nil {
+		record[5], record[6], record[7] = metric.Meta.Unit, metric.Meta.Help, metric.Meta.Source
+	}
+	if metric.UpdatedAt != nil {
+		record[8] = metric.UpdatedAt.Format(time.RFC3339Nano)
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func decodeNDJSON(r io.Reader) ([]models.Metrics, error) {
+	var metrics []models.Metrics
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var metric models.Metrics
+		if err := dec.Decode(&metric); err == io.EOF {
+			return metrics, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("record %d: %w", line, err)
+		}
+		metrics = append(metrics, metric)
+	}
+}
+
+func decodeCSV(r io.Reader) ([]models.Metrics, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, required := range []string{"id", "type"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("header: missing column %q", required)
+		}
+	}
+
+	var metrics []models.Metrics
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return metrics, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		metric, err := parseCSVRecord(columns, record)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		metrics = append(metrics, metric)
+	}
+}
+
+func parseCSVRecord(columns map[string]int, record []string) (models.Metrics, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	metric := models.Metrics{ID: field("id"), MType: field("type")}
+	if v := field("value"); v != "" {
+		value, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return models.Metrics{}, fmt.Errorf("invalid value: %w", err)
+		}
+		metric.Value = &value
+	}
+	if v := field("delta"); v != "" {
+		delta, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return models.Metrics{}, fmt.Errorf("invalid delta: %w", err)
+		}
+		metric.Delta = &delta
+	}
+	if v := field("histogram"); v != "" {
+		var h models.HistogramData
+		if err := json.Unmarshal([]byte(v), &h); err != nil {
+			return models.Metrics{}, fmt.Errorf("invalid histogram: %w", err)
+		}
+		metric.Histogram = &h
+	}
+	if unit, help, source := field("unit"), field("help"), field("source"); unit != "" || help != "" || source != "" {
+		metric.Meta = &models.MetricMeta{Unit: unit, Help: help, Source: source}
+	}
+	return metric, nil
+}
@@ -0,0 +1,37 @@
+package alerts
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+	"github.com/zetcan333/metrics-collector/internal/usecase/alerting"
+)
+
+type AlertsLister interface {
+	Alerts() []alerting.Alert
+}
+
+type AlertsHandler struct {
+	alerts AlertsLister
+}
+
+func New(a AlertsLister) *AlertsHandler {
+	return &AlertsHandler{alerts: a}
+}
+
+// Alerts возвращает состояние правил арендатора запроса в формате JSON
+func (h *AlertsHandler) Alerts(w http.ResponseWriter, r *http.Request) {
+	name := tenant.FromContext(r.Context())
+
+	result := make([]alerting.Alert, 0)
+	for _, alert := range h.alerts.Alerts() {
+		if alert.Tenant == name {
+			result = append(result, alert)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,27 @@
+package dashboard
+
+import (
+	"embed"
+	"net/http"
+)
+
+//go:embed static/index.html
+var static embed.FS
+
+type DashboardHandler struct{}
+
+func New() *DashboardHandler {
+	return &DashboardHandler{}
+}
+
+// Index отдаёт страницу дашборда; данные она забирает из /api/metrics
+func (h *DashboardHandler) Index(w http.ResponseWriter, r *http.Request) {
+	page, err := static.ReadFile("static/index.html")
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(page)
+}
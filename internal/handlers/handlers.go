@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/zetcan333/metrics-collector/internal/models"
@@ -14,12 +16,14 @@ import (
 
 //go:generate go run github.com/vektra/mockery/v2@v2.52.3 --name=ServerUseCase
 type ServerUseCase interface {
-	UpdateMetric(metricType, metricName, metricValue string) error
-	GetMetric(metricType, metricName string) (string, error)
-	UpdateViaModel(metric models.Metrics) (models.Metrics, error)
-	GetViaModel(metric models.Metrics) (models.Metrics, error)
-	GetAllMetrics() (string, error)
-	UpdateMetricsWithBatch(metrics []models.Metrics) error
+	UpdateMetric(ctx context.Context, metricType, metricName, metricValue string) error
+	GetMetric(ctx context.Context, metricType, metricName string) (string, error)
+	UpdateViaModel(ctx context.Context, metric models.Metrics) (models.Metrics, error)
+	GetViaModel(ctx context.Context, metric models.Metrics) (models.Metrics, error)
+	ListMetrics(ctx context.Context, filter models.MetricsFilter) ([]models.Metrics, string, error)
+	UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error
+	DeleteMetric(ctx context.Context, metricType, metricName string) error
+	DeleteMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error
 }
 
 type ServerHandler struct {
@@ -45,7 +49,7 @@ func (h *ServerHandler) UpdateMetric(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.serverUseCase.UpdateMetric(metricType, metricName, metricValue); err != nil {
+	if err := h.serverUseCase.UpdateMetric(r.Context(), metricType, metricName, metricValue); err != nil {
 		switch {
 		case isBadRequest(err):
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -65,7 +69,7 @@ func (h *ServerHandler) GetMetric(w http.ResponseWriter, r *http.Request) {
 	metricType := chi.URLParam(r, "type")
 	metricName := chi.URLParam(r, "name")
 
-	res, err := h.serverUseCase.GetMetric(metricType, metricName)
+	res, err := h.serverUseCase.GetMetric(r.Context(), metricType, metricName)
 	if err != nil {
 		switch {
 		case errors.Is(err, myerrors.ErrMetricNotFound):
@@ -87,7 +91,7 @@ func (h *ServerHandler) UpdateViaModel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updatedMetric, err := h.serverUseCase.UpdateViaModel(metric)
+	updatedMetric, err := h.serverUseCase.UpdateViaModel(r.Context(), metric)
 	if err != nil {
 		switch {
 		case isBadRequest(err):
@@ -112,7 +116,7 @@ func (h *ServerHandler) GetViaModel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.serverUseCase.GetViaModel(metric)
+	result, err := h.serverUseCase.GetViaModel(r.Context(), metric)
 	if err != nil {
 		switch {
 		case errors.Is(err, myerrors.ErrMetricNotFound):
@@ -133,17 +137,44 @@ func (h *ServerHandler) GetViaModel(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
-// GetAllMetricsHandler возвращает все метрики в формате HTML
-func (h *ServerHandler) GetAllMetrics(w http.ResponseWriter, r *http.Request) {
-	html, err := h.serverUseCase.GetAllMetrics()
+// ListMetrics возвращает метрики арендатора в формате JSON.
+// Параметры: type, prefix, match (glob или re:<regexp>), limit, cursor;
+// курсор следующей страницы передаётся в заголовке X-Next-Cursor.
+func (h *ServerHandler) ListMetrics(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.MetricsFilter{
+		Type:   query.Get("type"),
+		Prefix: query.Get("prefix"),
+		Match:  query.Get("match"),
+		Cursor: query.Get("cursor"),
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+
+	metrics, next, err := h.serverUseCase.ListMetrics(r.Context(), filter)
 	if err != nil {
-		h.log.Sugar().Errorln("falied to get metrics", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+		switch {
+		case errors.Is(err, myerrors.ErrInvalidMetricType), errors.Is(err, myerrors.ErrInvalidFilter):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		default:
+			h.log.Sugar().Errorln("falied to list metrics", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
 	}
-	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(html))
+	json.NewEncoder(w).Encode(metrics)
 }
 
 func (h *ServerHandler) UpdateMetricsWithBatch(w http.ResponseWriter, r *http.Request) {
@@ -158,7 +189,7 @@ func (h *ServerHandler) UpdateMetricsWithBatch(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	if err := h.serverUseCase.UpdateMetricsWithBatch(metrics); err != nil {
+	if err := h.serverUseCase.UpdateMetricsWithBatch(r.Context(), metrics); err != nil {
 		switch {
 		case isBadRequest(err):
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -173,8 +204,58 @@ func (h *ServerHandler) UpdateMetricsWithBatch(w http.ResponseWriter, r *http.Re
 	w.Write([]byte("Batch updated successfully\n"))
 }
 
+// DeleteMetric удаляет метрику по типу и имени
+func (h *ServerHandler) DeleteMetric(w http.ResponseWriter, r *http.Request) {
+	metricType := chi.URLParam(r, "type")
+	metricName := chi.URLParam(r, "name")
+
+	if err := h.serverUseCase.DeleteMetric(r.Context(), metricType, metricName); err != nil {
+		switch {
+		case errors.Is(err, myerrors.ErrMetricNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, myerrors.ErrInvalidMetricType):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		default:
+			h.log.Sugar().Errorln("falied to delete metric", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *ServerHandler) DeleteMetricsWithBatch(w http.ResponseWriter, r *http.Request) {
+	var metrics []models.Metrics
+	if err := json.NewDecoder(r.Body).Decode(&metrics); err != nil {
+		http.Error(w, "invalid JSON format", http.StatusBadRequest)
+		return
+	}
+
+	if len(metrics) == 0 {
+		http.Error(w, "empty batch", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.serverUseCase.DeleteMetricsWithBatch(r.Context(), metrics); err != nil {
+		switch {
+		case errors.Is(err, myerrors.ErrInvalidMetricType):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		default:
+			h.log.Sugar().Errorln("falied to delete metrics", zap.Error(err))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Batch deleted successfully\n"))
+}
+
 func isBadRequest(err error) bool {
 	return errors.Is(err, myerrors.ErrInvalidMetricType) ||
 		errors.Is(err, myerrors.ErrInvalidGaugeValue) ||
-		errors.Is(err, myerrors.ErrInvalidCounterValue)
+		errors.Is(err, myerrors.ErrInvalidCounterValue) ||
+		errors.Is(err, myerrors.ErrInvalidHistogramValue)
 }
@@ -3,6 +3,7 @@ package handlers_test
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -30,7 +31,7 @@ func TestUpdateMetric(t *testing.T) {
 			name: "Success gauge update",
 			path: "/update/gauge/testGauge/123.45",
 			mockSetup: func(m *mocks.ServerUseCase) {
-				m.On("UpdateMetric", "gauge", "testGauge", "123.45").Return(nil)
+				m.On("UpdateMetric", mock.Anything, "gauge", "testGauge", "123.45").Return(nil)
 			},
 			expectedCode: http.StatusOK,
 		},
@@ -38,7 +39,7 @@ func TestUpdateMetric(t *testing.T) {
 			name: "Invalid metric type",
 			path: "/update/invalid/test/123",
 			mockSetup: func(m *mocks.ServerUseCase) {
-				m.On("UpdateMetric", "invalid", "test", "123").Return(myerrors.ErrInvalidMetricType)
+				m.On("UpdateMetric", mock.Anything, "invalid", "test", "123").Return(myerrors.ErrInvalidMetricType)
 			},
 			expectedCode: http.StatusBadRequest,
 			expectedBody: "invalid metric type\n",
@@ -81,7 +82,7 @@ func TestGetMetric(t *testing.T) {
 			name: "Success get gauge",
 			path: "/value/gauge/testGauge",
 			mockSetup: func(m *mocks.ServerUseCase) {
-				m.On("GetMetric", "gauge", "testGauge").Return("123.45", nil)
+				m.On("GetMetric", mock.Anything, "gauge", "testGauge").Return("123.45", nil)
 			},
 			expectedCode: http.StatusOK,
 			expectedBody: "123.45",
@@ -90,7 +91,7 @@ func TestGetMetric(t *testing.T) {
 			name: "Metric not found",
 			path: "/value/gauge/notfound",
 			mockSetup: func(m *mocks.ServerUseCase) {
-				m.On("GetMetric", "gauge", "notfound").Return("", myerrors.ErrMetricNotFound)
+				m.On("GetMetric", mock.Anything, "gauge", "notfound").Return("", myerrors.ErrMetricNotFound)
 			},
 			expectedCode: http.StatusNotFound,
 			expectedBody: "metric not found\n",
@@ -135,7 +136,7 @@ func TestUpdateViaModel(t *testing.T) {
 				Value: func() *float64 { v := 123.45; return &v }(),
 			},
 			mockSetup: func(m *mocks.ServerUseCase) {
-				m.On("UpdateViaModel", mock.AnythingOfType("models.Metrics")).
+				m.On("UpdateViaModel", mock.Anything, mock.AnythingOfType("models.Metrics")).
 					Return(models.Metrics{
 						ID:    "testGauge",
 						MType: "gauge",
@@ -187,7 +188,7 @@ func TestGetViaModel(t *testing.T) {
 				MType: "gauge",
 			},
 			mockSetup: func(m *mocks.ServerUseCase) {
-				m.On("GetViaModel", mock.AnythingOfType("models.Metrics")).
+				m.On("GetViaModel", mock.Anything, mock.AnythingOfType("models.Metrics")).
 					Return(models.Metrics{
 						ID:    "testGauge",
 						MType: "gauge",
@@ -224,20 +225,62 @@ func TestGetViaModel(t *testing.T) {
 	}
 }
 
-func TestGetAllMetrics(t *testing.T) {
+func TestListMetrics(t *testing.T) {
+	value := 0.0012
 	tests := []struct {
 		name         string
+		url          string
 		mockSetup    func(*mocks.ServerUseCase)
 		expectedCode int
 		expectedBody string
+		expectedNext string
 	}{
 		{
-			name: "Success get all metrics",
+			name: "Success list metrics",
+			url:  "/api/metrics",
 			mockSetup: func(m *mocks.ServerUseCase) {
-				m.On("GetAllMetrics").Return("<html>metrics</html>", nil)
+				m.On("ListMetrics", mock.Anything, models.MetricsFilter{}).Return([]models.Metrics{
+					{ID: "Alloc", MType: "gauge", Value: &value},
+				}, "", nil)
 			},
 			expectedCode: http.StatusOK,
-			expectedBody: "<html>metrics</html>",
+			expectedBody: `[{"id":"Alloc","type":"gauge","value":0.0012}]`,
+		},
+		{
+			name: "Filter and pagination",
+			url:  "/api/metrics?type=gauge&prefix=Al&match=re:^Al.*c$&limit=1&cursor=A",
+			mockSetup: func(m *mocks.ServerUseCase) {
+				m.On("ListMetrics", mock.Anything, models.MetricsFilter{
+					Type: "gauge", Prefix: "Al", Match: "re:^Al.*c$", Cursor: "A", Limit: 1,
+				}).Return([]models.Metrics{
+					{ID: "Alloc", MType: "gauge", Value: &value},
+				}, "Alloc", nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"id":"Alloc","type":"gauge","value":0.0012}]`,
+			expectedNext: "Alloc",
+		},
+		{
+			name:         "Invalid limit",
+			url:          "/api/metrics?limit=abc",
+			mockSetup:    func(m *mocks.ServerUseCase) {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Invalid filter",
+			url:  "/api/metrics?match=re:(",
+			mockSetup: func(m *mocks.ServerUseCase) {
+				m.On("ListMetrics", mock.Anything, models.MetricsFilter{Match: "re:("}).Return(nil, "", myerrors.ErrInvalidFilter)
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Internal error",
+			url:  "/api/metrics",
+			mockSetup: func(m *mocks.ServerUseCase) {
+				m.On("ListMetrics", mock.Anything, models.MetricsFilter{}).Return(nil, "", errors.New("db down"))
+			},
+			expectedCode: http.StatusInternalServerError,
 		},
 	}
 
@@ -248,16 +291,19 @@ func TestGetAllMetrics(t *testing.T) {
 
 			handler := handlers.NewServerHandler(zapdiscard.NewDiscardLogger(), mockUsecase)
 			r := chi.NewRouter()
-			r.Get("/", handler.GetAllMetrics)
+			r.Get("/api/metrics", handler.ListMetrics)
 
-			req, err := http.NewRequest(http.MethodGet, "/", nil)
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
 			require.NoError(t, err)
 
 			rr := httptest.NewRecorder()
 			r.ServeHTTP(rr, req)
 
 			assert.Equal(t, tt.expectedCode, rr.Code)
-			assert.Equal(t, tt.expectedBody, rr.Body.String())
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+			assert.Equal(t, tt.expectedNext, rr.Header().Get("X-Next-Cursor"))
 			mockUsecase.AssertExpectations(t)
 		})
 	}
@@ -286,7 +332,7 @@ func TestUpdateMetricsWithBatc(t *testing.T) {
 				},
 			},
 			mockSetup: func(m *mocks.ServerUseCase) {
-				m.On("UpdateMetricsWithBatch", mock.AnythingOfType("[]models.Metrics")).
+				m.On("UpdateMetricsWithBatch", mock.Anything, mock.AnythingOfType("[]models.Metrics")).
 					Return(nil)
 			},
 			expectedCode: http.StatusOK,
@@ -314,3 +360,112 @@ func TestUpdateMetricsWithBatc(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteMetric(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		mockSetup    func(*mocks.ServerUseCase)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Success delete gauge",
+			path: "/value/gauge/testGauge",
+			mockSetup: func(m *mocks.ServerUseCase) {
+				m.On("DeleteMetric", mock.Anything, "gauge", "testGauge").Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "Metric not found",
+			path: "/value/counter/notfound",
+			mockSetup: func(m *mocks.ServerUseCase) {
+				m.On("DeleteMetric", mock.Anything, "counter", "notfound").Return(myerrors.ErrMetricNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+			expectedBody: "metric not found\n",
+		},
+		{
+			name: "Invalid metric type",
+			path: "/value/invalid/test",
+			mockSetup: func(m *mocks.ServerUseCase) {
+				m.On("DeleteMetric", mock.Anything, "invalid", "test").Return(myerrors.ErrInvalidMetricType)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "invalid metric type\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUsecase := &mocks.ServerUseCase{}
+			tt.mockSetup(mockUsecase)
+
+			handler := handlers.NewServerHandler(zapdiscard.NewDiscardLogger(), mockUsecase)
+			r := chi.NewRouter()
+			r.Delete("/value/{type}/{name}", handler.DeleteMetric)
+
+			req, err := http.NewRequest(http.MethodDelete, tt.path, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+			mockUsecase.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteMetricsWithBatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		requestBody  []models.Metrics
+		mockSetup    func(*mocks.ServerUseCase)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Success delete metrics with batch",
+			requestBody: []models.Metrics{
+				{ID: "testGauge", MType: "gauge"},
+				{ID: "testCounter", MType: "counter"},
+			},
+			mockSetup: func(m *mocks.ServerUseCase) {
+				m.On("DeleteMetricsWithBatch", mock.Anything, mock.AnythingOfType("[]models.Metrics")).
+					Return(nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "Batch deleted successfully\n",
+		},
+		{
+			name:         "Empty batch",
+			requestBody:  []models.Metrics{},
+			mockSetup:    func(m *mocks.ServerUseCase) {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "empty batch\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUsecase := &mocks.ServerUseCase{}
+			tt.mockSetup(mockUsecase)
+
+			handler := handlers.NewServerHandler(zapdiscard.NewDiscardLogger(), mockUsecase)
+			r := chi.NewRouter()
+			r.Delete("/value/", handler.DeleteMetricsWithBatch)
+
+			body, _ := json.Marshal(tt.requestBody)
+			req, err := http.NewRequest(http.MethodDelete, "/value/", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+			mockUsecase.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package influx
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/zetcan333/metrics-collector/internal/ingest/cumulative"
+	"github.com/zetcan333/metrics-collector/internal/ingest/influx"
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	maxLineSize = 1 << 20
+	maxBodySize = 32 << 20
+)
+
+type Updater interface {
+	UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error
+}
+
+type InfluxHandler struct {
+	log      *zap.Logger
+	updater  Updater
+	counters *cumulative.Counters // nil — целые поля записываются как gauge
+}
+
+func New(log *zap.Logger, updater Updater, counterMode bool) *InfluxHandler {
+	h := &InfluxHandler{log: log, updater: updater}
+	if counterMode {
+		h.counters = cumulative.NewCounters()
+	}
+	return h
+}
+
+type lineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type writeError struct {
+	Error string      `json:"error"`
+	Lines []lineError `json:"lines,omitempty"`
+}
+
+// Write принимает InfluxDB line protocol. Корректные строки записываются одной пачкой
+// даже при ошибках в других; ошибки возвращаются с номерами строк и статусом 400.
+// Метки времени проверяются, но хранилище фиксирует время приёма.
+func (h *InfluxHandler) Write(w http.ResponseWriter, r *http.Request) {
+	var (
+		metrics []models.Metrics
+		errs    []lineError
+		total   int
+		pending *cumulative.Pending
+	)
+	if h.counters != nil {
+		pending = h.counters.Begin()
+		defer pending.Discard()
+	}
+	tenantID := tenant.FromContext(r.Context())
+
+	scanner := bufio.NewScanner(http.MaxBytesReader(w, r.Body, maxBodySize))
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for n := 1; scanner.Scan(); n++ {
+		point, err := influx.ParseLine(scanner.Text())
+		if err != nil {
+			errs = append(errs, lineError{Line: n, Error: err.Error()})
+			continue
+		}
+		if point != nil {
+			total++
+			metrics = append(metrics, point.Metrics(pending, tenantID)...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		writeJSON(w, http.StatusBadRequest, writeError{Error: "failed to read body: " + err.Error()})
+		return
+	}
+
+	if pending != nil && pending.Err() != nil {
+		w.Header().Set("Retry-After", "1")
+		writeJSON(w, http.StatusServiceUnavailable, writeError{Error: pending.Err().Error()})
+		return
+	}
+
+	if len(metrics) > 0 {
+		if err := h.updater.UpdateMetricsWithBatch(r.Context(), metrics); err != nil {
+			h.log.Sugar().Errorln("failed to write line protocol metrics", zap.Error(err))
+			writeJSON(w, http.StatusInternalServerError, writeError{Error: "failed to store metrics"})
+			return
+		}
+	}
+	// Значения счётчиков запоминаются только после записи, чтобы повтор запроса не потерял приращения
+	if pending != nil {
+		pending.Commit()
+	}
+
+	if len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, writeError{
+			Error: fmt.Sprintf("partial write: %d of %d lines rejected", len(errs), total+len(errs)),
+			Lines: errs,
+		})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
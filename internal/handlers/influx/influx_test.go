@@ -0,0 +1,64 @@
+package influx_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/handlers/influx"
+	"github.com/zetcan333/metrics-collector/internal/lib/zapdiscard"
+	"github.com/zetcan333/metrics-collector/internal/repo/storage/mem"
+	"github.com/zetcan333/metrics-collector/internal/usecase"
+)
+
+func TestWrite(t *testing.T) {
+	ctx := context.Background()
+	uc := usecase.NewSeverUsecase(mem.NewStorage())
+	h := influx.New(zapdiscard.NewDiscardLogger(), uc, true)
+
+	rr := httptest.NewRecorder()
+	h.Write(rr, httptest.NewRequest(http.MethodPost, "/write", strings.NewReader("mem,host=a used=0.25,requests=2i\n\nmem,host=a requests=3i 1700000000\n")))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Целые поля накопительные: 2 — точка отсчёта, затем 3 — прирост 1
+	value, err := uc.GetMetric(ctx, "counter", `mem_requests{host="a"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+
+	// Повторная отправка того же итога не увеличивает счётчик, сброс даёт новое значение целиком
+	for _, body := range []string{"mem,host=a requests=3i\n", "mem,host=a requests=10i\n", "mem,host=a requests=4i\n"} {
+		rr = httptest.NewRecorder()
+		h.Write(rr, httptest.NewRequest(http.MethodPost, "/write", strings.NewReader(body)))
+		require.Equal(t, http.StatusNoContent, rr.Code)
+	}
+	value, err = uc.GetMetric(ctx, "counter", `mem_requests{host="a"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "12", value)
+
+	rr = httptest.NewRecorder()
+	h.Write(rr, httptest.NewRequest(http.MethodPost, "/write", strings.NewReader("disk free=10\ndisk free=\ndisk,x used=1\n")))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	var resp struct {
+		Error string
+		Lines []struct {
+			Line  int
+			Error string
+		}
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, "partial write: 2 of 3 lines rejected", resp.Error)
+	require.Len(t, resp.Lines, 2)
+	assert.Equal(t, 2, resp.Lines[0].Line)
+	assert.Equal(t, 3, resp.Lines[1].Line)
+
+	// Корректная строка записана, несмотря на ошибки в остальных
+	value, err = uc.GetMetric(ctx, "gauge", "disk_free")
+	require.NoError(t, err)
+	assert.Equal(t, "10", value)
+}
@@ -0,0 +1,27 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// HeaderToken — заголовок с токеном администратора. Authorization занят токеном арендатора,
+// поэтому токен администратора передаётся отдельно.
+const HeaderToken = "X-Admin-Token"
+
+// New пропускает только запросы с токеном администратора token в заголовке X-Admin-Token.
+// Арендатор запроса по-прежнему определяет middleware арендаторов: токен администратора
+// не привязан к арендатору и разрешает административные операции над любым из них.
+func New(token string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			got := r.Header.Get(HeaderToken)
+			if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				http.Error(w, "admin token required", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,38 @@
+package admin_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	mwAdmin "github.com/zetcan333/metrics-collector/internal/handlers/middleware/admin"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		header   string
+		wantCode int
+	}{
+		{name: "верный токен", token: "secret", header: "secret", wantCode: http.StatusOK},
+		{name: "без токена", token: "secret", wantCode: http.StatusUnauthorized},
+		{name: "неверный токен", token: "secret", header: "other", wantCode: http.StatusUnauthorized},
+		{name: "пустой токен сервера не пускает никого", header: "", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := mwAdmin.New(tt.token)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			r := httptest.NewRequest(http.MethodGet, "/admin/export", nil)
+			if tt.header != "" {
+				r.Header.Set(mwAdmin.HeaderToken, tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package tenant
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+)
+
+const (
+	HeaderTenant = "X-Tenant-ID"
+	QueryTenant  = "tenant"
+)
+
+// New определяет арендатора запроса и кладёт его в контекст.
+// Если tokens задан (даже пустой — файл токенов загружен), включается аутентификация: арендатор
+// берётся только из токена в Authorization: Bearer, заголовок X-Tenant-ID игнорируется, а запросы
+// без токена отклоняются с 401. allowAnonymous явно разрешает им работать в tenant.Default.
+// При tokens == nil арендатор берётся из заголовка X-Tenant-ID или параметра ?tenant= без
+// проверки — этот режим годится только для доверенной сети.
+func New(tokens map[string]string, allowAnonymous bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			name := tenant.Default
+
+			if tokens != nil {
+				token, ok := bearerToken(r)
+				switch {
+				case ok:
+					t, found := tokens[token]
+					if !found {
+						http.Error(w, "unknown tenant token", http.StatusUnauthorized)
+						return
+					}
+					name = t
+				case !allowAnonymous:
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					http.Error(w, "tenant token required", http.StatusUnauthorized)
+					return
+				}
+			} else {
+				if h := r.Header.Get(HeaderTenant); h != "" {
+					name = h
+				} else if q := r.URL.Query().Get(QueryTenant); q != "" {
+					name = q
+				}
+			}
+
+			if !tenant.IsValid(name) {
+				http.Error(w, "invalid tenant", http.StatusBadRequest)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(tenant.WithTenant(r.Context(), name)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// LoadTokens читает файл вида "<token> <tenant>" построчно, # — комментарий
+func LoadTokens(path string) (map[string]string, error) {
+	const op = "internal.handlers.middleware.tenant.LoadTokens"
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer file.Close()
+
+	tokens := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !tenant.IsValid(fields[1]) {
+			return nil, fmt.Errorf("%s: line %d: expected \"<token> <tenant>\"", op, lineNum)
+		}
+		tokens[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return tokens, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(auth, "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	return token, true
+}
@@ -0,0 +1,53 @@
+package tenant_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	mwTenant "github.com/zetcan333/metrics-collector/internal/handlers/middleware/tenant"
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		tokens    map[string]string
+		anonymous bool
+		header    string
+		token     string
+		wantCode  int
+		want      string
+	}{
+		{name: "без файла токенов заголовок принимается", header: "teamA", wantCode: http.StatusOK, want: "teamA"},
+		{name: "с токенами запрос без токена отклоняется", tokens: map[string]string{"t1": "teamB"}, header: "teamA", wantCode: http.StatusUnauthorized},
+		{name: "пустой файл токенов не доверяет заголовку", tokens: map[string]string{}, header: "teamA", wantCode: http.StatusUnauthorized},
+		{name: "анонимный доступ разрешён явно", tokens: map[string]string{"t1": "teamB"}, anonymous: true, header: "teamA", wantCode: http.StatusOK, want: tenant.Default},
+		{name: "анонимный доступ не отменяет проверку токена", tokens: map[string]string{"t1": "teamB"}, anonymous: true, token: "t2", wantCode: http.StatusUnauthorized},
+		{name: "арендатор по токену", tokens: map[string]string{"t1": "teamB"}, token: "t1", wantCode: http.StatusOK, want: "teamB"},
+		{name: "неизвестный токен", tokens: map[string]string{}, token: "t1", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := mwTenant.New(tt.tokens, tt.anonymous)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = tenant.FromContext(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(mwTenant.HeaderTenant, tt.header)
+			}
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, r)
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	context "context"
 	mock "github.com/stretchr/testify/mock"
 	models "github.com/zetcan333/metrics-collector/internal/models"
 )
@@ -12,37 +13,45 @@ type ServerUseCase struct {
 	mock.Mock
 }
 
-// GetAllMetrics provides a mock function with no fields
-func (_m *ServerUseCase) GetAllMetrics() (string, error) {
-	ret := _m.Called()
+// DeleteMetric provides a mock function with given fields: ctx, metricType, metricName
+func (_m *ServerUseCase) DeleteMetric(ctx context.Context, metricType string, metricName string) error {
+	ret := _m.Called(ctx, metricType, metricName)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetAllMetrics")
+		panic("no return value specified for DeleteMetric")
 	}
 
-	var r0 string
-	var r1 error
-	if rf, ok := ret.Get(0).(func() (string, error)); ok {
-		return rf()
-	}
-	if rf, ok := ret.Get(0).(func() string); ok {
-		r0 = rf()
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, metricType, metricName)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Error(0)
 	}
 
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	return r0
+}
+
+// DeleteMetricsWithBatch provides a mock function with given fields: ctx, metrics
+func (_m *ServerUseCase) DeleteMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error {
+	ret := _m.Called(ctx, metrics)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteMetricsWithBatch")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []models.Metrics) error); ok {
+		r0 = rf(ctx, metrics)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1
+	return r0
 }
 
-// GetMetric provides a mock function with given fields: metricType, metricName
-func (_m *ServerUseCase) GetMetric(metricType string, metricName string) (string, error) {
-	ret := _m.Called(metricType, metricName)
+// GetMetric provides a mock function with given fields: ctx, metricType, metricName
+func (_m *ServerUseCase) GetMetric(ctx context.Context, metricType string, metricName string) (string, error) {
+	ret := _m.Called(ctx, metricType, metricName)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetMetric")
@@ -50,17 +59,17 @@ func (_m *ServerUseCase) GetMetric(metricType string, metricName string) (string
 
 	var r0 string
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string, string) (string, error)); ok {
-		return rf(metricType, metricName)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (string, error)); ok {
+		return rf(ctx, metricType, metricName)
 	}
-	if rf, ok := ret.Get(0).(func(string, string) string); ok {
-		r0 = rf(metricType, metricName)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) string); ok {
+		r0 = rf(ctx, metricType, metricName)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
-	if rf, ok := ret.Get(1).(func(string, string) error); ok {
-		r1 = rf(metricType, metricName)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, metricType, metricName)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -68,9 +77,9 @@ func (_m *ServerUseCase) GetMetric(metricType string, metricName string) (string
 	return r0, r1
 }
 
-// GetViaModel provides a mock function with given fields: metric
-func (_m *ServerUseCase) GetViaModel(metric models.Metrics) (models.Metrics, error) {
-	ret := _m.Called(metric)
+// GetViaModel provides a mock function with given fields: ctx, metric
+func (_m *ServerUseCase) GetViaModel(ctx context.Context, metric models.Metrics) (models.Metrics, error) {
+	ret := _m.Called(ctx, metric)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetViaModel")
@@ -78,17 +87,17 @@ func (_m *ServerUseCase) GetViaModel(metric models.Metrics) (models.Metrics, err
 
 	var r0 models.Metrics
 	var r1 error
-	if rf, ok := ret.Get(0).(func(models.Metrics) (models.Metrics, error)); ok {
-		return rf(metric)
+	if rf, ok := ret.Get(0).(func(context.Context, models.Metrics) (models.Metrics, error)); ok {
+		return rf(ctx, metric)
 	}
-	if rf, ok := ret.Get(0).(func(models.Metrics) models.Metrics); ok {
-		r0 = rf(metric)
+	if rf, ok := ret.Get(0).(func(context.Context, models.Metrics) models.Metrics); ok {
+		r0 = rf(ctx, metric)
 	} else {
 		r0 = ret.Get(0).(models.Metrics)
 	}
 
-	if rf, ok := ret.Get(1).(func(models.Metrics) error); ok {
-		r1 = rf(metric)
+	if rf, ok := ret.Get(1).(func(context.Context, models.Metrics) error); ok {
+		r1 = rf(ctx, metric)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -96,17 +105,54 @@ func (_m *ServerUseCase) GetViaModel(metric models.Metrics) (models.Metrics, err
 	return r0, r1
 }
 
-// UpdateMetric provides a mock function with given fields: metricType, metricName, metricValue
-func (_m *ServerUseCase) UpdateMetric(metricType string, metricName string, metricValue string) error {
-	ret := _m.Called(metricType, metricName, metricValue)
+// ListMetrics provides a mock function with given fields: ctx, filter
+func (_m *ServerUseCase) ListMetrics(ctx context.Context, filter models.MetricsFilter) ([]models.Metrics, string, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListMetrics")
+	}
+
+	var r0 []models.Metrics
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.MetricsFilter) ([]models.Metrics, string, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.MetricsFilter) []models.Metrics); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Metrics)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.MetricsFilter) string); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, models.MetricsFilter) error); ok {
+		r2 = rf(ctx, filter)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// UpdateMetric provides a mock function with given fields: ctx, metricType, metricName, metricValue
+func (_m *ServerUseCase) UpdateMetric(ctx context.Context, metricType string, metricName string, metricValue string) error {
+	ret := _m.Called(ctx, metricType, metricName, metricValue)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpdateMetric")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, string, string) error); ok {
-		r0 = rf(metricType, metricName, metricValue)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, metricType, metricName, metricValue)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -114,17 +160,17 @@ func (_m *ServerUseCase) UpdateMetric(metricType string, metricName string, metr
 	return r0
 }
 
-// UpdateMetricsWithBatch provides a mock function with given fields: metrics
-func (_m *ServerUseCase) UpdateMetricsWithBatch(metrics []models.Metrics) error {
-	ret := _m.Called(metrics)
+// UpdateMetricsWithBatch provides a mock function with given fields: ctx, metrics
+func (_m *ServerUseCase) UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error {
+	ret := _m.Called(ctx, metrics)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpdateMetricsWithBatch")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func([]models.Metrics) error); ok {
-		r0 = rf(metrics)
+	if rf, ok := ret.Get(0).(func(context.Context, []models.Metrics) error); ok {
+		r0 = rf(ctx, metrics)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -132,9 +178,9 @@ func (_m *ServerUseCase) UpdateMetricsWithBatch(metrics []models.Metrics) error
 	return r0
 }
 
-// UpdateViaModel provides a mock function with given fields: metric
-func (_m *ServerUseCase) UpdateViaModel(metric models.Metrics) (models.Metrics, error) {
-	ret := _m.Called(metric)
+// UpdateViaModel provides a mock function with given fields: ctx, metric
+func (_m *ServerUseCase) UpdateViaModel(ctx context.Context, metric models.Metrics) (models.Metrics, error) {
+	ret := _m.Called(ctx, metric)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpdateViaModel")
@@ -142,17 +188,17 @@ func (_m *ServerUseCase) UpdateViaModel(metric models.Metrics) (models.Metrics,
 
 	var r0 models.Metrics
 	var r1 error
-	if rf, ok := ret.Get(0).(func(models.Metrics) (models.Metrics, error)); ok {
-		return rf(metric)
+	if rf, ok := ret.Get(0).(func(context.Context, models.Metrics) (models.Metrics, error)); ok {
+		return rf(ctx, metric)
 	}
-	if rf, ok := ret.Get(0).(func(models.Metrics) models.Metrics); ok {
-		r0 = rf(metric)
+	if rf, ok := ret.Get(0).(func(context.Context, models.Metrics) models.Metrics); ok {
+		r0 = rf(ctx, metric)
 	} else {
 		r0 = ret.Get(0).(models.Metrics)
 	}
 
-	if rf, ok := ret.Get(1).(func(models.Metrics) error); ok {
-		r1 = rf(metric)
+	if rf, ok := ret.Get(1).(func(context.Context, models.Metrics) error); ok {
+		r1 = rf(ctx, metric)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -0,0 +1,138 @@
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zetcan333/metrics-collector/internal/ingest/otlp"
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/pkg/myerrors"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	maxBodySize = 16 << 20
+
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+type Updater interface {
+	UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error
+}
+
+type OTLPHandler struct {
+	log       *zap.Logger
+	updater   Updater
+	converter *otlp.Converter
+}
+
+func New(log *zap.Logger, updater Updater, converter *otlp.Converter) *OTLPHandler {
+	return &OTLPHandler{log: log, updater: updater, converter: converter}
+}
+
+// Metrics принимает ExportMetricsServiceRequest по OTLP/HTTP в protobuf или JSON.
+// Неподдерживаемые точки не мешают записи остальных и возвращаются в partial_success.
+func (h *OTLPHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != contentTypeProtobuf && contentType != contentTypeJSON {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	// MetricsData совпадает по формату с ExportMetricsServiceRequest
+	var data metricsv1.MetricsData
+	if contentType == contentTypeJSON {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, &data)
+	} else {
+		err = proto.Unmarshal(body, &data)
+	}
+	if err != nil {
+		http.Error(w, "failed to decode request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res := h.converter.Convert(tenant.FromContext(r.Context()), &data)
+	defer res.Discard()
+	if err := res.Err(); err != nil {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if len(res.Metrics) > 0 {
+		if err := h.updater.UpdateMetricsWithBatch(r.Context(), res.Metrics); err != nil {
+			// Например, границы корзин гистограммы не совпадают с сохранёнными: повтор
+			// запроса не поможет, а на 500 экспортёры OTLP повторяют его бесконечно
+			if errors.Is(err, myerrors.ErrInvalidHistogramValue) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			h.log.Sugar().Errorln("failed to write otlp metrics", zap.Error(err))
+			http.Error(w, "failed to store metrics", http.StatusInternalServerError)
+			return
+		}
+	}
+	res.Commit()
+
+	message := strings.Join(res.Problems, "; ")
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	if contentType == contentTypeJSON {
+		writeJSONResponse(w, res.Rejected, message)
+		return
+	}
+	w.Write(protobufResponse(res.Rejected, message))
+}
+
+type partialSuccess struct {
+	RejectedDataPoints string `json:"rejectedDataPoints"` // int64 в protojson кодируется строкой
+	ErrorMessage       string `json:"errorMessage,omitempty"`
+}
+
+type exportResponse struct {
+	PartialSuccess *partialSuccess `json:"partialSuccess,omitempty"`
+}
+
+func writeJSONResponse(w io.Writer, rejected int, message string) {
+	var resp exportResponse
+	if rejected > 0 {
+		resp.PartialSuccess = &partialSuccess{RejectedDataPoints: strconv.Itoa(rejected), ErrorMessage: message}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// protobufResponse кодирует ExportMetricsServiceResponse без зависимости от gRPC-пакета коллектора:
+// partial_success = 1 { rejected_data_points = 1; error_message = 2 }
+func protobufResponse(rejected int, message string) []byte {
+	if rejected == 0 {
+		return nil
+	}
+	var partial []byte
+	partial = protowire.AppendTag(partial, 1, protowire.VarintType)
+	partial = protowire.AppendVarint(partial, uint64(rejected))
+	if message != "" {
+		partial = protowire.AppendTag(partial, 2, protowire.BytesType)
+		partial = protowire.AppendString(partial, message)
+	}
+
+	var resp []byte
+	resp = protowire.AppendTag(resp, 1, protowire.BytesType)
+	return protowire.AppendBytes(resp, partial)
+}
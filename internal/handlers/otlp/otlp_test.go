@@ -0,0 +1,122 @@
+package otlp_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/handlers/otlp"
+	otlpIngest "github.com/zetcan333/metrics-collector/internal/ingest/otlp"
+	"github.com/zetcan333/metrics-collector/internal/lib/zapdiscard"
+	"github.com/zetcan333/metrics-collector/internal/repo/storage/mem"
+	"github.com/zetcan333/metrics-collector/internal/usecase"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	uc := usecase.NewSeverUsecase(mem.NewStorage())
+	h := otlp.New(zapdiscard.NewDiscardLogger(), uc, otlpIngest.NewConverter(""))
+
+	post := func(contentType string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/metrics", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rr := httptest.NewRecorder()
+		h.Metrics(rr, req)
+		return rr
+	}
+
+	rr := post("application/json", []byte(`{"resourceMetrics":[{"scopeMetrics":[{"metrics":[
+		{"name":"temp","gauge":{"dataPoints":[{"asDouble":21.5,"attributes":[{"key":"room","value":{"stringValue":"a"}}]}]}},
+		{"name":"jobs","sum":{"aggregationTemporality":1,"isMonotonic":true,"dataPoints":[{"asInt":"4"}]}},
+		{"name":"sizes","summary":{"dataPoints":[{"count":"1"}]}}
+	]}]}]}`))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"partialSuccess":{"rejectedDataPoints":"1","errorMessage":"sizes: summaries are not supported"}}`, rr.Body.String())
+
+	value, err := uc.GetMetric(ctx, "gauge", `temp{room="a"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "21.5", value)
+
+	body, err := proto.Marshal(&metricsv1.MetricsData{ResourceMetrics: []*metricsv1.ResourceMetrics{{
+		ScopeMetrics: []*metricsv1.ScopeMetrics{{Metrics: []*metricsv1.Metric{{
+			Name: "jobs",
+			Data: &metricsv1.Metric_Sum{Sum: &metricsv1.Sum{
+				AggregationTemporality: metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+				IsMonotonic:            true,
+				DataPoints:             []*metricsv1.NumberDataPoint{{Value: &metricsv1.NumberDataPoint_AsInt{AsInt: 6}}},
+			}},
+		}}}},
+	}}})
+	require.NoError(t, err)
+	rr = post("application/x-protobuf", body)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Body.Bytes())
+
+	value, err = uc.GetMetric(ctx, "counter", "jobs")
+	require.NoError(t, err)
+	assert.Equal(t, "10", value)
+
+	// partial_success в protobuf: поле 1 с вложенным rejected_data_points
+	rr = post("application/x-protobuf", mustMarshalSummary(t))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	num, typ, n := protowire.ConsumeTag(rr.Body.Bytes())
+	require.Greater(t, n, 0)
+	assert.Equal(t, protowire.Number(1), num)
+	assert.Equal(t, protowire.BytesType, typ)
+	partial, _ := protowire.ConsumeBytes(rr.Body.Bytes()[n:])
+	_, _, n = protowire.ConsumeTag(partial)
+	rejected, _ := protowire.ConsumeVarint(partial[n:])
+	assert.Equal(t, uint64(1), rejected)
+
+	rr = post("text/plain", []byte("jobs 1"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+
+	rr = post("application/json", []byte(strings.Repeat("{", 3)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestMetricsHistogramBoundsMismatch(t *testing.T) {
+	uc := usecase.NewSeverUsecase(mem.NewStorage())
+	h := otlp.New(zapdiscard.NewDiscardLogger(), uc, otlpIngest.NewConverter(""))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/metrics", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.Metrics(rr, req)
+		return rr
+	}
+	histogram := func(bounds string) string {
+		return `{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"name":"latency","histogram":{"aggregationTemporality":1,
+			"dataPoints":[{"count":"1","sum":0.5,"bucketCounts":["1","0"],"explicitBounds":[` + bounds + `]}]}}]}]}]}`
+	}
+
+	require.Equal(t, http.StatusOK, post(histogram("1")).Code)
+
+	// Другие границы не исправит повтор запроса: 400, а не 500, который экспортёр повторял бы вечно
+	rr := post(histogram("2"))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	value, err := uc.GetMetric(context.Background(), "histogram", "latency")
+	require.NoError(t, err)
+	assert.Contains(t, value, `"count":1`)
+}
+
+func mustMarshalSummary(t *testing.T) []byte {
+	body, err := proto.Marshal(&metricsv1.MetricsData{ResourceMetrics: []*metricsv1.ResourceMetrics{{
+		ScopeMetrics: []*metricsv1.ScopeMetrics{{Metrics: []*metricsv1.Metric{{
+			Name: "sizes",
+			Data: &metricsv1.Metric_Summary{Summary: &metricsv1.Summary{DataPoints: []*metricsv1.SummaryDataPoint{{Count: 1}}}},
+		}}}},
+	}}})
+	require.NoError(t, err)
+	return body
+}
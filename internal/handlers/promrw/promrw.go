@@ -0,0 +1,87 @@
+package promrw
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/golang/snappy"
+	"github.com/zetcan333/metrics-collector/internal/ingest/promrw"
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	maxBodySize = 32 << 20
+	// maxDecodedSize ограничивает распакованный запрос: длина из заголовка snappy
+	// проверяется до выделения памяти, иначе несколько байт могут запросить гигабайты
+	maxDecodedSize = 64 << 20
+)
+
+type Updater interface {
+	UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error
+}
+
+type RemoteWriteHandler struct {
+	log       *zap.Logger
+	updater   Updater
+	converter *promrw.Converter
+}
+
+func New(log *zap.Logger, updater Updater, converter *promrw.Converter) *RemoteWriteHandler {
+	return &RemoteWriteHandler{log: log, updater: updater, converter: converter}
+}
+
+// Write принимает snappy-сжатый protobuf WriteRequest Prometheus remote write.
+// На ошибки хранилища отвечает 500, чтобы Prometheus повторил отправку; 400 — без повтора.
+func (h *RemoteWriteHandler) Write(w http.ResponseWriter, r *http.Request) {
+	compressed, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	decodedLen, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		http.Error(w, "failed to decompress snappy body", http.StatusBadRequest)
+		return
+	}
+	if decodedLen > maxDecodedSize {
+		http.Error(w, fmt.Sprintf("decompressed body exceeds %d bytes", maxDecodedSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	body, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		http.Error(w, "failed to decompress snappy body", http.StatusBadRequest)
+		return
+	}
+
+	req, err := promrw.Unmarshal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	metrics, skipped, pending := h.converter.Convert(tenant.FromContext(r.Context()), req)
+	defer pending.Discard()
+	if err := pending.Err(); err != nil {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if skipped > 0 {
+		h.log.Sugar().Infoln("remote write: skipped series without __name__:", skipped)
+	}
+	if len(metrics) > 0 {
+		if err := h.updater.UpdateMetricsWithBatch(r.Context(), metrics); err != nil {
+			h.log.Sugar().Errorln("failed to write remote write metrics", zap.Error(err))
+			http.Error(w, "failed to store metrics", http.StatusInternalServerError)
+			return
+		}
+	}
+	pending.Commit()
+	w.WriteHeader(http.StatusNoContent)
+}
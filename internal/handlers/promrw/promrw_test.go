@@ -0,0 +1,186 @@
+package promrw_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/handlers/promrw"
+	promrwIngest "github.com/zetcan333/metrics-collector/internal/ingest/promrw"
+	"github.com/zetcan333/metrics-collector/internal/lib/zapdiscard"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/internal/repo/storage/mem"
+	"github.com/zetcan333/metrics-collector/internal/usecase"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// series кодирует TimeSeries: метки парами имя/значение и значения сэмплов
+func series(labels []string, values ...float64) []byte {
+	var ts []byte
+	for i := 0; i+1 < len(labels); i += 2 {
+		var l []byte
+		l = protowire.AppendTag(l, 1, protowire.BytesType)
+		l = protowire.AppendString(l, labels[i])
+		l = protowire.AppendTag(l, 2, protowire.BytesType)
+		l = protowire.AppendString(l, labels[i+1])
+		ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, l)
+	}
+	for i, v := range values {
+		var s []byte
+		s = protowire.AppendTag(s, 1, protowire.Fixed64Type)
+		s = protowire.AppendFixed64(s, math.Float64bits(v))
+		s = protowire.AppendTag(s, 2, protowire.VarintType)
+		s = protowire.AppendVarint(s, uint64(1700000000000+i))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, s)
+	}
+	var req []byte
+	req = protowire.AppendTag(req, 1, protowire.BytesType)
+	return protowire.AppendBytes(req, ts)
+}
+
+func TestWrite(t *testing.T) {
+	ctx := context.Background()
+	uc := usecase.NewSeverUsecase(mem.NewStorage())
+	converter, err := promrwIngest.NewConverter([]string{"*_total"})
+	require.NoError(t, err)
+	h := promrw.New(zapdiscard.NewDiscardLogger(), uc, converter)
+
+	write := func(parts ...[]byte) int {
+		rr := httptest.NewRecorder()
+		body := snappy.Encode(nil, bytes.Join(parts, nil))
+		h.Write(rr, httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(body)))
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, write(
+		series([]string{"__name__", "temperature", "room", "a"}, 20, 21.5),
+		series([]string{"__name__", "http_requests_total", "code", "200"}, 100),
+		series([]string{"job", "no-name"}, 1),
+	))
+	assert.Equal(t, http.StatusNoContent, write(
+		series([]string{"__name__", "http_requests_total", "code", "200"}, 130, 5, math.NaN()),
+	))
+
+	value, err := uc.GetMetric(ctx, "gauge", `temperature{room="a"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "21.5", value)
+
+	// 100 — точка отсчёта, затем 30 до сброса + 5 после
+	value, err = uc.GetMetric(ctx, "counter", `http_requests_total{code="200"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "35", value)
+
+	rr := httptest.NewRecorder()
+	h.Write(rr, httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader([]byte("not snappy"))))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	assert.Equal(t, http.StatusBadRequest, write([]byte{0x0a, 0x05, 0x01}))
+}
+
+func TestWriteRejectsOversizedDecodedLength(t *testing.T) {
+	converter, err := promrwIngest.NewConverter(nil)
+	require.NoError(t, err)
+	h := promrw.New(zapdiscard.NewDiscardLogger(), usecase.NewSeverUsecase(mem.NewStorage()), converter)
+
+	// Заголовок snappy объявляет ~4 ГБ распакованных данных при теле в несколько байт
+	body := binary.AppendUvarint(nil, 1<<32-1)
+	rr := httptest.NewRecorder()
+	h.Write(rr, httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+type failingUpdater struct {
+	*usecase.SeverUsecase
+	fail bool
+}
+
+func (u *failingUpdater) UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error {
+	if u.fail {
+		return errors.New("storage unavailable")
+	}
+	return u.SeverUsecase.UpdateMetricsWithBatch(ctx, metrics)
+}
+
+func TestWriteRetryAfterStorageError(t *testing.T) {
+	ctx := context.Background()
+	uc := usecase.NewSeverUsecase(mem.NewStorage())
+	updater := &failingUpdater{SeverUsecase: uc}
+	converter, err := promrwIngest.NewConverter([]string{"*_total"})
+	require.NoError(t, err)
+	h := promrw.New(zapdiscard.NewDiscardLogger(), updater, converter)
+
+	write := func(values ...float64) int {
+		rr := httptest.NewRecorder()
+		body := snappy.Encode(nil, series([]string{"__name__", "jobs_total"}, values...))
+		h.Write(rr, httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(body)))
+		return rr.Code
+	}
+
+	require.Equal(t, http.StatusNoContent, write(10))
+	updater.fail = true
+	require.Equal(t, http.StatusInternalServerError, write(25))
+
+	// Повтор того же запроса после восстановления хранилища сохраняет приращение
+	updater.fail = false
+	require.Equal(t, http.StatusNoContent, write(25))
+
+	value, err := uc.GetMetric(ctx, "counter", "jobs_total")
+	require.NoError(t, err)
+	assert.Equal(t, "15", value)
+}
+
+// blockingUpdater задерживает запись, пока тест не закроет release
+type blockingUpdater struct {
+	*usecase.SeverUsecase
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (u *blockingUpdater) UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error {
+	close(u.entered)
+	<-u.release
+	return u.SeverUsecase.UpdateMetricsWithBatch(ctx, metrics)
+}
+
+func TestWriteConcurrentSameSeries(t *testing.T) {
+	ctx := context.Background()
+	uc := usecase.NewSeverUsecase(mem.NewStorage())
+	converter, err := promrwIngest.NewConverter([]string{"*_total"})
+	require.NoError(t, err)
+
+	write := func(h *promrw.RemoteWriteHandler, value float64) int {
+		rr := httptest.NewRecorder()
+		body := snappy.Encode(nil, series([]string{"__name__", "jobs_total"}, value))
+		h.Write(rr, httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(body)))
+		return rr.Code
+	}
+	h := promrw.New(zapdiscard.NewDiscardLogger(), uc, converter)
+	require.Equal(t, http.StatusNoContent, write(h, 10))
+
+	// Первый запрос ещё пишет в хранилище, второй посчитал бы дельту от того же значения
+	blocking := &blockingUpdater{SeverUsecase: uc, entered: make(chan struct{}), release: make(chan struct{})}
+	blocked := promrw.New(zapdiscard.NewDiscardLogger(), blocking, converter)
+	done := make(chan int)
+	go func() { done <- write(blocked, 25) }()
+	<-blocking.entered
+
+	assert.Equal(t, http.StatusServiceUnavailable, write(h, 25))
+	close(blocking.release)
+	require.Equal(t, http.StatusNoContent, <-done)
+
+	// Повтор отклонённого запроса уже ничего не прибавляет
+	require.Equal(t, http.StatusNoContent, write(h, 25))
+	value, err := uc.GetMetric(ctx, "counter", "jobs_total")
+	require.NoError(t, err)
+	assert.Equal(t, "15", value)
+}
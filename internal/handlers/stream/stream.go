@@ -0,0 +1,88 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/internal/usecase/stream"
+)
+
+const keepAlivePeriod = 15 * time.Second
+
+type StreamHandler struct {
+	hub *stream.Hub
+}
+
+func New(hub *stream.Hub) *StreamHandler {
+	return &StreamHandler{hub: hub}
+}
+
+// Stream отдаёт принятые обновления метрик как Server-Sent Events.
+// Параметры: match — шаблон имени метрики, как в списке метрик (glob или "re:" и регулярное
+// выражение), type — список типов через запятую.
+func (h *StreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	pattern, err := models.MetricsFilter{Match: r.URL.Query().Get("match")}.MatchRegexp()
+	if err != nil {
+		http.Error(w, "Invalid match pattern", http.StatusBadRequest)
+		return
+	}
+	filter := stream.Filter{Tenant: tenant.FromContext(r.Context())}
+	if pattern != "" {
+		filter.Pattern = regexp.MustCompile(pattern)
+	}
+	if types := r.URL.Query().Get("type"); types != "" {
+		filter.Types = make(map[string]bool)
+		for _, t := range strings.Split(types, ",") {
+			filter.Types[strings.TrimSpace(t)] = true
+		}
+	}
+
+	sub := h.hub.Subscribe(filter)
+	defer h.hub.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAlivePeriod)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-sub.Ready():
+			for _, metric := range sub.Drain() {
+				data, err := json.Marshal(metric)
+				if err != nil {
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "event: update\ndata: %s\n\n", data); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
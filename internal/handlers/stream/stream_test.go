@@ -0,0 +1,71 @@
+package stream_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	hStream "github.com/zetcan333/metrics-collector/internal/handlers/stream"
+	"github.com/zetcan333/metrics-collector/internal/repo/storage/mem"
+	"github.com/zetcan333/metrics-collector/internal/usecase"
+	"github.com/zetcan333/metrics-collector/internal/usecase/stream"
+)
+
+func TestStreamMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		match string
+		want  string
+	}{
+		{name: "glob", match: "Poll*", want: `"id":"PollCount"`},
+		{name: "regexp", match: `re:^Poll(Count|Gauge)$`, want: `"id":"PollCount"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := stream.NewHub(stream.DefaultMaxPending)
+			uc := usecase.NewSeverUsecase(mem.NewStorage(), usecase.WithUpdateObservers(hub))
+			srv := httptest.NewServer(http.HandlerFunc(hStream.New(hub).Stream))
+			t.Cleanup(srv.Close)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"?match="+url.QueryEscape(tt.match), nil)
+			require.NoError(t, err)
+			resp, err := srv.Client().Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			// Подписка создаётся до ответа, поэтому обновления после заголовков не теряются
+			require.NoError(t, uc.UpdateMetric(context.Background(), "gauge", "Alloc", "1"))
+			require.NoError(t, uc.UpdateMetric(context.Background(), "counter", "PollCount", "1"))
+
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+					assert.Contains(t, data, tt.want)
+					return
+				}
+			}
+			t.Fatal("Обновление не получено")
+		})
+	}
+}
+
+func TestStreamInvalidMatch(t *testing.T) {
+	h := hStream.New(stream.NewHub(stream.DefaultMaxPending))
+
+	for _, match := range []string{"re:(", "[a"} {
+		r := httptest.NewRequest(http.MethodGet, "/stream?match="+url.QueryEscape(match), nil)
+		w := httptest.NewRecorder()
+		h.Stream(w, r)
+		assert.Equal(t, http.StatusBadRequest, w.Code, match)
+	}
+}
@@ -0,0 +1,252 @@
+package cumulative
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultTTL — сколько хранится состояние серии, не получавшей значений. Серия, пришедшая
+// после удаления состояния, снова считается неизвестной, и её значение становится точкой отсчёта.
+const DefaultTTL = time.Hour
+
+type series struct {
+	start uint64
+	value float64
+	rest  float64 // дробный остаток, ещё не попавший в целую дельту
+	seen  time.Time
+}
+
+// Counters переводит кумулятивные значения счётчиков в целые дельты для хранилища.
+// Состояние живёт только в памяти, поэтому первое значение неизвестной серии — лишь точка
+// отсчёта: иначе после перезапуска или удаления по TTL весь накопленный итог прибавился бы
+// к сохранённому счётчику повторно. Уменьшение значения или смена времени начала известной
+// серии считаются сбросом, и дельтой становится всё значение.
+// Серии, не обновлявшиеся дольше TTL, удаляются, чтобы метки от клиентов не копили память.
+type Counters struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	series map[string]series
+	busy   map[string]struct{} // серии незавершённых наборов изменений
+	pruned time.Time
+}
+
+// ErrBusy — серию уже меняет другой незавершённый запрос. Оба запроса посчитали бы дельту
+// от одного и того же значения, поэтому второй нужно отклонить, чтобы клиент его повторил.
+var ErrBusy = errors.New("series is being updated by a concurrent request")
+
+func NewCounters() *Counters {
+	return &Counters{ttl: DefaultTTL, series: make(map[string]series), busy: make(map[string]struct{}), pruned: time.Now()}
+}
+
+// Delta возвращает прирост серии key и сразу запоминает значение; start — время начала
+// серии, 0 если неизвестно. ok=false, если целая дельта нулевая.
+// Delta, DeltaFromZero и Add не учитывают наборы изменений Begin: на одном Counters
+// нужно использовать что-то одно.
+func (c *Counters) Delta(key string, start uint64, value float64) (int64, bool) {
+	return c.apply(key, func(s series, seen bool) (series, float64) {
+		return advance(s, seen, start, value, false)
+	})
+}
+
+// DeltaFromZero — как Delta, но первое значение неизвестной серии целиком становится дельтой.
+// Только для серий, которые заведомо начались с нуля после запуска вызывающего.
+func (c *Counters) DeltaFromZero(key string, start uint64, value float64) (int64, bool) {
+	return c.apply(key, func(s series, seen bool) (series, float64) {
+		return advance(s, seen, start, value, true)
+	})
+}
+
+// Add учитывает уже готовую дельту, перенося дробный остаток между вызовами
+func (c *Counters) Add(key string, delta float64) (int64, bool) {
+	return c.apply(key, func(s series, _ bool) (series, float64) {
+		return s, delta
+	})
+}
+
+// apply читает и меняет серию под одной блокировкой
+func (c *Counters) apply(key string, fn func(s series, seen bool) (series, float64)) (int64, bool) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, seen := c.series[key]
+	s, delta := fn(s, seen)
+	s, whole, ok := take(s, delta)
+	s.seen = now
+	c.series[key] = s
+	c.maybePrune(now)
+	return whole, ok
+}
+
+// Begin начинает набор изменений. Приёмники применяют его через Commit только после
+// успешной записи в хранилище: повтор отклонённого запроса даёт те же дельты. До Commit
+// или Discard серии набора заняты, и другой набор, затронувший их, получает ErrBusy.
+func (c *Counters) Begin() *Pending {
+	return &Pending{counters: c, updates: make(map[string]series), reserved: make(map[string]struct{})}
+}
+
+// Prune удаляет серии, не обновлявшиеся с момента before, и возвращает их число
+func (c *Counters) Prune(before time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.prune(before)
+}
+
+func (c *Counters) prune(before time.Time) int {
+	n := 0
+	for key, s := range c.series {
+		if s.seen.Before(before) {
+			delete(c.series, key)
+			n++
+		}
+	}
+	return n
+}
+
+// maybePrune раз в TTL удаляет устаревшие серии; вызывается под c.mu
+func (c *Counters) maybePrune(now time.Time) {
+	if now.Sub(c.pruned) >= c.ttl {
+		c.prune(now.Add(-c.ttl))
+		c.pruned = now
+	}
+}
+
+// Pending — изменения серий, ещё не применённые к Counters. Значения внутри набора
+// учитывают друг друга, так что несколько точек одной серии в запросе дают верные дельты.
+type Pending struct {
+	counters *Counters
+	updates  map[string]series
+	reserved map[string]struct{}
+	err      error
+}
+
+func (p *Pending) Delta(key string, start uint64, value float64) (int64, bool) {
+	s, seen := p.get(key)
+	s, delta := advance(s, seen, start, value, false)
+	return p.take(key, s, delta)
+}
+
+func (p *Pending) DeltaFromZero(key string, start uint64, value float64) (int64, bool) {
+	s, seen := p.get(key)
+	s, delta := advance(s, seen, start, value, true)
+	return p.take(key, s, delta)
+}
+
+func (p *Pending) Add(key string, delta float64) (int64, bool) {
+	s, _ := p.get(key)
+	return p.take(key, s, delta)
+}
+
+// Reserve занимает ключ key до Commit или Discard, как это делают Delta и Add.
+// Нужен вызывающим, которые хранят состояние серии сами, например гистограммы OTLP.
+func (p *Pending) Reserve(key string) {
+	if _, ok := p.reserved[key]; ok {
+		return
+	}
+	c := p.counters
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p.reserve(key)
+}
+
+// Err возвращает ErrBusy, если часть серий набора занята другим запросом.
+// Такой набор нельзя записывать в хранилище: его нужно отменить через Discard.
+func (p *Pending) Err() error {
+	return p.err
+}
+
+// Commit применяет изменения и освобождает серии; заодно раз в TTL удаляются устаревшие
+// серии. Набор с ошибкой Err не применяется.
+func (p *Pending) Commit() {
+	c := p.counters
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p.err == nil {
+		for key, s := range p.updates {
+			s.seen = now
+			c.series[key] = s
+		}
+	}
+	p.release()
+	c.maybePrune(now)
+}
+
+// Discard освобождает серии набора, не применяя изменений; повторный вызов безопасен
+func (p *Pending) Discard() {
+	c := p.counters
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p.release()
+}
+
+func (p *Pending) get(key string) (series, bool) {
+	if s, ok := p.updates[key]; ok {
+		return s, true
+	}
+	c := p.counters
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p.reserve(key)
+	s, ok := c.series[key]
+	return s, ok
+}
+
+// reserve занимает ключ для набора; вызывается под c.mu
+func (p *Pending) reserve(key string) {
+	if _, ok := p.reserved[key]; ok {
+		return
+	}
+	c := p.counters
+	if _, busy := c.busy[key]; busy {
+		p.err = ErrBusy
+		return
+	}
+	c.busy[key] = struct{}{}
+	p.reserved[key] = struct{}{}
+}
+
+// release освобождает занятые набором ключи; вызывается под c.mu
+func (p *Pending) release() {
+	for key := range p.reserved {
+		delete(p.counters.busy, key)
+	}
+	clear(p.reserved)
+}
+
+func (p *Pending) take(key string, s series, delta float64) (int64, bool) {
+	s, whole, ok := take(s, delta)
+	p.updates[key] = s
+	return whole, ok
+}
+
+// advance возвращает новое состояние серии и дробную дельту для значения value
+func advance(s series, seen bool, start uint64, value float64, fromZero bool) (series, float64) {
+	var delta float64
+	switch {
+	case !seen:
+		if fromZero {
+			delta = value
+		}
+	case s.start != start || value < s.value:
+		delta = value
+	default:
+		delta = value - s.value
+	}
+	s.start, s.value = start, value
+	return s, delta
+}
+
+// take добавляет к дельте дробный остаток серии и отделяет целую часть
+func take(s series, delta float64) (series, int64, bool) {
+	delta += s.rest
+	whole := math.Trunc(delta)
+	s.rest = delta - whole
+	if whole == 0 {
+		return s, 0, false
+	}
+	return s, int64(whole), true
+}
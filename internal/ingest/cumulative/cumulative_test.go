@@ -0,0 +1,140 @@
+package cumulative_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/ingest/cumulative"
+)
+
+type point struct {
+	start uint64
+	value float64
+}
+
+func TestDelta(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []point
+		want   []int64 // 0 — нет целой дельты
+	}{
+		{name: "первое значение — точка отсчёта", points: []point{{1, 10}}, want: []int64{0}},
+		{name: "прирост", points: []point{{1, 10}, {1, 15}, {1, 15}}, want: []int64{0, 5, 0}},
+		{name: "сброс по времени начала", points: []point{{1, 10}, {2, 3}}, want: []int64{0, 3}},
+		{name: "уменьшение значения", points: []point{{0, 10}, {0, 4}, {0, 6}}, want: []int64{0, 4, 2}},
+		{name: "перенос дробного остатка", points: []point{{1, 0.4}, {1, 1.2}, {1, 2.5}}, want: []int64{0, 0, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cumulative.NewCounters()
+			for i, p := range tt.points {
+				delta, ok := c.Delta("requests", p.start, p.value)
+				assert.Equal(t, tt.want[i] != 0, ok, "point %d", i)
+				assert.Equal(t, tt.want[i], delta, "point %d", i)
+			}
+		})
+	}
+}
+
+func TestDeltaFromZero(t *testing.T) {
+	c := cumulative.NewCounters()
+	delta, ok := c.DeltaFromZero("requests", 1, 10)
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), delta, "Первое значение новой серии целиком")
+
+	delta, _ = c.DeltaFromZero("requests", 1, 15)
+	assert.Equal(t, int64(5), delta)
+}
+
+func TestRestart(t *testing.T) {
+	c := cumulative.NewCounters()
+	c.Delta("requests", 1, 100)
+	delta, _ := c.Delta("requests", 1, 130)
+	assert.Equal(t, int64(30), delta)
+
+	// Состояние живёт только в памяти: после перезапуска итог не прибавляется повторно
+	c = cumulative.NewCounters()
+	_, ok := c.Delta("requests", 1, 140)
+	assert.False(t, ok)
+	delta, _ = c.Delta("requests", 1, 145)
+	assert.Equal(t, int64(5), delta)
+}
+
+func TestAdd(t *testing.T) {
+	c := cumulative.NewCounters()
+	var got []int64
+	for _, d := range []float64{0.5, 0.7, 2, -0.4} {
+		delta, _ := c.Add("bytes", d)
+		got = append(got, delta)
+	}
+	assert.Equal(t, []int64{0, 1, 2, 0}, got)
+}
+
+func TestPendingCommit(t *testing.T) {
+	c := cumulative.NewCounters()
+	c.Delta("requests", 0, 100)
+
+	// Незафиксированный набор не меняет состояние: повтор даёт ту же дельту
+	for range 2 {
+		p := c.Begin()
+		delta, _ := p.Delta("requests", 0, 130)
+		assert.Equal(t, int64(30), delta)
+		delta, _ = p.Delta("requests", 0, 135)
+		assert.Equal(t, int64(5), delta, "Точки одного набора учитывают друг друга")
+		p.Discard()
+	}
+
+	p := c.Begin()
+	p.Delta("requests", 0, 135)
+	p.Commit()
+	_, ok := c.Delta("requests", 0, 135)
+	assert.False(t, ok)
+}
+
+func TestPendingBusy(t *testing.T) {
+	c := cumulative.NewCounters()
+	first := c.Begin()
+	first.Delta("requests", 0, 100)
+	first.Commit()
+
+	// Два одновременных запроса посчитали бы дельту от одного значения: второй отклоняется
+	p1, p2 := c.Begin(), c.Begin()
+	delta, _ := p1.Delta("requests", 0, 130)
+	assert.Equal(t, int64(30), delta)
+	p2.Delta("requests", 0, 130)
+	p2.Delta("other", 0, 1)
+	require.NoError(t, p1.Err())
+	assert.ErrorIs(t, p2.Err(), cumulative.ErrBusy)
+	p1.Commit()
+	p2.Commit()
+
+	// Отклонённый набор не применился и освободил свои серии: повтор считает от нового значения
+	p3 := c.Begin()
+	delta, _ = p3.Delta("requests", 0, 130)
+	assert.Zero(t, delta)
+	p3.Delta("other", 0, 1)
+	require.NoError(t, p3.Err())
+	p3.Discard()
+
+	// Reserve занимает серии, состояние которых вызывающий хранит сам
+	p4, p5 := c.Begin(), c.Begin()
+	p4.Reserve("histogram")
+	p5.Reserve("histogram")
+	assert.NoError(t, p4.Err())
+	assert.ErrorIs(t, p5.Err(), cumulative.ErrBusy)
+	p4.Discard()
+	p5.Discard()
+}
+
+func TestPrune(t *testing.T) {
+	c := cumulative.NewCounters()
+	c.Delta("old", 0, 10)
+	assert.Zero(t, c.Prune(time.Now().Add(-time.Minute)))
+	assert.Equal(t, 1, c.Prune(time.Now().Add(time.Minute)))
+
+	_, ok := c.Delta("old", 0, 12)
+	assert.False(t, ok, "Удалённая серия снова начинается с точки отсчёта")
+}
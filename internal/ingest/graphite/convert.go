@@ -0,0 +1,77 @@
+package graphite
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/zetcan333/metrics-collector/internal/ingest/cumulative"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+const source = "graphite"
+
+// Converter переводит точки в метрики: путь становится ID (после шаблона — имя и метки),
+// значения записываются как gauge, а для имён из правил — как приращения counter.
+type Converter struct {
+	templates    []Template
+	counterRules []*regexp.Regexp
+	counters     *cumulative.Counters
+}
+
+// NewConverter принимает правила счётчиков в синтаксисе параметра match: glob или re:<regexp>
+func NewConverter(templates []Template, counterRules []string) (*Converter, error) {
+	const op = "internal.ingest.graphite.NewConverter"
+
+	c := &Converter{templates: templates, counters: cumulative.NewCounters()}
+	for _, rule := range counterRules {
+		expr, err := models.MetricsFilter{Match: rule}.MatchRegexp()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		c.counterRules = append(c.counterRules, regexp.MustCompile(expr))
+	}
+	return c, nil
+}
+
+// Convert возвращает false для дробного приращения счётчика, которое ещё не набрало единицу
+func (c *Converter) Convert(p Point) (models.Metrics, bool) {
+	name, labels := p.Path, map[string]string{}
+	segments := strings.Split(p.Path, ".")
+	for _, t := range c.templates {
+		if !t.match(segments) {
+			continue
+		}
+		// Путь короче шаблона может не дойти до measurement — тогда он остаётся именем целиком
+		if n, l := t.apply(segments); n != "" {
+			name, labels = n, l
+		}
+		break
+	}
+	for k, v := range p.Tags {
+		labels[k] = v
+	}
+
+	m := models.Metrics{ID: models.FormatID(name, labels), Meta: &models.MetricMeta{Source: source}}
+	if !c.isCounter(name) {
+		value := p.Value
+		m.MType, m.Value = models.Gauge, &value
+		return m, true
+	}
+
+	delta, ok := c.counters.Add(m.ID, p.Value)
+	if !ok {
+		return models.Metrics{}, false
+	}
+	m.MType, m.Delta = models.Counter, &delta
+	return m, true
+}
+
+func (c *Converter) isCounter(name string) bool {
+	for _, re := range c.counterRules {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
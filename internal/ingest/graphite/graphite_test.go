@@ -0,0 +1,107 @@
+package graphite_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/ingest/graphite"
+	"github.com/zetcan333/metrics-collector/internal/lib/zapdiscard"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/internal/repo/storage/mem"
+	"github.com/zetcan333/metrics-collector/internal/usecase"
+)
+
+func TestParseLine(t *testing.T) {
+	p, err := graphite.ParseLine("servers.web1.cpu.load 0.75 1700000000")
+	require.NoError(t, err)
+	assert.Equal(t, graphite.Point{Path: "servers.web1.cpu.load", Value: 0.75, Timestamp: 1700000000}, p)
+
+	p, err = graphite.ParseLine("disk.used;host=a;mount=/ 42")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"host": "a", "mount": "/"}, p.Tags)
+	assert.Equal(t, int64(-1), p.Timestamp)
+
+	for _, line := range []string{"only.path", "a..b 1", "a.b x", "a.b 1 now", "a;tag 1", "a.b 1 2 3"} {
+		_, err := graphite.ParseLine(line)
+		assert.ErrorIs(t, err, graphite.ErrInvalidLine, line)
+	}
+}
+
+func TestConvertTemplates(t *testing.T) {
+	templates, err := graphite.ParseTemplates(strings.NewReader(`
+# фильтр шаблон теги
+servers.* .host.measurement*
+cron.*.*  .job.measurement env=prod
+region.host.measurement
+`))
+	require.NoError(t, err)
+	require.Len(t, templates, 3)
+
+	c, err := graphite.NewConverter(templates, []string{"processed"})
+	require.NoError(t, err)
+
+	for path, id := range map[string]string{
+		"servers.web1.cpu.load": `cpu.load{host="web1"}`,
+		"cron.backup.duration":  `duration{env="prod",job="backup"}`,
+		"eu.db1.uptime":         `uptime{host="db1",region="eu"}`,
+		"short":                 "short",
+	} {
+		m, ok := c.Convert(graphite.Point{Path: path, Value: 1})
+		require.True(t, ok)
+		assert.Equal(t, id, m.ID)
+		assert.Equal(t, models.Gauge, m.MType)
+	}
+
+	m, ok := c.Convert(graphite.Point{Path: "cron.backup.processed", Value: 1.5})
+	require.True(t, ok)
+	assert.Equal(t, models.Counter, m.MType)
+	assert.Equal(t, int64(1), *m.Delta)
+
+	_, err = graphite.ParseTemplates(strings.NewReader("servers.* .host"))
+	assert.Error(t, err, "Шаблон без measurement")
+	_, err = graphite.ParseTemplates(strings.NewReader("measurement*.host"))
+	assert.Error(t, err)
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	uc := usecase.NewSeverUsecase(mem.NewStorage())
+	c, err := graphite.NewConverter(nil, []string{"jobs.*.processed"})
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	srv := graphite.NewServer(zapdiscard.NewDiscardLogger(), uc, c, addr)
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- srv.Run(runCtx) }()
+
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		conn, err = net.Dial("tcp", addr)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	_, err = conn.Write([]byte("jobs.backup.duration 12.5 1700000000\njobs.backup.processed 3\nbroken\njobs.backup.processed 4\n"))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	value, err := uc.GetMetric(ctx, "gauge", "jobs.backup.duration")
+	require.NoError(t, err)
+	assert.Equal(t, "12.5", value)
+
+	value, err = uc.GetMetric(ctx, "counter", "jobs.backup.processed")
+	require.NoError(t, err)
+	assert.Equal(t, "7", value)
+}
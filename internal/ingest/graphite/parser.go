@@ -0,0 +1,60 @@
+package graphite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidLine = errors.New("invalid graphite line")
+
+// Point — строка plaintext-протокола "path value [timestamp]"
+type Point struct {
+	Path      string
+	Tags      map[string]string // теги формата Graphite 1.1: path;tag=value
+	Value     float64
+	Timestamp int64 // секунды; -1, если не задана
+}
+
+// ParseLine разбирает строку. Метка времени проверяется, но хранилище фиксирует время приёма.
+func ParseLine(line string) (Point, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 && len(fields) != 3 {
+		return Point{}, fmt.Errorf("%w: expected \"path value [timestamp]\"", ErrInvalidLine)
+	}
+
+	p := Point{Timestamp: -1}
+	path, tags, _ := strings.Cut(fields[0], ";")
+	if path == "" || strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") || strings.Contains(path, "..") {
+		return Point{}, fmt.Errorf("%w: invalid path %q", ErrInvalidLine, path)
+	}
+	p.Path = path
+
+	if tags != "" {
+		p.Tags = make(map[string]string)
+		for _, tag := range strings.Split(tags, ";") {
+			k, v, ok := strings.Cut(tag, "=")
+			if !ok || k == "" || v == "" {
+				return Point{}, fmt.Errorf("%w: invalid tag %q", ErrInvalidLine, tag)
+			}
+			p.Tags[k] = v
+		}
+	}
+
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return Point{}, fmt.Errorf("%w: invalid value %q", ErrInvalidLine, fields[1])
+	}
+	p.Value = value
+
+	if len(fields) == 3 {
+		ts, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil || ts < -1 {
+			return Point{}, fmt.Errorf("%w: invalid timestamp %q", ErrInvalidLine, fields[2])
+		}
+		p.Timestamp = int64(ts)
+	}
+	return p, nil
+}
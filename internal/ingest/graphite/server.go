@@ -0,0 +1,116 @@
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/ingest"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	flushInterval = time.Second
+	maxBatchSize  = 1000
+)
+
+type Updater interface {
+	UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error
+}
+
+// Server принимает plaintext-протокол Graphite по TCP и записывает точки пачками
+type Server struct {
+	log       *zap.Logger
+	updater   Updater
+	converter *Converter
+	addr      string
+
+	mu      sync.Mutex
+	pending []models.Metrics
+}
+
+func NewServer(log *zap.Logger, updater Updater, converter *Converter, addr string) *Server {
+	return &Server{log: log, updater: updater, converter: converter, addr: addr}
+}
+
+// Run слушает адрес до отмены контекста; при остановке записывает накопленное
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.log.Sugar().Infoln("Graphite listener on", ln.Addr())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := ingest.ServeConns(ctx, ln, func(conn net.Conn) { s.handleConn(ctx, conn) })
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			s.log.Sugar().Errorln("graphite listener stopped", zap.Error(err))
+		}
+	}()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush(ctx)
+		case <-ctx.Done():
+			ln.Close()
+			<-done
+			s.flush(context.WithoutCancel(ctx))
+			return nil
+		}
+	}
+}
+
+func (s *Server) handleConn(ctx context.Context, conn net.Conn) {
+	log := s.log.With(zap.String("remote", conn.RemoteAddr().String()))
+
+	scanner := bufio.NewScanner(conn)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		point, err := ParseLine(line)
+		if err != nil {
+			log.Error("graphite: invalid line", zap.Int("line", n), zap.String("text", line), zap.Error(err))
+			continue
+		}
+		if metric, ok := s.converter.Convert(point); ok {
+			s.add(ctx, metric)
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Error("graphite: connection read failed", zap.Error(err))
+	}
+}
+
+func (s *Server) add(ctx context.Context, metric models.Metrics) {
+	s.mu.Lock()
+	s.pending = append(s.pending, metric)
+	full := len(s.pending) >= maxBatchSize
+	s.mu.Unlock()
+
+	if full {
+		s.flush(context.WithoutCancel(ctx))
+	}
+}
+
+func (s *Server) flush(ctx context.Context) {
+	s.mu.Lock()
+	metrics := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	if err := ingest.WriteBatch(ctx, s.updater, metrics); err != nil {
+		s.log.Sugar().Errorln("graphite: failed to write metrics", zap.Error(err))
+	}
+}
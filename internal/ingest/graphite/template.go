@@ -0,0 +1,164 @@
+package graphite
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	partMeasurement = "measurement"
+	partRest        = "measurement*"
+)
+
+// Template выделяет из пути имя метрики и теги. Части шаблона соответствуют
+// сегментам пути: "measurement" входит в имя, "measurement*" забирает все
+// оставшиеся сегменты, пустая часть пропускает сегмент, любое другое слово
+// становится именем тега. Сегменты за концом шаблона отбрасываются.
+type Template struct {
+	filter []string
+	parts  []string
+	tags   map[string]string
+}
+
+// LoadTemplates читает шаблоны из файла
+func LoadTemplates(file string) ([]Template, error) {
+	const op = "internal.ingest.graphite.LoadTemplates"
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	templates, err := ParseTemplates(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return templates, nil
+}
+
+// ParseTemplates разбирает шаблоны по одному в строке:
+//
+//	# [фильтр] шаблон [тег=значение,...]
+//	servers.* .host.measurement*
+//	cron.*.*  .job.measurement env=prod
+//	measurement.measurement.host
+//
+// Фильтр сравнивается с началом пути посегментно, * подходит под любой сегмент.
+// Строка без фильтра подходит под любой путь. Применяется первый подошедший шаблон.
+func ParseTemplates(r io.Reader) ([]Template, error) {
+	var templates []Template
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		t, err := parseTemplate(strings.Fields(line))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		templates = append(templates, t)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func parseTemplate(fields []string) (Template, error) {
+	var t Template
+	if n := len(fields); n > 1 && strings.Contains(fields[n-1], "=") {
+		tags, err := parseTags(fields[n-1])
+		if err != nil {
+			return Template{}, err
+		}
+		t.tags, fields = tags, fields[:n-1]
+	}
+	switch len(fields) {
+	case 1:
+		t.parts = strings.Split(fields[0], ".")
+	case 2:
+		t.filter, t.parts = strings.Split(fields[0], "."), strings.Split(fields[1], ".")
+	default:
+		return Template{}, fmt.Errorf("expected \"[filter] template [tag=value,...]\"")
+	}
+
+	for _, segment := range t.filter {
+		if _, err := path.Match(segment, ""); err != nil {
+			return Template{}, fmt.Errorf("invalid filter segment %q", segment)
+		}
+	}
+	hasName := false
+	for i, part := range t.parts {
+		if part == partRest && i != len(t.parts)-1 {
+			return Template{}, fmt.Errorf("%s must be the last part", partRest)
+		}
+		hasName = hasName || part == partMeasurement || part == partRest
+	}
+	if !hasName {
+		return Template{}, fmt.Errorf("template has no %s part", partMeasurement)
+	}
+	return t, nil
+}
+
+func parseTags(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(tag, "=")
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("invalid default tag %q", tag)
+		}
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+func (t Template) match(segments []string) bool {
+	if len(t.filter) > len(segments) {
+		return false
+	}
+	for i, f := range t.filter {
+		if ok, _ := path.Match(f, segments[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (t Template) apply(segments []string) (string, map[string]string) {
+	var name []string
+	tags := make(map[string]string, len(t.tags))
+	for k, v := range t.tags {
+		tags[k] = v
+	}
+
+	// Повторяющийся в шаблоне тег собирается из нескольких сегментов через точку
+	fromPath := make(map[string]bool)
+	for i, part := range t.parts {
+		if i >= len(segments) {
+			break
+		}
+		switch part {
+		case "":
+		case partMeasurement:
+			name = append(name, segments[i])
+		case partRest:
+			name = append(name, segments[i:]...)
+		default:
+			if fromPath[part] {
+				tags[part] += "." + segments[i]
+			} else {
+				tags[part], fromPath[part] = segments[i], true
+			}
+		}
+	}
+	return strings.Join(name, "."), tags
+}
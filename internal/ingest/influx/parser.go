@@ -0,0 +1,233 @@
+package influx
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/zetcan333/metrics-collector/internal/ingest/cumulative"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+var ErrInvalidLine = errors.New("invalid line protocol")
+
+type FieldKind int
+
+const (
+	KindFloat FieldKind = iota
+	KindInteger
+	KindUnsigned
+	KindBool
+	KindString
+)
+
+type Field struct {
+	Key   string
+	Kind  FieldKind
+	Float float64 // значение для KindFloat, KindUnsigned и KindBool (0/1)
+	Int   int64   // значение для KindInteger
+}
+
+// Point — одна строка: measurement[,tag=value...] field=value[,...] [timestamp]
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      []Field
+	Timestamp   int64
+	HasTime     bool
+}
+
+// ParseLine разбирает строку line protocol; пустые строки и комментарии дают (nil, nil)
+func ParseLine(line string) (*Point, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	series, rest, err := splitUnescaped(line, ' ', true)
+	if err != nil {
+		return nil, err
+	}
+	fieldsPart, timestamp, err := splitUnescaped(strings.TrimLeft(rest, " "), ' ', true)
+	if err != nil {
+		return nil, err
+	}
+	if fieldsPart == "" {
+		return nil, fmt.Errorf("%w: missing fields", ErrInvalidLine)
+	}
+
+	point := &Point{}
+	if err := parseSeries(series, point); err != nil {
+		return nil, err
+	}
+	if err := parseFields(fieldsPart, point); err != nil {
+		return nil, err
+	}
+
+	if timestamp = strings.TrimSpace(timestamp); timestamp != "" {
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid timestamp %q", ErrInvalidLine, timestamp)
+		}
+		point.Timestamp, point.HasTime = ts, true
+	}
+	return point, nil
+}
+
+func parseSeries(series string, point *Point) error {
+	parts, err := splitAll(series, ',')
+	if err != nil {
+		return err
+	}
+	point.Measurement = unescape(parts[0])
+	if point.Measurement == "" {
+		return fmt.Errorf("%w: missing measurement", ErrInvalidLine)
+	}
+
+	for _, tag := range parts[1:] {
+		key, value, err := splitUnescaped(tag, '=', false)
+		if err != nil || key == "" || value == "" {
+			return fmt.Errorf("%w: invalid tag %q", ErrInvalidLine, tag)
+		}
+		if point.Tags == nil {
+			point.Tags = make(map[string]string)
+		}
+		point.Tags[unescape(key)] = unescape(value)
+	}
+	return nil
+}
+
+func parseFields(fields string, point *Point) error {
+	parts, err := splitAll(fields, ',')
+	if err != nil {
+		return err
+	}
+	for _, part := range parts {
+		key, raw, err := splitUnescaped(part, '=', false)
+		if err != nil || key == "" || raw == "" {
+			return fmt.Errorf("%w: invalid field %q", ErrInvalidLine, part)
+		}
+		field, err := parseFieldValue(unescape(key), raw)
+		if err != nil {
+			return err
+		}
+		point.Fields = append(point.Fields, field)
+	}
+	return nil
+}
+
+func parseFieldValue(key, raw string) (Field, error) {
+	field := Field{Key: key}
+	invalid := fmt.Errorf("%w: invalid value %q for field %q", ErrInvalidLine, raw, key)
+
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		if len(raw) < 2 || !strings.HasSuffix(raw, `"`) {
+			return Field{}, invalid
+		}
+		field.Kind = KindString
+	case strings.HasSuffix(raw, "i"):
+		v, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return Field{}, invalid
+		}
+		field.Kind, field.Int = KindInteger, v
+	case strings.HasSuffix(raw, "u"):
+		v, err := strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return Field{}, invalid
+		}
+		field.Kind, field.Float = KindUnsigned, float64(v)
+	default:
+		switch raw {
+		case "t", "T", "true", "True", "TRUE":
+			field.Kind, field.Float = KindBool, 1
+		case "f", "F", "false", "False", "FALSE":
+			field.Kind, field.Float = KindBool, 0
+		default:
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+				return Field{}, invalid
+			}
+			field.Kind, field.Float = KindFloat, v
+		}
+	}
+	return field, nil
+}
+
+// splitUnescaped делит s по первому неэкранированному sep (вне кавычек, если quotes)
+func splitUnescaped(s string, sep byte, quotes bool) (string, string, error) {
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case quotes && c == '"':
+			inQuotes = !inQuotes
+		case c == sep && !inQuotes:
+			return s[:i], s[i+1:], nil
+		}
+	}
+	if inQuotes {
+		return "", "", fmt.Errorf("%w: unterminated string", ErrInvalidLine)
+	}
+	return s, "", nil
+}
+
+// splitAll делит s по всем неэкранированным sep вне кавычек
+func splitAll(s string, sep byte) ([]string, error) {
+	var parts []string
+	for {
+		head, tail, err := splitUnescaped(s, sep, true)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, head)
+		if len(tail) == 0 && len(head) == len(s) {
+			return parts, nil
+		}
+		s = tail
+	}
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Metrics превращает поля точки в метрики с ID вида measurement_field{tag="value"}.
+// Если передан counters, целые поля (суффикс i) становятся counter: как и у Telegraf,
+// они считаются накопительными и переводятся в дельты по сериям арендатора tenant.
+// Остальные поля — gauge; строковые поля пропускаются.
+func (p *Point) Metrics(counters *cumulative.Pending, tenant string) []models.Metrics {
+	metrics := make([]models.Metrics, 0, len(p.Fields))
+	for _, field := range p.Fields {
+		id := models.FormatID(p.Measurement+"_"+field.Key, p.Tags)
+		switch {
+		case field.Kind == KindString:
+			continue
+		case field.Kind == KindInteger && counters != nil:
+			if delta, ok := counters.Delta(tenant+"\x00"+id, 0, float64(field.Int)); ok {
+				metrics = append(metrics, models.Metrics{ID: id, MType: models.Counter, Delta: &delta})
+			}
+		case field.Kind == KindInteger:
+			value := float64(field.Int)
+			metrics = append(metrics, models.Metrics{ID: id, MType: models.Gauge, Value: &value})
+		default:
+			value := field.Float
+			metrics = append(metrics, models.Metrics{ID: id, MType: models.Gauge, Value: &value})
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,55 @@
+package influx_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/ingest/cumulative"
+	"github.com/zetcan333/metrics-collector/internal/ingest/influx"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+func TestParseLine(t *testing.T) {
+	point, err := influx.ParseLine(`cpu\ load,host=server\ 1,region=eu usage=0.5,count=3i,up=t,msg="a, b=c" 1700000000000000000`)
+	require.NoError(t, err)
+	assert.Equal(t, "cpu load", point.Measurement)
+	assert.Equal(t, map[string]string{"host": "server 1", "region": "eu"}, point.Tags)
+	require.Len(t, point.Fields, 4)
+	assert.Equal(t, influx.Field{Key: "usage", Kind: influx.KindFloat, Float: 0.5}, point.Fields[0])
+	assert.Equal(t, influx.Field{Key: "count", Kind: influx.KindInteger, Int: 3}, point.Fields[1])
+	assert.Equal(t, influx.Field{Key: "up", Kind: influx.KindBool, Float: 1}, point.Fields[2])
+	assert.Equal(t, influx.KindString, point.Fields[3].Kind)
+	assert.True(t, point.HasTime)
+
+	counters := cumulative.NewCounters()
+	pending := counters.Begin()
+	require.Len(t, point.Metrics(pending, "default"), 2, "Первое значение целого поля — точка отсчёта")
+	pending.Commit()
+
+	point.Fields[1].Int = 5
+	metrics := point.Metrics(counters.Begin(), "default")
+	require.Len(t, metrics, 3)
+	assert.Equal(t, `cpu load_usage{host="server 1",region="eu"}`, metrics[0].ID)
+	assert.Equal(t, models.Counter, metrics[1].MType)
+	assert.Equal(t, int64(2), *metrics[1].Delta)
+	assert.Equal(t, models.Gauge, point.Metrics(nil, "default")[1].MType)
+
+	point, err = influx.ParseLine("# comment")
+	assert.NoError(t, err)
+	assert.Nil(t, point)
+
+	for _, bad := range []string{
+		"cpu",
+		"cpu usage=",
+		"cpu usage=abc",
+		"cpu,host usage=1",
+		`cpu msg="unterminated`,
+		"cpu usage=1 notatime",
+		"cpu usage=1,",
+		"cpu usage=NaN",
+	} {
+		_, err := influx.ParseLine(bad)
+		assert.ErrorIs(t, err, influx.ErrInvalidLine, bad)
+	}
+}
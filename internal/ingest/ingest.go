@@ -0,0 +1,59 @@
+// Package ingest содержит общий код приёмников сторонних протоколов.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+type Updater interface {
+	UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error
+}
+
+// WriteBatch записывает metrics одной пачкой. Пачка записывается атомарно, поэтому
+// при её отказе метрики пишутся по одной: одна плохая метрика не должна терять остальные.
+// Возвращает ошибки метрик, которые записать не удалось.
+func WriteBatch(ctx context.Context, updater Updater, metrics []models.Metrics) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	if err := updater.UpdateMetricsWithBatch(ctx, metrics); err == nil {
+		return nil
+	}
+	var errs []error
+	for _, metric := range metrics {
+		if err := updater.UpdateMetricsWithBatch(ctx, []models.Metrics{metric}); err != nil {
+			errs = append(errs, fmt.Errorf("metric %s: %w", metric.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ServeConns принимает TCP-соединения и обрабатывает каждое в своей горутине до закрытия ln.
+// При отмене ctx открытые соединения закрываются, чтобы остановка не ждала клиентов;
+// ServeConns возвращает ошибку Accept после завершения всех обработчиков.
+func ServeConns(ctx context.Context, ln net.Listener, handle func(conn net.Conn)) error {
+	var conns sync.WaitGroup
+	defer conns.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			defer conn.Close()
+
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			defer stop()
+
+			handle(conn)
+		}()
+	}
+}
@@ -0,0 +1,331 @@
+package otlp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/ingest/cumulative"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+const source = "otlp"
+
+// seriesState — последняя кумулятивная точка серии, нужна для перевода в дельты
+type seriesState struct {
+	start  uint64
+	counts []uint64
+	bounds []float64
+	sum    float64
+	seen   time.Time
+}
+
+// Converter переводит OTLP в метрики сервера:
+//   - Gauge и немонотонные кумулятивные Sum — gauge;
+//   - монотонные Sum и дельта-Sum — counter; кумулятивные значения переводятся
+//     в дельты относительно последней увиденной точки серии;
+//   - Histogram с явными границами — histogram (кумулятивные тоже через дельты).
+//
+// Первая кумулятивная точка неизвестной серии — точка отсчёта. Исключение — серия, время
+// начала которой не раньше запуска приёмника: её значение целиком накоплено после него.
+//
+// Атрибуты ресурса и точки становятся метками; значение атрибута ресурса
+// prefixAttr, если задан, становится префиксом имени.
+type Converter struct {
+	prefixAttr string
+	counters   *cumulative.Counters
+	started    uint64 // время создания в наносекундах Unix
+
+	mu     sync.Mutex
+	series map[string]seriesState // кумулятивные гистограммы
+	pruned time.Time
+}
+
+func NewConverter(prefixAttr string) *Converter {
+	now := time.Now()
+	return &Converter{
+		prefixAttr: prefixAttr,
+		counters:   cumulative.NewCounters(),
+		started:    uint64(now.UnixNano()),
+		series:     make(map[string]seriesState),
+		pruned:     now,
+	}
+}
+
+// Result — итог разбора запроса. Кумулятивные значения серий запоминаются только
+// вызовом Commit после успешной записи Metrics: повтор запроса клиентом после ошибки
+// хранилища должен дать те же дельты.
+type Result struct {
+	Metrics  []models.Metrics
+	Rejected int // отброшенные точки: неподдерживаемые типы и некорректные данные
+	Problems []string
+
+	converter  *Converter
+	counters   *cumulative.Pending
+	histograms map[string]seriesState
+}
+
+// Err возвращает cumulative.ErrBusy, если серии запроса меняет другой незавершённый запрос.
+// Такой результат нельзя записывать: его нужно отменить через Discard, а клиент повторит запрос.
+func (r *Result) Err() error {
+	return r.counters.Err()
+}
+
+// Discard освобождает серии запроса, не запоминая их состояние
+func (r *Result) Discard() {
+	r.counters.Discard()
+}
+
+// Commit запоминает состояние серий; раз в cumulative.DefaultTTL удаляются гистограммы,
+// не получавшие точек дольше этого срока. Результат с ошибкой Err только отменяется.
+func (r *Result) Commit() {
+	if r.Err() != nil {
+		r.Discard()
+		return
+	}
+
+	c := r.converter
+	now := time.Now()
+	c.mu.Lock()
+	for key, st := range r.histograms {
+		st.seen = now
+		c.series[key] = st
+	}
+	if now.Sub(c.pruned) >= cumulative.DefaultTTL {
+		for key, st := range c.series {
+			if now.Sub(st.seen) >= cumulative.DefaultTTL {
+				delete(c.series, key)
+			}
+		}
+		c.pruned = now
+	}
+	c.mu.Unlock()
+
+	// Серии освобождаются последними, когда состояние гистограмм уже записано
+	r.counters.Commit()
+}
+
+// Convert разбирает запрос; после записи метрик нужно вызвать Result.Commit, а при ошибке — Result.Discard
+func (c *Converter) Convert(tenant string, data *metricsv1.MetricsData) *Result {
+	res := &Result{converter: c, counters: c.counters.Begin(), histograms: make(map[string]seriesState)}
+	reject := func(n int, format string, args ...any) {
+		res.Rejected += n
+		res.Problems = append(res.Problems, fmt.Sprintf(format, args...))
+	}
+
+	for _, rm := range data.GetResourceMetrics() {
+		prefix, resourceLabels := c.resourceLabels(rm.GetResource().GetAttributes())
+
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				name := prefix + m.GetName()
+				meta := &models.MetricMeta{Unit: m.GetUnit(), Help: m.GetDescription(), Source: source}
+
+				switch {
+				case m.GetGauge() != nil:
+					for _, dp := range m.GetGauge().GetDataPoints() {
+						value := numberValue(dp)
+						if !finite(value) {
+							reject(1, "%s: non-finite value", m.GetName())
+							continue
+						}
+						res.Metrics = append(res.Metrics, models.Metrics{
+							ID: seriesID(name, resourceLabels, dp.GetAttributes()), MType: models.Gauge, Value: &value, Meta: meta,
+						})
+					}
+
+				case m.GetSum() != nil:
+					sum := m.GetSum()
+					cumulative := sum.GetAggregationTemporality() == metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+					for _, dp := range sum.GetDataPoints() {
+						id := seriesID(name, resourceLabels, dp.GetAttributes())
+						value := numberValue(dp)
+						if !finite(value) {
+							reject(1, "%s: non-finite value", m.GetName())
+							continue
+						}
+						if cumulative && !sum.GetIsMonotonic() {
+							res.Metrics = append(res.Metrics, models.Metrics{ID: id, MType: models.Gauge, Value: &value, Meta: meta})
+							continue
+						}
+						if delta, ok := c.counterDelta(res.counters, tenant+"\x00"+id, dp.GetStartTimeUnixNano(), value, cumulative); ok {
+							res.Metrics = append(res.Metrics, models.Metrics{ID: id, MType: models.Counter, Delta: &delta, Meta: meta})
+						}
+					}
+
+				case m.GetHistogram() != nil:
+					hist := m.GetHistogram()
+					cumulative := hist.GetAggregationTemporality() == metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+					for _, dp := range hist.GetDataPoints() {
+						id := seriesID(name, resourceLabels, dp.GetAttributes())
+						h, err := c.histogramDelta(res, tenant+"\x00"+id, dp, cumulative)
+						if err != nil {
+							reject(1, "%s: %v", m.GetName(), err)
+							continue
+						}
+						if h != nil {
+							res.Metrics = append(res.Metrics, models.Metrics{ID: id, MType: models.Histogram, Histogram: h, Meta: meta})
+						}
+					}
+
+				case m.GetExponentialHistogram() != nil:
+					reject(len(m.GetExponentialHistogram().GetDataPoints()), "%s: exponential histograms are not supported", m.GetName())
+				case m.GetSummary() != nil:
+					reject(len(m.GetSummary().GetDataPoints()), "%s: summaries are not supported", m.GetName())
+				}
+			}
+		}
+	}
+	return res
+}
+
+func (c *Converter) resourceLabels(attrs []*commonv1.KeyValue) (string, map[string]string) {
+	prefix := ""
+	labels := make(map[string]string, len(attrs))
+	for _, kv := range attrs {
+		value, ok := attrValue(kv.GetValue())
+		if !ok {
+			continue
+		}
+		if c.prefixAttr != "" && kv.GetKey() == c.prefixAttr {
+			prefix = value + "."
+			continue
+		}
+		labels[labelKey(kv.GetKey())] = value
+	}
+	return prefix, labels
+}
+
+func seriesID(name string, resource map[string]string, attrs []*commonv1.KeyValue) string {
+	labels := make(map[string]string, len(resource)+len(attrs))
+	for k, v := range resource {
+		labels[k] = v
+	}
+	for _, kv := range attrs {
+		if value, ok := attrValue(kv.GetValue()); ok {
+			labels[labelKey(kv.GetKey())] = value
+		}
+	}
+	return models.FormatID(name, labels)
+}
+
+// counterDelta возвращает целую дельту счётчика; ok=false, если прибавлять нечего
+func (c *Converter) counterDelta(pending *cumulative.Pending, key string, start uint64, value float64, cumulative bool) (int64, bool) {
+	switch {
+	case !cumulative:
+		return pending.Add(key, value)
+	case c.startedAfter(start):
+		return pending.DeltaFromZero(key, start, value)
+	default:
+		return pending.Delta(key, start, value)
+	}
+}
+
+// startedAfter сообщает, что серия со временем начала start началась после создания Converter
+func (c *Converter) startedAfter(start uint64) bool {
+	return start != 0 && start >= c.started
+}
+
+// histogramState ищет последнюю точку серии сначала среди точек текущего запроса.
+// Серия занимается до конца запроса так же, как счётчики в cumulative.Pending.
+func (c *Converter) histogramState(res *Result, key string) (seriesState, bool) {
+	if st, ok := res.histograms[key]; ok {
+		return st, true
+	}
+	res.counters.Reserve(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.series[key]
+	return st, ok
+}
+
+func (c *Converter) histogramDelta(res *Result, key string, dp *metricsv1.HistogramDataPoint, cumulative bool) (*models.HistogramData, error) {
+	bounds, counts := dp.GetExplicitBounds(), dp.GetBucketCounts()
+	if len(counts) == 0 {
+		return nil, nil
+	}
+	if err := models.ValidateBounds(bounds); err != nil {
+		return nil, err
+	}
+	if len(counts) != len(bounds)+1 {
+		return nil, fmt.Errorf("%d bucket counts for %d bounds", len(counts), len(bounds))
+	}
+	if !finite(dp.GetSum()) {
+		return nil, errors.New("non-finite sum")
+	}
+
+	h := &models.HistogramData{Bounds: slices.Clone(bounds), Counts: slices.Clone(counts), Sum: dp.GetSum()}
+	if cumulative {
+		st, seen := c.histogramState(res, key)
+		res.histograms[key] = seriesState{start: dp.GetStartTimeUnixNano(), counts: slices.Clone(counts), bounds: slices.Clone(bounds), sum: dp.GetSum()}
+		if !seen && !c.startedAfter(dp.GetStartTimeUnixNano()) {
+			return nil, nil
+		}
+		reset := !seen || st.start != dp.GetStartTimeUnixNano() || !slices.Equal(st.bounds, bounds)
+		if !reset {
+			for i := range counts {
+				if counts[i] < st.counts[i] {
+					reset = true
+					break
+				}
+			}
+		}
+		if !reset {
+			for i := range h.Counts {
+				h.Counts[i] -= st.counts[i]
+			}
+			h.Sum -= st.sum
+		}
+	}
+
+	for _, n := range h.Counts {
+		h.Count += n
+	}
+	if h.Count == 0 {
+		return nil, nil
+	}
+	return h, nil
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+func numberValue(dp *metricsv1.NumberDataPoint) float64 {
+	if _, ok := dp.GetValue().(*metricsv1.NumberDataPoint_AsInt); ok {
+		return float64(dp.GetAsInt())
+	}
+	return dp.GetAsDouble()
+}
+
+func attrValue(v *commonv1.AnyValue) (string, bool) {
+	switch v.GetValue().(type) {
+	case *commonv1.AnyValue_StringValue:
+		return v.GetStringValue(), true
+	case *commonv1.AnyValue_BoolValue:
+		return strconv.FormatBool(v.GetBoolValue()), true
+	case *commonv1.AnyValue_IntValue:
+		return strconv.FormatInt(v.GetIntValue(), 10), true
+	case *commonv1.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.GetDoubleValue(), 'g', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// labelKey приводит ключ атрибута к имени метки: service.name -> service_name
+func labelKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, key)
+}
@@ -0,0 +1,160 @@
+package otlp_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/ingest/otlp"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcev1 "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func strAttr(k, v string) *commonv1.KeyValue {
+	return &commonv1.KeyValue{Key: k, Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: v}}}
+}
+
+func cumulativeSum(start uint64, value float64) *metricsv1.MetricsData {
+	return &metricsv1.MetricsData{ResourceMetrics: []*metricsv1.ResourceMetrics{{
+		Resource: &resourcev1.Resource{Attributes: []*commonv1.KeyValue{strAttr("service.name", "api"), strAttr("host.name", "h1")}},
+		ScopeMetrics: []*metricsv1.ScopeMetrics{{Metrics: []*metricsv1.Metric{{
+			Name: "requests",
+			Data: &metricsv1.Metric_Sum{Sum: &metricsv1.Sum{
+				IsMonotonic:            true,
+				AggregationTemporality: metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				DataPoints: []*metricsv1.NumberDataPoint{{
+					StartTimeUnixNano: start,
+					Attributes:        []*commonv1.KeyValue{strAttr("code", "200")},
+					Value:             &metricsv1.NumberDataPoint_AsDouble{AsDouble: value},
+				}},
+			}},
+		}}}},
+	}}}
+}
+
+func TestConvertCumulativeSum(t *testing.T) {
+	c := otlp.NewConverter("service.name")
+
+	deltas := func(data *metricsv1.MetricsData) []int64 {
+		res := c.Convert("default", data)
+		assert.Zero(t, res.Rejected)
+		res.Commit()
+		var deltas []int64
+		for _, m := range res.Metrics {
+			require.Equal(t, models.Counter, m.MType)
+			assert.Equal(t, `api.requests{code="200",host_name="h1"}`, m.ID)
+			deltas = append(deltas, *m.Delta)
+		}
+		return deltas
+	}
+
+	assert.Empty(t, deltas(cumulativeSum(1, 10)), "Первая точка — точка отсчёта")
+	assert.Equal(t, []int64{5}, deltas(cumulativeSum(1, 15.5)))
+	assert.Equal(t, []int64{1}, deltas(cumulativeSum(1, 16)), "Дробный остаток переносится")
+	assert.Empty(t, deltas(cumulativeSum(1, 16)))
+	assert.Equal(t, []int64{3}, deltas(cumulativeSum(2, 3)), "Сброс счётчика по времени начала")
+
+	// Состояние ведётся отдельно для каждого арендатора
+	res := c.Convert("teamA", cumulativeSum(1, 7))
+	assert.Empty(t, res.Metrics)
+
+	// Серия, начавшаяся после запуска приёмника, накоплена целиком после него
+	res = c.Convert("teamB", cumulativeSum(uint64(time.Now().UnixNano()), 7))
+	require.Len(t, res.Metrics, 1)
+	assert.Equal(t, int64(7), *res.Metrics[0].Delta)
+}
+
+func TestConvertAfterRestart(t *testing.T) {
+	c := otlp.NewConverter("")
+	c.Convert("default", cumulativeSum(1, 10)).Commit()
+	res := c.Convert("default", cumulativeSum(1, 25))
+	require.Len(t, res.Metrics, 1)
+	res.Commit()
+
+	// Новый приёмник (перезапуск сервера) не прибавляет накопленный итог повторно
+	restarted := otlp.NewConverter("")
+	res = restarted.Convert("default", cumulativeSum(1, 30))
+	assert.Empty(t, res.Metrics)
+	res.Commit()
+
+	res = restarted.Convert("default", cumulativeSum(1, 32))
+	require.Len(t, res.Metrics, 1)
+	assert.Equal(t, int64(2), *res.Metrics[0].Delta)
+}
+
+func TestConvertWithoutCommit(t *testing.T) {
+	c := otlp.NewConverter("")
+	c.Convert("default", cumulativeSum(1, 10)).Commit()
+
+	// Запрос, метрики которого не удалось записать, при повторе даёт ту же дельту
+	for range 2 {
+		res := c.Convert("default", cumulativeSum(1, 25))
+		require.Len(t, res.Metrics, 1)
+		assert.Equal(t, int64(15), *res.Metrics[0].Delta)
+	}
+}
+
+func TestConvertRejectsNonFinite(t *testing.T) {
+	c := otlp.NewConverter("")
+	data := &metricsv1.MetricsData{ResourceMetrics: []*metricsv1.ResourceMetrics{{
+		ScopeMetrics: []*metricsv1.ScopeMetrics{{Metrics: []*metricsv1.Metric{{
+			Name: "temperature",
+			Data: &metricsv1.Metric_Gauge{Gauge: &metricsv1.Gauge{DataPoints: []*metricsv1.NumberDataPoint{
+				{Value: &metricsv1.NumberDataPoint_AsDouble{AsDouble: math.NaN()}},
+				{Value: &metricsv1.NumberDataPoint_AsDouble{AsDouble: math.Inf(1)}},
+				{Value: &metricsv1.NumberDataPoint_AsDouble{AsDouble: 21.5}},
+			}}},
+		}}}},
+	}}}
+
+	res := c.Convert("default", data)
+	assert.Equal(t, 2, res.Rejected)
+	require.Len(t, res.Metrics, 1)
+	assert.Equal(t, 21.5, *res.Metrics[0].Value)
+}
+
+func TestConvertHistogramAndUnsupported(t *testing.T) {
+	c := otlp.NewConverter("")
+
+	histogram := func(counts ...uint64) *metricsv1.MetricsData {
+		return &metricsv1.MetricsData{ResourceMetrics: []*metricsv1.ResourceMetrics{{
+			ScopeMetrics: []*metricsv1.ScopeMetrics{{Metrics: []*metricsv1.Metric{
+				{
+					Name: "latency",
+					Unit: "s",
+					Data: &metricsv1.Metric_Histogram{Histogram: &metricsv1.Histogram{
+						AggregationTemporality: metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+						DataPoints: []*metricsv1.HistogramDataPoint{{
+							StartTimeUnixNano: 1, ExplicitBounds: []float64{0.1, 1}, BucketCounts: counts, Sum: ptr(float64(len(counts))),
+						}},
+					}},
+				},
+				{
+					Name: "sizes",
+					Data: &metricsv1.Metric_Summary{Summary: &metricsv1.Summary{DataPoints: []*metricsv1.SummaryDataPoint{{}}}},
+				},
+			}}},
+		}}}
+	}
+
+	res := c.Convert("default", histogram(1, 2, 0))
+	res.Commit()
+	assert.Equal(t, 1, res.Rejected)
+	require.Len(t, res.Problems, 1)
+	assert.Empty(t, res.Metrics, "Первая точка — точка отсчёта")
+
+	metrics := c.Convert("default", histogram(3, 2, 1)).Metrics
+	require.Len(t, metrics, 1)
+	assert.Equal(t, models.Histogram, metrics[0].MType)
+	assert.Equal(t, "s", metrics[0].Meta.Unit)
+	assert.Equal(t, []uint64{2, 0, 1}, metrics[0].Histogram.Counts)
+	assert.Equal(t, uint64(3), metrics[0].Histogram.Count)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,120 @@
+package promrw
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/zetcan333/metrics-collector/internal/ingest/cumulative"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+const (
+	source = "prometheus"
+
+	nameLabel = "__name__"
+)
+
+// Converter переводит серии remote write в метрики сервера. По умолчанию сэмплы
+// становятся gauge; серии, имя которых подходит под одно из правил, считаются
+// счётчиками, и их кумулятивные значения переводятся в дельты.
+type Converter struct {
+	counterRules []*regexp.Regexp
+	counters     *cumulative.Counters
+}
+
+// NewConverter принимает правила в синтаксисе параметра match: glob (http_*_total) или re:<regexp>
+func NewConverter(counterRules []string) (*Converter, error) {
+	const op = "internal.ingest.promrw.NewConverter"
+
+	c := &Converter{counters: cumulative.NewCounters()}
+	for _, rule := range counterRules {
+		expr, err := models.MetricsFilter{Match: rule}.MatchRegexp()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		c.counterRules = append(c.counterRules, regexp.MustCompile(expr))
+	}
+	return c, nil
+}
+
+// Convert возвращает метрики, число пропущенных серий без имени и новые значения счётчиков.
+// Их нужно зафиксировать через Commit после успешной записи метрик: иначе повтор запроса
+// Prometheus после ошибки хранилища дал бы нулевые дельты. При ошибке или Err набор
+// отменяется через Discard.
+func (c *Converter) Convert(tenant string, req *WriteRequest) ([]models.Metrics, int, *cumulative.Pending) {
+	meta := make(map[string]*models.MetricMeta, len(req.Metadata))
+	for _, md := range req.Metadata {
+		meta[md.Family] = &models.MetricMeta{Unit: md.Unit, Help: md.Help, Source: source}
+	}
+
+	var (
+		metrics []models.Metrics
+		skipped int
+		pending = c.counters.Begin()
+	)
+	for _, ts := range req.Timeseries {
+		name, labels := "", make(map[string]string, len(ts.Labels))
+		for _, l := range ts.Labels {
+			if l.Name == nameLabel {
+				name = l.Value
+				continue
+			}
+			labels[l.Name] = l.Value
+		}
+		if name == "" {
+			skipped++
+			continue
+		}
+
+		id := models.FormatID(name, labels)
+		m := models.Metrics{ID: id, Meta: c.meta(meta, name)}
+		if c.isCounter(name) {
+			var total int64
+			for _, s := range ts.Samples {
+				// NaN — маркер устаревания серии, значения в нём нет
+				if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+					continue
+				}
+				if delta, ok := pending.Delta(tenant+"\x00"+id, 0, s.Value); ok {
+					total += delta
+				}
+			}
+			if total == 0 {
+				continue
+			}
+			m.MType, m.Delta = models.Counter, &total
+		} else {
+			var value *float64
+			for _, s := range ts.Samples {
+				if !math.IsNaN(s.Value) && !math.IsInf(s.Value, 0) {
+					value = &s.Value
+				}
+			}
+			if value == nil {
+				continue
+			}
+			m.MType, m.Value = models.Gauge, value
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, skipped, pending
+}
+
+func (c *Converter) isCounter(name string) bool {
+	for _, re := range c.counterRules {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// meta ищет описание по имени семейства: у счётчиков оно бывает записано без суффикса _total
+func (c *Converter) meta(meta map[string]*models.MetricMeta, name string) *models.MetricMeta {
+	if m, ok := meta[name]; ok {
+		return m
+	}
+	return meta[strings.TrimSuffix(name, "_total")]
+}
@@ -0,0 +1,164 @@
+package promrw
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var ErrInvalidMessage = errors.New("invalid remote write message")
+
+// Типы из prometheus/prompb/types.proto, которые нужны приёмнику
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	Value     float64
+	Timestamp int64 // миллисекунды
+}
+
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+type MetricMetadata struct {
+	Family string
+	Help   string
+	Unit   string
+}
+
+type WriteRequest struct {
+	Timeseries []TimeSeries
+	Metadata   []MetricMetadata
+}
+
+// Unmarshal разбирает WriteRequest (remote write 1.0) без зависимости от пакетов Prometheus.
+// Неизвестные поля, в том числе exemplars и native histograms, пропускаются.
+func Unmarshal(b []byte) (*WriteRequest, error) {
+	req := &WriteRequest{}
+	err := fields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			ts, err := unmarshalTimeSeries(v)
+			if err != nil {
+				return err
+			}
+			req.Timeseries = append(req.Timeseries, ts)
+		case num == 3 && typ == protowire.BytesType:
+			md, err := unmarshalMetadata(v)
+			if err != nil {
+				return err
+			}
+			req.Metadata = append(req.Metadata, md)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func unmarshalTimeSeries(b []byte) (TimeSeries, error) {
+	var ts TimeSeries
+	err := fields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			var l Label
+			err := fields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if typ != protowire.BytesType {
+					return nil
+				}
+				switch num {
+				case 1:
+					l.Name = string(v)
+				case 2:
+					l.Value = string(v)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			ts.Labels = append(ts.Labels, l)
+		case num == 2 && typ == protowire.BytesType:
+			var s Sample
+			err := fields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				switch {
+				case num == 1 && typ == protowire.Fixed64Type:
+					s.Value = math.Float64frombits(fixed64(v))
+				case num == 2 && typ == protowire.VarintType:
+					x, _ := protowire.ConsumeVarint(v)
+					s.Timestamp = int64(x)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			ts.Samples = append(ts.Samples, s)
+		}
+		return nil
+	})
+	return ts, err
+}
+
+func unmarshalMetadata(b []byte) (MetricMetadata, error) {
+	var md MetricMetadata
+	err := fields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 2:
+			md.Family = string(v)
+		case 4:
+			md.Help = string(v)
+		case 5:
+			md.Unit = string(v)
+		}
+		return nil
+	})
+	return md, err
+}
+
+// fields обходит поля сообщения; для varint и fixed64 в fn передаются сырые байты значения
+func fields(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrInvalidMessage, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		var v []byte
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n >= 0 {
+				v = b[:n]
+			}
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrInvalidMessage, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if err := fn(num, typ, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fixed64(b []byte) uint64 {
+	x, _ := protowire.ConsumeFixed64(b)
+	return x
+}
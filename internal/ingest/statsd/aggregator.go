@@ -0,0 +1,139 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/zetcan333/metrics-collector/internal/ingest"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/pkg/myerrors"
+)
+
+// TimerBounds — границы корзин гистограмм для таймеров, в миллисекундах
+var TimerBounds = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// Updater — операции SeverUsecase, через которые записываются агрегаты
+type Updater interface {
+	UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error
+	GetViaModel(ctx context.Context, metric models.Metrics) (models.Metrics, error)
+}
+
+type gaugeState struct {
+	set   bool // было абсолютное значение в этом интервале
+	value float64
+	delta float64 // сумма относительных изменений после последнего абсолютного
+}
+
+// Aggregator накапливает выборки за интервал и записывает их одной пачкой
+type Aggregator struct {
+	updater Updater
+
+	mu       sync.Mutex
+	counters map[string]float64
+	gauges   map[string]*gaugeState
+	timers   map[string]*models.HistogramData
+}
+
+func NewAggregator(updater Updater) *Aggregator {
+	return &Aggregator{
+		updater:  updater,
+		counters: make(map[string]float64),
+		gauges:   make(map[string]*gaugeState),
+		timers:   make(map[string]*models.HistogramData),
+	}
+}
+
+func (a *Aggregator) Add(s Sample) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch s.Type {
+	case TypeCounter:
+		a.counters[s.ID] += s.Value / s.Rate
+
+	case TypeGauge:
+		g, ok := a.gauges[s.ID]
+		if !ok {
+			g = &gaugeState{}
+			a.gauges[s.ID] = g
+		}
+		if s.Relative {
+			g.delta += s.Value
+		} else {
+			g.set, g.value, g.delta = true, s.Value, 0
+		}
+
+	case TypeTimer, TypeHist:
+		h, ok := a.timers[s.ID]
+		if !ok {
+			h = &models.HistogramData{Bounds: TimerBounds, Counts: make([]uint64, len(TimerBounds)+1)}
+			a.timers[s.ID] = h
+		}
+		// Выборка с частотой rate представляет 1/rate наблюдений
+		weight := uint64(math.Max(1, math.Round(1/s.Rate)))
+		h.Counts[sort.SearchFloat64s(TimerBounds, s.Value)] += weight
+		h.Count += weight
+		h.Sum += s.Value * float64(weight)
+	}
+}
+
+// Flush записывает накопленное за интервал. Дробная часть счётчиков (из-за sample rate)
+// переносится в следующий интервал.
+func (a *Aggregator) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	counters, gauges, timers := a.counters, a.gauges, a.timers
+	a.counters = make(map[string]float64)
+	a.gauges = make(map[string]*gaugeState)
+	a.timers = make(map[string]*models.HistogramData)
+
+	metrics := make([]models.Metrics, 0, len(counters)+len(gauges)+len(timers))
+	for id, value := range counters {
+		delta := int64(math.Trunc(value))
+		if rest := value - float64(delta); rest != 0 {
+			a.counters[id] = rest
+		}
+		if delta != 0 {
+			metrics = append(metrics, models.Metrics{ID: id, MType: models.Counter, Delta: &delta})
+		}
+	}
+	for id, h := range timers {
+		metrics = append(metrics, models.Metrics{ID: id, MType: models.Histogram, Histogram: h})
+	}
+	a.mu.Unlock()
+
+	var errs []error
+	for id, g := range gauges {
+		value, err := a.gaugeValue(ctx, id, g)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		metrics = append(metrics, models.Metrics{ID: id, MType: models.Gauge, Value: &value})
+	}
+
+	if err := ingest.WriteBatch(ctx, a.updater, metrics); err != nil {
+		errs = append(errs, fmt.Errorf("failed to write statsd metrics: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// gaugeValue применяет относительные изменения к значению из хранилища, если абсолютного не было
+func (a *Aggregator) gaugeValue(ctx context.Context, id string, g *gaugeState) (float64, error) {
+	if g.set {
+		return g.value + g.delta, nil
+	}
+	current, err := a.updater.GetViaModel(ctx, models.Metrics{ID: id, MType: models.Gauge})
+	switch {
+	case errors.Is(err, myerrors.ErrMetricNotFound):
+		return g.delta, nil
+	case err != nil:
+		return 0, fmt.Errorf("failed to read gauge %s: %w", id, err)
+	case current.MType != models.Gauge || current.Value == nil:
+		return 0, fmt.Errorf("cannot adjust %s: stored metric is %s", id, current.MType)
+	}
+	return *current.Value + g.delta, nil
+}
@@ -0,0 +1,81 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+const (
+	TypeCounter = "c"
+	TypeGauge   = "g"
+	TypeTimer   = "ms"
+	TypeHist    = "h" // DogStatsD, обрабатывается как таймер
+)
+
+var ErrInvalidLine = errors.New("invalid statsd line")
+
+// Sample — одна строка StatsD: name:value|type[|@rate][|#tag:value,...]
+type Sample struct {
+	ID       string // имя с метками из тегов DogStatsD
+	Type     string
+	Value    float64
+	Relative bool // gauge со знаком +/- изменяет текущее значение
+	Rate     float64
+}
+
+func Parse(line string) (Sample, error) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return Sample{}, fmt.Errorf("%w: %q: missing name", ErrInvalidLine, line)
+	}
+
+	fields := strings.Split(rest, "|")
+	if len(fields) < 2 {
+		return Sample{}, fmt.Errorf("%w: %q: missing type", ErrInvalidLine, line)
+	}
+
+	sample := Sample{Type: fields[1], Rate: 1}
+	switch sample.Type {
+	case TypeCounter, TypeGauge, TypeTimer, TypeHist:
+	default:
+		return Sample{}, fmt.Errorf("%w: %q: unsupported type %q", ErrInvalidLine, line, sample.Type)
+	}
+
+	raw := fields[0]
+	if sample.Type == TypeGauge && (strings.HasPrefix(raw, "+") || strings.HasPrefix(raw, "-")) {
+		sample.Relative = true
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return Sample{}, fmt.Errorf("%w: %q: bad value", ErrInvalidLine, line)
+	}
+	sample.Value = value
+
+	var labels map[string]string
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			rate, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return Sample{}, fmt.Errorf("%w: %q: bad sample rate", ErrInvalidLine, line)
+			}
+			sample.Rate = rate
+		case strings.HasPrefix(field, "#"):
+			labels = make(map[string]string)
+			for _, tag := range strings.Split(field[1:], ",") {
+				if tag == "" {
+					continue
+				}
+				key, value, _ := strings.Cut(tag, ":")
+				labels[key] = value
+			}
+		}
+	}
+	sample.ID = models.FormatID(name, labels)
+	return sample, nil
+}
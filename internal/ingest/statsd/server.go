@@ -0,0 +1,130 @@
+package statsd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/ingest"
+	"go.uber.org/zap"
+)
+
+const maxPacketSize = 65535
+
+// Server принимает StatsD по UDP и/или TCP и раз в интервал записывает агрегаты
+type Server struct {
+	log           *zap.Logger
+	agg           *Aggregator
+	udpAddr       string
+	tcpAddr       string
+	flushInterval time.Duration
+	invalid       atomic.Uint64
+}
+
+func NewServer(log *zap.Logger, agg *Aggregator, udpAddr, tcpAddr string, flushInterval time.Duration) *Server {
+	return &Server{log: log, agg: agg, udpAddr: udpAddr, tcpAddr: tcpAddr, flushInterval: flushInterval}
+}
+
+// Run слушает адреса до отмены контекста; при остановке записывает последний интервал
+func (s *Server) Run(ctx context.Context) error {
+	var listeners []func() error
+	var closers []func() error
+
+	if s.udpAddr != "" {
+		conn, err := net.ListenPacket("udp", s.udpAddr)
+		if err != nil {
+			return err
+		}
+		closers = append(closers, conn.Close)
+		listeners = append(listeners, func() error { return s.serveUDP(conn) })
+		s.log.Sugar().Infoln("StatsD UDP listener on", conn.LocalAddr())
+	}
+	if s.tcpAddr != "" {
+		ln, err := net.Listen("tcp", s.tcpAddr)
+		if err != nil {
+			for _, c := range closers {
+				c()
+			}
+			return err
+		}
+		closers = append(closers, ln.Close)
+		listeners = append(listeners, func() error { return s.serveTCP(ctx, ln) })
+		s.log.Sugar().Infoln("StatsD TCP listener on", ln.Addr())
+	}
+
+	var wg sync.WaitGroup
+	for _, serve := range listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := serve(); err != nil && !errors.Is(err, net.ErrClosed) {
+				s.log.Sugar().Errorln("statsd listener stopped", zap.Error(err))
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush(ctx)
+		case <-ctx.Done():
+			for _, c := range closers {
+				c()
+			}
+			wg.Wait()
+			s.flush(context.WithoutCancel(ctx))
+			return nil
+		}
+	}
+}
+
+func (s *Server) flush(ctx context.Context) {
+	if invalid := s.invalid.Swap(0); invalid > 0 {
+		s.log.Sugar().Errorln("statsd: invalid lines skipped:", invalid)
+	}
+	if err := s.agg.Flush(ctx); err != nil {
+		s.log.Sugar().Errorln("statsd: flush failed", zap.Error(err))
+	}
+}
+
+func (s *Server) serveUDP(conn net.PacketConn) error {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			s.handleLine(line)
+		}
+	}
+}
+
+func (s *Server) serveTCP(ctx context.Context, ln net.Listener) error {
+	return ingest.ServeConns(ctx, ln, func(conn net.Conn) {
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			s.handleLine(scanner.Text())
+		}
+	})
+}
+
+func (s *Server) handleLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	sample, err := Parse(line)
+	if err != nil {
+		s.invalid.Add(1)
+		return
+	}
+	s.agg.Add(sample)
+}
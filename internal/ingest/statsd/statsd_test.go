@@ -0,0 +1,117 @@
+package statsd_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/ingest/statsd"
+	"github.com/zetcan333/metrics-collector/internal/lib/zapdiscard"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/internal/repo/storage/mem"
+	"github.com/zetcan333/metrics-collector/internal/usecase"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    statsd.Sample
+		wantErr bool
+	}{
+		{line: "hits:1|c", want: statsd.Sample{ID: "hits", Type: "c", Value: 1, Rate: 1}},
+		{line: "hits:3|c|@0.1", want: statsd.Sample{ID: "hits", Type: "c", Value: 3, Rate: 0.1}},
+		{line: "temp:21.5|g", want: statsd.Sample{ID: "temp", Type: "g", Value: 21.5, Rate: 1}},
+		{line: "temp:-2|g", want: statsd.Sample{ID: "temp", Type: "g", Value: -2, Relative: true, Rate: 1}},
+		{line: "db.query:12|ms|#table:users,op:select", want: statsd.Sample{ID: `db.query{op="select",table="users"}`, Type: "ms", Value: 12, Rate: 1}},
+		{line: "hits", wantErr: true},
+		{line: "hits:1", wantErr: true},
+		{line: "hits:x|c", wantErr: true},
+		{line: "lat:NaN|ms", wantErr: true},
+		{line: "hits:Inf|c", wantErr: true},
+		{line: "temp:-Inf|g", wantErr: true},
+		{line: "users:42|s", wantErr: true},
+		{line: "hits:1|c|@2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := statsd.Parse(tt.line)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, statsd.ErrInvalidLine)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestServerAggregatesAndFlushes(t *testing.T) {
+	ctx := context.Background()
+	uc := usecase.NewSeverUsecase(mem.NewStorage())
+	require.NoError(t, uc.UpdateMetric(ctx, "gauge", "queue", "10"))
+
+	udpAddr := freeAddr(t, "udp")
+	tcpAddr := freeAddr(t, "tcp")
+	srv := statsd.NewServer(zapdiscard.NewDiscardLogger(), statsd.NewAggregator(uc), udpAddr, tcpAddr, time.Hour)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- srv.Run(runCtx) }()
+
+	// UDP открывается раньше TCP, так что после подключения по TCP оба слушателя готовы
+	var tcp net.Conn
+	require.Eventually(t, func() bool {
+		var err error
+		tcp, err = net.Dial("tcp", tcpAddr)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	_, err := tcp.Write([]byte("temp:20|g\ntemp:+1.5|g\nlatency:30|ms\nlatency:700|ms|@0.5\n"))
+	require.NoError(t, err)
+	require.NoError(t, tcp.Close())
+
+	udp, err := net.Dial("udp", udpAddr)
+	require.NoError(t, err)
+	defer udp.Close()
+	_, err = udp.Write([]byte("hits:1|c\nhits:2|c|@0.5\nqueue:+5|g\nqueue:-2|g\nbad line\n"))
+	require.NoError(t, err)
+
+	// Ждём, пока UDP-пакет и TCP-строки будут разобраны, затем останавливаем с финальной записью
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	value, err := uc.GetMetric(ctx, "counter", "hits")
+	require.NoError(t, err)
+	assert.Equal(t, "5", value)
+
+	value, err = uc.GetMetric(ctx, "gauge", "queue")
+	require.NoError(t, err)
+	assert.Equal(t, "13", value)
+
+	value, err = uc.GetMetric(ctx, "gauge", "temp")
+	require.NoError(t, err)
+	assert.Equal(t, "21.5", value)
+
+	latency, err := uc.GetViaModel(ctx, models.Metrics{ID: "latency", MType: models.Histogram})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), latency.Histogram.Count)
+	assert.Equal(t, 1430.0, latency.Histogram.Sum)
+}
+
+func freeAddr(t *testing.T, network string) string {
+	t.Helper()
+	if network == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer conn.Close()
+		return conn.LocalAddr().String()
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().String()
+}
@@ -1,16 +1,10 @@
 package float
 
-import (
-	"strconv"
-	"strings"
-)
+import "strconv"
 
-// formatFloat форматирует float64, удаляя лишние нули.
+// FormatFloat форматирует float64 в кратчайшую десятичную запись без экспоненты и лишних нулей.
+// Точность -1 уже не оставляет нулей в конце дробной части, поэтому обрезать их не нужно:
+// обрезка нулей в целой части превращала 10 в 1.
 func FormatFloat(value float64) string {
-	// Преобразуем число в строку с максимальной точностью.
-	str := strconv.FormatFloat(value, 'f', -1, 64)
-	// Удаляем лишние нули и точку, если они есть.
-	str = strings.TrimRight(str, "0")
-	str = strings.TrimRight(str, ".")
-	return str
+	return strconv.FormatFloat(value, 'f', -1, 64)
 }
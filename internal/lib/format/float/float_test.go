@@ -0,0 +1,30 @@
+package float_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zetcan333/metrics-collector/internal/lib/format/float"
+)
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{value: 0, want: "0"},
+		{value: 1, want: "1"},
+		{value: 10, want: "10"},
+		{value: 100, want: "100"},
+		{value: -20, want: "-20"},
+		{value: 0.5, want: "0.5"},
+		{value: 10.5, want: "10.5"},
+		{value: 1.25, want: "1.25"},
+		{value: 1e-6, want: "0.000001"},
+		{value: 1e21, want: "1000000000000000000000"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, float.FormatFloat(tt.value), tt.value)
+	}
+}
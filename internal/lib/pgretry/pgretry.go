@@ -6,39 +6,34 @@ import (
 	"fmt"
 	"net"
 	"strings"
-	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/zetcan333/metrics-collector/internal/lib/retry"
 )
 
-var (
-	delays      = []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second}
-	maxAttempts = 3
-)
-
+// Retry выполняет fn с повторами по расписанию retry.Delays, пока ошибка связана с соединением
 func Retry[T any](ctx context.Context, op string, fn func() (T, error)) (T, error) {
-	var zero T
-	for attempt := 0; attempt < maxAttempts; attempt++ {
-		result, err := fn()
+	var result T
+	attempt := 0
+	_, err := retry.Do(ctx, retry.Delays, func() (bool, error) {
+		var err error
+		result, err = fn()
 		switch {
-
 		case err == nil:
-			return result, nil
-
+			return false, nil
 		case !isRetriableError(err):
-			return zero, fmt.Errorf("not retriable error: %w", err)
-		}
-
-		fmt.Printf("op: %s, retrying to exec, attempt: %d\n", op, attempt+1)
-		select {
-		case <-ctx.Done():
-			return zero, ctx.Err()
-		case <-time.After(delays[attempt]):
+			return false, fmt.Errorf("not retriable error: %w", err)
 		}
+		attempt++
+		fmt.Printf("op: %s, exec failed, attempt: %d\n", op, attempt)
+		return true, err
+	})
+	if err != nil {
+		var zero T
+		return zero, err
 	}
-	return zero, fmt.Errorf("max attempts reached")
-
+	return result, nil
 }
 
 func isRetriableError(err error) bool {
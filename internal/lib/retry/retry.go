@@ -0,0 +1,119 @@
+// Package retry — общие для агента, клиента и сервера повторы: расписание пауз
+// и отправка JSON POST-запросов со сжатием и подписью тела.
+package retry
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/lib/sign"
+)
+
+// Delays — паузы между повторами по умолчанию; попыток на одну больше, чем пауз
+var Delays = []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second}
+
+var ErrMaxAttempts = errors.New("max attempts reached")
+
+// Do вызывает fn, пока она возвращает временную ошибку (retriable=true), выдерживая между
+// попытками паузы delays. Возвращённый retriable=true означает, что попытки кончились или
+// контекст отменён на временной ошибке — операцию имеет смысл повторить позже.
+func Do(ctx context.Context, delays []time.Duration, fn func() (bool, error)) (bool, error) {
+	for attempt := 0; ; attempt++ {
+		retriable, err := fn()
+		if err == nil || !retriable {
+			return false, err
+		}
+		if attempt >= len(delays) {
+			return true, fmt.Errorf("%w: %w", ErrMaxAttempts, err)
+		}
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case <-time.After(delays[attempt]):
+		}
+	}
+}
+
+// Poster отправляет JSON-тела POST-запросами. Тело подписывается HMAC-SHA256 ключом Key
+// в заголовке HashSHA256 (подпись считается по несжатому телу) и при Gzip сжимается.
+type Poster struct {
+	Client *http.Client
+	Key    string
+	Gzip   bool
+	Delays []time.Duration // nil — Delays
+}
+
+// Post отправляет body на url с повторами, см. Do и Send
+func (p *Poster) Post(ctx context.Context, url string, body []byte, header http.Header) (bool, error) {
+	delays := p.Delays
+	if delays == nil {
+		delays = Delays
+	}
+	return Do(ctx, delays, func() (bool, error) {
+		return p.Send(ctx, url, body, header)
+	})
+}
+
+// Send делает одну попытку; header — дополнительные заголовки (X-Tenant-ID, Authorization).
+// Сетевые ошибки и ответы 5xx и 429 временные, остальные ответы не 2xx — нет.
+func (p *Poster) Send(ctx context.Context, url string, body []byte, header http.Header) (bool, error) {
+	payload := body
+	if p.Gzip {
+		var err error
+		if payload, err = Compress(body); err != nil {
+			return false, fmt.Errorf("failed to compress body: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if p.Key != "" {
+		req.Header.Set(sign.Header, sign.Sum(body, p.Key))
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to send request: %w", err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("server returned status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+}
+
+// Compress сжимает тело запроса gzip
+func Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
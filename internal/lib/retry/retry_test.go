@@ -0,0 +1,94 @@
+package retry_test
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/lib/retry"
+	"github.com/zetcan333/metrics-collector/internal/lib/sign"
+)
+
+func TestDo(t *testing.T) {
+	delays := []time.Duration{time.Millisecond, time.Millisecond}
+
+	calls := 0
+	retriable, err := retry.Do(context.Background(), delays, func() (bool, error) {
+		calls++
+		return true, errors.New("unavailable")
+	})
+	assert.True(t, retriable)
+	assert.ErrorIs(t, err, retry.ErrMaxAttempts)
+	assert.Equal(t, 3, calls, "Попыток на одну больше, чем пауз")
+
+	calls = 0
+	retriable, err = retry.Do(context.Background(), delays, func() (bool, error) {
+		calls++
+		return false, errors.New("bad request")
+	})
+	assert.False(t, retriable)
+	assert.EqualError(t, err, "bad request")
+	assert.Equal(t, 1, calls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	retriable, err = retry.Do(ctx, []time.Duration{time.Hour}, func() (bool, error) {
+		return true, errors.New("unavailable")
+	})
+	assert.True(t, retriable)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPosterPost(t *testing.T) {
+	body := []byte(`[{"id":"a","type":"gauge","value":1}]`)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "teamA", r.Header.Get("X-Tenant-ID"))
+		assert.Equal(t, sign.Sum(body, "secret"), r.Header.Get(sign.Header))
+
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		got, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		assert.Equal(t, body, got)
+
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	p := &retry.Poster{Key: "secret", Gzip: true, Delays: []time.Duration{time.Millisecond, time.Millisecond}}
+	header := http.Header{"X-Tenant-Id": {"teamA"}}
+	_, err := p.Post(context.Background(), server.URL, body, header)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestPosterNotRetriable(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	p := &retry.Poster{Delays: []time.Duration{time.Millisecond}}
+	retriable, err := p.Post(context.Background(), server.URL, []byte(`{}`), nil)
+	assert.False(t, retriable)
+	assert.EqualError(t, err, "server returned status 400")
+	assert.Equal(t, int32(1), calls.Load())
+}
@@ -0,0 +1,17 @@
+package sign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Header — заголовок с подписью тела запроса
+const Header = "HashSHA256"
+
+// Sum возвращает HMAC-SHA256 несжатого тела запроса в hex
+func Sum(data []byte, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,31 @@
+package tenant
+
+import (
+	"context"
+	"regexp"
+)
+
+// Default — арендатор, к которому относятся запросы без явного указания команды
+const Default = "default"
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type ctxKey struct{}
+
+// WithTenant возвращает контекст с привязанным арендатором
+func WithTenant(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, name)
+}
+
+// FromContext извлекает арендатора из контекста, по умолчанию Default
+func FromContext(ctx context.Context) string {
+	if name, ok := ctx.Value(ctxKey{}).(string); ok && name != "" {
+		return name
+	}
+	return Default
+}
+
+// IsValid проверяет имя арендатора: оно используется в именах файлов бэкапа
+func IsValid(name string) bool {
+	return validName.MatchString(name)
+}
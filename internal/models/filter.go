@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/zetcan333/metrics-collector/pkg/myerrors"
+)
+
+// RegexpPrefix помечает шаблон имени как регулярное выражение, иначе это glob
+const RegexpPrefix = "re:"
+
+// MetricsFilter задаёт выборку метрик. Результат упорядочен по ID,
+// Cursor — ID последней метрики предыдущей страницы, Limit 0 — без ограничения.
+type MetricsFilter struct {
+	Type   string
+	Prefix string
+	Match  string // glob по ID или регулярное выражение с префиксом RegexpPrefix
+	Cursor string
+	Limit  int
+}
+
+// MatchRegexp возвращает Match в виде регулярного выражения; пустая строка — без фильтра
+func (f MetricsFilter) MatchRegexp() (string, error) {
+	if f.Match == "" {
+		return "", nil
+	}
+
+	pattern, ok := strings.CutPrefix(f.Match, RegexpPrefix)
+	if !ok {
+		pattern = globToRegexp(f.Match)
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return "", fmt.Errorf("%w: %v", myerrors.ErrInvalidFilter, err)
+	}
+	return pattern, nil
+}
+
+// globToRegexp поддерживает *, ? и классы символов [...] / [!...]
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	inClass, classStart := false, false
+	for _, r := range glob {
+		switch {
+		case classStart && r == '!':
+			classStart = false
+			b.WriteString("^")
+		case inClass:
+			classStart = false
+			if r == ']' {
+				inClass = false
+			}
+			if r == '\\' {
+				b.WriteString(`\\`)
+				continue
+			}
+			b.WriteRune(r)
+		case r == '*':
+			b.WriteString(".*")
+		case r == '?':
+			b.WriteString(".")
+		case r == '[':
+			inClass, classStart = true, true
+			b.WriteRune(r)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/zetcan333/metrics-collector/pkg/myerrors"
+)
+
+const Histogram = "histogram"
+
+// DefaultHistogramBounds — верхние границы корзин по умолчанию (как DefBuckets в Prometheus)
+var DefaultHistogramBounds = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultQuantiles — квантили, которые вычисляются при чтении гистограммы
+var DefaultQuantiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+var (
+	errHistogramBounds = fmt.Errorf("%w: bounds must be finite and strictly increasing", myerrors.ErrInvalidHistogramValue)
+	errHistogramCounts = fmt.Errorf("%w: counts must have len(bounds)+1 elements", myerrors.ErrInvalidHistogramValue)
+	errHistogramMerge  = fmt.Errorf("%w: bounds do not match stored bounds", myerrors.ErrInvalidHistogramValue)
+	errHistogramValue  = fmt.Errorf("%w: observations must be finite", myerrors.ErrInvalidHistogramValue)
+	errHistogramEmpty  = fmt.Errorf("%w: neither observations nor counts given", myerrors.ErrInvalidHistogramValue)
+	errHistogramSum    = fmt.Errorf("%w: sum overflows float64", myerrors.ErrInvalidHistogramValue)
+)
+
+// HistogramData — гистограмма с фиксированными корзинами.
+// Counts[i] — число наблюдений в (Bounds[i-1], Bounds[i]], последний элемент — корзина +Inf.
+// Во входящих обновлениях можно передать либо сырые Observations, либо готовые Bounds+Counts.
+type HistogramData struct {
+	Bounds       []float64          `json:"bounds,omitempty"`
+	Counts       []uint64           `json:"counts,omitempty"`
+	Sum          float64            `json:"sum"`
+	Count        uint64             `json:"count"`
+	Observations []float64          `json:"observations,omitempty"` // только во входящих обновлениях
+	Quantiles    map[string]float64 `json:"quantiles,omitempty"`    // только в ответах сервера
+}
+
+// ValidateBounds проверяет, что границы корзин конечны и строго возрастают
+func ValidateBounds(bounds []float64) error {
+	for i, b := range bounds {
+		if math.IsNaN(b) || math.IsInf(b, 0) || (i > 0 && b <= bounds[i-1]) {
+			return errHistogramBounds
+		}
+	}
+	return nil
+}
+
+// Validate проверяет входящее обновление гистограммы
+func (h *HistogramData) Validate() error {
+	if err := ValidateBounds(h.Bounds); err != nil {
+		return err
+	}
+	if len(h.Counts) > 0 && len(h.Counts) != len(h.Bounds)+1 {
+		return errHistogramCounts
+	}
+	for _, v := range h.Observations {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return errHistogramValue
+		}
+	}
+	if len(h.Counts) == 0 && len(h.Observations) == 0 {
+		return errHistogramEmpty
+	}
+	return nil
+}
+
+// MergeHistograms добавляет обновление in к накопленной гистограмме stored (может быть nil).
+// Корзины берутся из stored, а для новой гистограммы — из in.Bounds.
+// Готовые счётчики корзин принимаются только при совпадении границ.
+// Обновление отклоняется, если сумма перестаёт быть конечной: такую гистограмму
+// нельзя сериализовать в JSON.
+func MergeHistograms(stored *HistogramData, in HistogramData) (*HistogramData, error) {
+	result := &HistogramData{}
+	if stored != nil && len(stored.Counts) > 0 {
+		result.Bounds = slices.Clone(stored.Bounds)
+		result.Counts = slices.Clone(stored.Counts)
+		result.Sum = stored.Sum
+		result.Count = stored.Count
+	} else {
+		result.Bounds = slices.Clone(in.Bounds)
+		result.Counts = make([]uint64, len(in.Bounds)+1)
+	}
+
+	if len(in.Counts) > 0 {
+		if !slices.Equal(result.Bounds, in.Bounds) {
+			return nil, errHistogramMerge
+		}
+		var count uint64
+		for i, c := range in.Counts {
+			result.Counts[i] += c
+			count += c
+		}
+		result.Count += count
+		result.Sum += in.Sum
+	}
+
+	for _, v := range in.Observations {
+		i := sort.SearchFloat64s(result.Bounds, v)
+		result.Counts[i]++
+		result.Count++
+		result.Sum += v
+	}
+	if math.IsNaN(result.Sum) || math.IsInf(result.Sum, 0) {
+		return nil, errHistogramSum
+	}
+	return result, nil
+}
+
+// Quantile оценивает квантиль q линейной интерполяцией внутри корзины.
+// Для корзины +Inf возвращается последняя конечная граница.
+func (h *HistogramData) Quantile(q float64) float64 {
+	if h.Count == 0 || len(h.Counts) == 0 {
+		return math.NaN()
+	}
+
+	rank := q * float64(h.Count)
+	var cumulative uint64
+	for i, c := range h.Counts {
+		if float64(cumulative+c) < rank || c == 0 {
+			cumulative += c
+			continue
+		}
+		if i == len(h.Bounds) {
+			if len(h.Bounds) == 0 {
+				return math.NaN()
+			}
+			return h.Bounds[len(h.Bounds)-1]
+		}
+		upper := h.Bounds[i]
+		lower := 0.0
+		if i > 0 {
+			lower = h.Bounds[i-1]
+		} else if upper <= 0 {
+			return upper
+		}
+		return lower + (upper-lower)*(rank-float64(cumulative))/float64(c)
+	}
+	return math.NaN()
+}
+
+// WithQuantiles возвращает копию гистограммы с Quantiles для DefaultQuantiles,
+// ключи вида "0.99". Сама h не меняется: её может одновременно читать хранилище.
+func (h *HistogramData) WithQuantiles() *HistogramData {
+	out := *h
+	if out.Count == 0 {
+		return &out
+	}
+	out.Quantiles = make(map[string]float64, len(DefaultQuantiles))
+	for _, q := range DefaultQuantiles {
+		// NaN не сериализуется в JSON: такие квантили просто не возвращаем
+		if v := h.Quantile(q); !math.IsNaN(v) {
+			out.Quantiles[strconv.FormatFloat(q, 'f', -1, 64)] = v
+		}
+	}
+	return &out
+}
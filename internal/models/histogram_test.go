@@ -0,0 +1,88 @@
+package models_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/pkg/myerrors"
+)
+
+func TestMergeHistograms(t *testing.T) {
+	bounds := []float64{1, 2, 4}
+
+	h, err := models.MergeHistograms(nil, models.HistogramData{Bounds: bounds, Observations: []float64{0.5, 1, 3, 10}})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{2, 0, 1, 1}, h.Counts)
+	assert.Equal(t, uint64(4), h.Count)
+	assert.Equal(t, 14.5, h.Sum)
+
+	// Готовые корзины с теми же границами складываются
+	h, err = models.MergeHistograms(h, models.HistogramData{Bounds: bounds, Counts: []uint64{0, 3, 0, 0}, Sum: 4.5})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{2, 3, 1, 1}, h.Counts)
+	assert.Equal(t, uint64(7), h.Count)
+	assert.Equal(t, 19.0, h.Sum)
+
+	// Наблюдения раскладываются по уже сохранённым границам
+	h, err = models.MergeHistograms(h, models.HistogramData{Bounds: []float64{100}, Observations: []float64{1.5}})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{2, 4, 1, 1}, h.Counts)
+
+	_, err = models.MergeHistograms(h, models.HistogramData{Bounds: []float64{1, 3}, Counts: []uint64{1, 1, 1}})
+	assert.ErrorIs(t, err, myerrors.ErrInvalidHistogramValue)
+}
+
+func TestMergeHistogramsSumOverflow(t *testing.T) {
+	h, err := models.MergeHistograms(nil, models.HistogramData{Bounds: []float64{1}, Observations: []float64{math.MaxFloat64}})
+	require.NoError(t, err)
+
+	// Каждое наблюдение конечно, но сумма переполняется до +Inf
+	_, err = models.MergeHistograms(h, models.HistogramData{Observations: []float64{math.MaxFloat64}})
+	assert.ErrorIs(t, err, myerrors.ErrInvalidHistogramValue)
+	_, err = models.MergeHistograms(h, models.HistogramData{Bounds: []float64{1}, Counts: []uint64{0, 1}, Sum: math.MaxFloat64})
+	assert.ErrorIs(t, err, myerrors.ErrInvalidHistogramValue)
+	assert.Equal(t, math.MaxFloat64, h.Sum, "Сохранённая гистограмма не меняется")
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	h := &models.HistogramData{
+		Bounds: []float64{10, 20, 40},
+		Counts: []uint64{50, 40, 10, 0},
+		Count:  100,
+	}
+
+	assert.InDelta(t, 10.0, h.Quantile(0.5), 1e-9)
+	assert.InDelta(t, 17.5, h.Quantile(0.8), 1e-9)
+	assert.InDelta(t, 40.0, h.Quantile(1), 1e-9)
+
+	withQuantiles := h.WithQuantiles()
+	assert.Contains(t, withQuantiles.Quantiles, "0.99")
+	assert.Nil(t, h.Quantiles, "WithQuantiles must not modify the receiver")
+}
+
+func TestHistogramValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       models.HistogramData
+		wantErr bool
+	}{
+		{name: "observations", h: models.HistogramData{Observations: []float64{1}}},
+		{name: "counts", h: models.HistogramData{Bounds: []float64{1}, Counts: []uint64{1, 0}}},
+		{name: "empty", h: models.HistogramData{}, wantErr: true},
+		{name: "unsorted bounds", h: models.HistogramData{Bounds: []float64{2, 1}, Observations: []float64{1}}, wantErr: true},
+		{name: "counts length", h: models.HistogramData{Bounds: []float64{1}, Counts: []uint64{1}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.h.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, myerrors.ErrInvalidHistogramValue)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
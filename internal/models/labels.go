@@ -0,0 +1,113 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Метки кодируются в ID метрики в стиле Prometheus: name{key="value",...}.
+// Ключи отсортированы, поэтому одна и та же серия всегда получает один ID.
+
+// FormatID собирает ID из имени и меток
+func FormatID(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(key)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(labels[key]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// ParseID разбирает ID на имя и метки; ID без меток возвращается как имя
+func ParseID(id string) (string, map[string]string, error) {
+	open := strings.IndexByte(id, '{')
+	if open < 0 {
+		return id, nil, nil
+	}
+	if !strings.HasSuffix(id, "}") {
+		return "", nil, fmt.Errorf("invalid metric id %q: unterminated labels", id)
+	}
+
+	name, rest := id[:open], id[open+1:len(id)-1]
+	labels := make(map[string]string)
+	for rest != "" {
+		eq := strings.Index(rest, `="`)
+		if eq <= 0 {
+			return "", nil, fmt.Errorf("invalid metric id %q: bad label", id)
+		}
+		key := rest[:eq]
+		rest = rest[eq+2:]
+
+		var value strings.Builder
+		closed := false
+		for i := 0; i < len(rest); i++ {
+			c := rest[i]
+			if c == '\\' && i+1 < len(rest) {
+				i++
+				if rest[i] == 'n' {
+					value.WriteByte('\n')
+				} else {
+					value.WriteByte(rest[i])
+				}
+				continue
+			}
+			if c == '"' {
+				rest = rest[i+1:]
+				closed = true
+				break
+			}
+			value.WriteByte(c)
+		}
+		if !closed {
+			return "", nil, fmt.Errorf("invalid metric id %q: unterminated label value", id)
+		}
+		labels[key] = value.String()
+
+		if rest != "" {
+			if rest[0] != ',' {
+				return "", nil, fmt.Errorf("invalid metric id %q: expected ','", id)
+			}
+			rest = rest[1:]
+		}
+	}
+	return name, labels, nil
+}
+
+// WithLabels добавляет метки к ID, перезаписывая совпадающие ключи
+func WithLabels(id string, extra map[string]string) (string, error) {
+	if len(extra) == 0 {
+		return id, nil
+	}
+	name, labels, err := ParseID(id)
+	if err != nil {
+		return "", err
+	}
+	if labels == nil {
+		labels = make(map[string]string, len(extra))
+	}
+	for key, value := range extra {
+		labels[key] = value
+	}
+	return FormatID(name, labels), nil
+}
@@ -0,0 +1,30 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+func TestLabels(t *testing.T) {
+	assert.Equal(t, "Alloc", models.FormatID("Alloc", nil))
+
+	id := models.FormatID("http_requests", map[string]string{"path": `/a"b\c`, "dc": "eu1"})
+	assert.Equal(t, `http_requests{dc="eu1",path="/a\"b\\c"}`, id)
+
+	name, labels, err := models.ParseID(id)
+	require.NoError(t, err)
+	assert.Equal(t, "http_requests", name)
+	assert.Equal(t, map[string]string{"path": `/a"b\c`, "dc": "eu1"}, labels)
+
+	merged, err := models.WithLabels(`up{dc="old",job="x"}`, map[string]string{"dc": "eu1"})
+	require.NoError(t, err)
+	assert.Equal(t, `up{dc="eu1",job="x"}`, merged)
+
+	for _, bad := range []string{`a{b="c"`, `a{b}`, `a{b="c}`, `a{b="c"d="e"}`} {
+		_, _, err := models.ParseID(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -1,10 +1,22 @@
 package models
 
+import "time"
+
 type Metrics struct {
-	ID    string   `json:"id"`
-	MType string   `json:"type"`
-	Delta *int64   `json:"delta,omitempty"`
-	Value *float64 `json:"value,omitempty"`
+	ID        string         `json:"id"`
+	MType     string         `json:"type"`
+	Delta     *int64         `json:"delta,omitempty"`
+	Value     *float64       `json:"value,omitempty"`
+	Histogram *HistogramData `json:"histogram,omitempty"`
+	Meta      *MetricMeta    `json:"meta,omitempty"`
+	UpdatedAt *time.Time     `json:"updated_at,omitempty"` // выставляется хранилищем при каждом обновлении
+}
+
+// MetricMeta — необязательное описание метрики
+type MetricMeta struct {
+	Unit   string `json:"unit,omitempty"`   // единица измерения: bytes, ns, count...
+	Help   string `json:"help,omitempty"`   // человекочитаемое описание
+	Source string `json:"source,omitempty"` // откуда метрика получена
 }
 
 const (
@@ -5,166 +5,359 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
 	"github.com/zetcan333/metrics-collector/internal/models"
 	"github.com/zetcan333/metrics-collector/pkg/myerrors"
 )
 
+// MemStorage хранит метрики раздельно для каждого арендатора: tenant -> id -> метрика
 type MemStorage struct {
 	sync.RWMutex
-	Metrics map[string]models.Metrics
+	Metrics map[string]map[string]models.Metrics
 }
 
 func NewStorage() *MemStorage {
 	return &MemStorage{
-		Metrics: make(map[string]models.Metrics),
+		Metrics: make(map[string]map[string]models.Metrics),
 	}
 }
-func (s *MemStorage) UpdateMetric(ctx context.Context, metric models.Metrics) error {
-	s.Lock()
-	defer s.Unlock()
-
-	currentMetric, exists := s.Metrics[metric.ID]
 
-	switch metric.MType {
-	case models.Gauge:
-		s.Metrics[metric.ID] = models.Metrics{
-			MType: models.Gauge,
-			ID:    metric.ID,
-			Value: metric.Value,
-		}
-	case models.Counter:
-		var newDelta int64
-		if exists && currentMetric.Delta != nil {
-			newDelta = *currentMetric.Delta
-		}
-		if metric.Delta != nil {
-			newDelta += *metric.Delta
-		}
-		s.Metrics[metric.ID] = models.Metrics{
-			MType: models.Counter,
-			ID:    metric.ID,
-			Delta: &newDelta,
-		}
+// tenantMetrics возвращает мапу метрик арендатора из контекста, вызывать под блокировкой
+func (s *MemStorage) tenantMetrics(ctx context.Context, create bool) map[string]models.Metrics {
+	name := tenant.FromContext(ctx)
+	metrics, ok := s.Metrics[name]
+	if !ok && create {
+		metrics = make(map[string]models.Metrics)
+		s.Metrics[name] = metrics
 	}
-	return nil
+	return metrics
+}
+
+func (s *MemStorage) UpdateMetric(ctx context.Context, metric models.Metrics) error {
+	return s.UpdateMetricsWithBatch(ctx, []models.Metrics{metric})
 }
 
 func (s *MemStorage) GetMetric(ctx context.Context, id string) (models.Metrics, error) {
 	s.RLock()
 	defer s.RUnlock()
-	metric, exists := s.Metrics[id]
+	metric, exists := s.tenantMetrics(ctx, false)[id]
 	if !exists {
 		return models.Metrics{}, myerrors.ErrMetricNotFound
 	}
 	return metric, nil
 }
 
-func (s *MemStorage) GetAllGauges(ctx context.Context) (map[string]float64, error) {
-	s.RLock()
-	defer s.RUnlock()
-	all := make(map[string]float64)
-	for key, value := range s.Metrics {
-		if value.Value != nil {
-			all[key] = *value.Value
-		}
+// ListMetrics возвращает метрики арендатора, подходящие под фильтр, упорядоченные по ID
+func (s *MemStorage) ListMetrics(ctx context.Context, filter models.MetricsFilter) ([]models.Metrics, error) {
+	const op = "internal.repo.storage.mem.ListMetrics"
+
+	pattern, err := filter.MatchRegexp()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	var re *regexp.Regexp
+	if pattern != "" {
+		re = regexp.MustCompile(pattern)
 	}
-	return all, nil
-}
 
-func (s *MemStorage) GetAllCounters(ctx context.Context) (map[string]int64, error) {
 	s.RLock()
-	defer s.RUnlock()
-	all := make(map[string]int64)
-	for key, value := range s.Metrics {
-		if value.Delta != nil {
-			all[key] = *value.Delta
+	metrics := make([]models.Metrics, 0)
+	for id, metric := range s.tenantMetrics(ctx, false) {
+		switch {
+		case filter.Type != "" && metric.MType != filter.Type,
+			!strings.HasPrefix(id, filter.Prefix),
+			filter.Cursor != "" && id <= filter.Cursor,
+			re != nil && !re.MatchString(id):
+			continue
 		}
+		metrics = append(metrics, metric)
 	}
-	return all, nil
+	s.RUnlock()
+
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].ID < metrics[j].ID })
+	if filter.Limit > 0 && len(metrics) > filter.Limit {
+		metrics = metrics[:filter.Limit]
+	}
+	return metrics, nil
 }
 
 func (s *MemStorage) UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error {
 	s.Lock()
 	defer s.Unlock()
 
+	tenantMetrics := s.tenantMetrics(ctx, true)
+
+	// Сначала считаем все новые значения, чтобы ошибка не оставила батч применённым наполовину
+	staged := make(map[string]models.Metrics, len(metrics))
 	for _, metric := range metrics {
-		s.updateMetricUnsafe(metric)
+		current, exists := staged[metric.ID]
+		if !exists {
+			current, exists = tenantMetrics[metric.ID]
+		}
+		merged, err := mergeMetric(current, exists, metric)
+		if err != nil {
+			return err
+		}
+		staged[metric.ID] = merged
+	}
+
+	for id, metric := range staged {
+		tenantMetrics[id] = metric
 	}
 	return nil
 }
 
-func (s *MemStorage) updateMetricUnsafe(metric models.Metrics) {
-	currentMetric, exists := s.Metrics[metric.ID]
+// mergeMeta дополняет сохранённое описание непустыми полями обновления,
+// как COALESCE в хранилище Postgres
+func mergeMeta(stored, in *models.MetricMeta) *models.MetricMeta {
+	if in == nil {
+		return stored
+	}
+	if stored == nil {
+		return in
+	}
+	merged := *stored
+	if in.Unit != "" {
+		merged.Unit = in.Unit
+	}
+	if in.Help != "" {
+		merged.Help = in.Help
+	}
+	if in.Source != "" {
+		merged.Source = in.Source
+	}
+	return &merged
+}
+
+// mergeMetric применяет обновление к текущему значению метрики и возвращает новое значение
+func mergeMetric(currentMetric models.Metrics, exists bool, metric models.Metrics) (models.Metrics, error) {
+	now := time.Now()
+
+	meta := metric.Meta
+	if exists {
+		meta = mergeMeta(currentMetric.Meta, metric.Meta)
+	}
 
 	switch metric.MType {
 	case models.Gauge:
-		s.Metrics[metric.ID] = models.Metrics{
-			MType: models.Gauge,
-			ID:    metric.ID,
-			Value: metric.Value,
-		}
+		return models.Metrics{
+			MType:     models.Gauge,
+			ID:        metric.ID,
+			Value:     metric.Value,
+			Meta:      meta,
+			UpdatedAt: &now,
+		}, nil
 	case models.Counter:
 		var newDelta int64
-		if exists && currentMetric.Delta != nil {
+		if exists && currentMetric.MType == models.Counter && currentMetric.Delta != nil {
 			newDelta = *currentMetric.Delta
 		}
 		if metric.Delta != nil {
 			newDelta += *metric.Delta
 		}
-		s.Metrics[metric.ID] = models.Metrics{
-			MType: models.Counter,
-			ID:    metric.ID,
-			Delta: &newDelta,
+		return models.Metrics{
+			MType:     models.Counter,
+			ID:        metric.ID,
+			Delta:     &newDelta,
+			Meta:      meta,
+			UpdatedAt: &now,
+		}, nil
+	case models.Histogram:
+		var stored *models.HistogramData
+		if exists && currentMetric.MType == models.Histogram {
+			stored = currentMetric.Histogram
+		}
+		var in models.HistogramData
+		if metric.Histogram != nil {
+			in = *metric.Histogram
+		}
+		merged, err := models.MergeHistograms(stored, in)
+		if err != nil {
+			return models.Metrics{}, err
+		}
+		return models.Metrics{
+			MType:     models.Histogram,
+			ID:        metric.ID,
+			Histogram: merged,
+			Meta:      meta,
+			UpdatedAt: &now,
+		}, nil
+	}
+	return models.Metrics{}, myerrors.ErrInvalidMetricType
+}
+
+// DeleteMetric удаляет метрику с совпадающими ID и типом
+func (s *MemStorage) DeleteMetric(ctx context.Context, id, metricType string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	metrics := s.tenantMetrics(ctx, false)
+	if current, exists := metrics[id]; !exists || current.MType != metricType {
+		return myerrors.ErrMetricNotFound
+	}
+	delete(metrics, id)
+	return nil
+}
+
+// DeleteMetricsWithBatch удаляет метрики с совпадающими ID и типом
+func (s *MemStorage) DeleteMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error {
+	s.Lock()
+	defer s.Unlock()
+
+	stored := s.tenantMetrics(ctx, false)
+	for _, metric := range metrics {
+		if current, exists := stored[metric.ID]; exists && current.MType == metric.MType {
+			delete(stored, metric.ID)
+		}
+	}
+	return nil
+}
+
+// ReplaceMetrics атомарно заменяет все метрики арендатора на metrics
+func (s *MemStorage) ReplaceMetrics(ctx context.Context, metrics []models.Metrics) error {
+	staged := make(map[string]models.Metrics, len(metrics))
+	for _, metric := range metrics {
+		current, exists := staged[metric.ID]
+		merged, err := mergeMetric(current, exists, metric)
+		if err != nil {
+			return err
+		}
+		staged[metric.ID] = merged
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.Metrics[tenant.FromContext(ctx)] = staged
+	return nil
+}
+
+// DeleteStaleMetrics удаляет метрики всех арендаторов, не обновлявшиеся с момента before.
+// Мапы арендаторов не удаляются, чтобы следующий бэкап перезаписал их файлы.
+func (s *MemStorage) DeleteStaleMetrics(ctx context.Context, before time.Time) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var deleted int64
+	for _, metrics := range s.Metrics {
+		for id, metric := range metrics {
+			if metric.UpdatedAt == nil || metric.UpdatedAt.Before(before) {
+				delete(metrics, id)
+				deleted++
+			}
 		}
 	}
+	return deleted, nil
 }
 
+// SaveBkpToFile сохраняет метрики каждого арендатора в отдельный файл:
+// tenant.Default пишется в path, остальные — в path.tenant-<tenant>
 func (s *MemStorage) SaveBkpToFile(path string) error {
 	const op = "internal.repo.storage.mem.SaveBkpToFile"
 	s.RLock()
 	defer s.RUnlock()
 
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	for name, metrics := range s.Metrics {
+		if err := saveTenantFile(tenantBkpPath(path, name), metrics); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return nil
+}
+
+func (s *MemStorage) LoadBkpFromFile(path string) error {
+	const op = "internal.repo.storage.mem.LoadBkpFromFile"
+	s.Lock()
+	defer s.Unlock()
+
+	loaded := make(map[string]map[string]models.Metrics)
+
+	metrics, err := loadTenantFile(path)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	defer file.Close()
-
-	// Сериализуем всю мапу одним JSON-объектом
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
+	if metrics != nil {
+		loaded[tenant.Default] = metrics
+	}
 
-	if err := encoder.Encode(s.Metrics); err != nil {
+	// Арендаторы берутся только из файлов с явным префиксом: посторонние path.bak,
+	// path.old или файлы редактора рядом с копией не должны стать арендаторами
+	files, err := filepath.Glob(path + tenantBkpSuffix + "*")
+	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	for _, file := range files {
+		name := strings.TrimPrefix(file, path+tenantBkpSuffix)
+		if !tenant.IsValid(name) || name == tenant.Default {
+			continue
+		}
+		metrics, err := loadTenantFile(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if metrics != nil {
+			loaded[name] = metrics
+		}
+	}
 
+	s.Metrics = loaded
 	return nil
 }
 
-func (s *MemStorage) LoadBkpFromFile(path string) error {
-	const op = "internal.repo.storage.mem.LoadBkpFromFile"
-	s.Lock()
-	defer s.Unlock()
+const tenantBkpSuffix = ".tenant-"
 
+func tenantBkpPath(path, name string) string {
+	if name == tenant.Default {
+		return path
+	}
+	return path + tenantBkpSuffix + name
+}
+
+func saveTenantFile(path string, metrics map[string]models.Metrics) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Сериализуем всю мапу одним JSON-объектом
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(metrics)
+}
+
+func loadTenantFile(path string) (map[string]models.Metrics, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil
+			return nil, nil
 		}
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, err
 	}
 	defer file.Close()
 
 	// Декодируем весь JSON-файл в мапу
 	var metrics map[string]models.Metrics
 	if err := json.NewDecoder(file).Decode(&metrics); err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, err
 	}
 
-	s.Metrics = metrics
-	return nil
+	// Бэкапы старого формата не содержат времени обновления: отсчитываем TTL от загрузки
+	now := time.Now()
+	for id, metric := range metrics {
+		if metric.UpdatedAt == nil {
+			metric.UpdatedAt = &now
+			metrics[id] = metric
+		}
+	}
+	return metrics, nil
 }
 
 // mock Ping
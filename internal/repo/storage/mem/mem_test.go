@@ -0,0 +1,234 @@
+package mem_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/internal/repo/storage/mem"
+	"github.com/zetcan333/metrics-collector/pkg/myerrors"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	s := mem.NewStorage()
+	teamA := tenant.WithTenant(context.Background(), "teamA")
+	teamB := tenant.WithTenant(context.Background(), "teamB")
+
+	a, b := 1.5, 2.5
+	require.NoError(t, s.UpdateMetric(teamA, models.Metrics{ID: "Load", MType: models.Gauge, Value: &a}))
+	require.NoError(t, s.UpdateMetric(teamB, models.Metrics{ID: "Load", MType: models.Gauge, Value: &b}))
+
+	got, err := s.GetMetric(teamA, "Load")
+	require.NoError(t, err)
+	assert.Equal(t, a, *got.Value)
+
+	got, err = s.GetMetric(teamB, "Load")
+	require.NoError(t, err)
+	assert.Equal(t, b, *got.Value)
+
+	_, err = s.GetMetric(context.Background(), "Load")
+	assert.ErrorIs(t, err, myerrors.ErrMetricNotFound)
+
+	list, err := s.ListMetrics(teamA, models.MetricsFilter{})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, a, *list[0].Value)
+}
+
+func TestTenantBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	teamA := tenant.WithTenant(context.Background(), "teamA")
+
+	s := mem.NewStorage()
+	def, team := int64(3), int64(7)
+	require.NoError(t, s.UpdateMetric(context.Background(), models.Metrics{ID: "Hits", MType: models.Counter, Delta: &def}))
+	require.NoError(t, s.UpdateMetric(teamA, models.Metrics{ID: "Hits", MType: models.Counter, Delta: &team}))
+	require.NoError(t, s.SaveBkpToFile(path))
+
+	assert.FileExists(t, path)
+	assert.FileExists(t, path+".tenant-teamA")
+
+	// Копия рядом с файлом резервной копии не загружается как арендатор "bak"
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path+".bak", data, 0644))
+
+	restored := mem.NewStorage()
+	require.NoError(t, restored.LoadBkpFromFile(path))
+
+	_, err = restored.GetMetric(tenant.WithTenant(context.Background(), "bak"), "Hits")
+	assert.ErrorIs(t, err, myerrors.ErrMetricNotFound)
+
+	got, err := restored.GetMetric(context.Background(), "Hits")
+	require.NoError(t, err)
+	assert.Equal(t, def, *got.Delta)
+
+	got, err = restored.GetMetric(teamA, "Hits")
+	require.NoError(t, err)
+	assert.Equal(t, team, *got.Delta)
+}
+
+func TestDeleteStaleMetrics(t *testing.T) {
+	s := mem.NewStorage()
+	teamA := tenant.WithTenant(context.Background(), "teamA")
+
+	v := 1.0
+	require.NoError(t, s.UpdateMetric(context.Background(), models.Metrics{ID: "Old", MType: models.Gauge, Value: &v}))
+	require.NoError(t, s.UpdateMetric(teamA, models.Metrics{ID: "Old", MType: models.Gauge, Value: &v}))
+
+	cutoff := time.Now()
+	require.NoError(t, s.UpdateMetric(context.Background(), models.Metrics{ID: "Fresh", MType: models.Gauge, Value: &v}))
+
+	deleted, err := s.DeleteStaleMetrics(context.Background(), cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	_, err = s.GetMetric(teamA, "Old")
+	assert.ErrorIs(t, err, myerrors.ErrMetricNotFound)
+
+	_, err = s.GetMetric(context.Background(), "Fresh")
+	assert.NoError(t, err)
+}
+
+func TestListMetricsFilter(t *testing.T) {
+	ctx := context.Background()
+	s := mem.NewStorage()
+	for _, id := range []string{"HeapAlloc", "HeapIdle", "HeapInuse", "Mallocs", "heap_custom"} {
+		v := 1.0
+		require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: id, MType: models.Gauge, Value: &v}))
+	}
+	delta := int64(1)
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "HeapCount", MType: models.Counter, Delta: &delta}))
+
+	ids := func(filter models.MetricsFilter) []string {
+		list, err := s.ListMetrics(ctx, filter)
+		require.NoError(t, err)
+		result := make([]string, 0, len(list))
+		for _, m := range list {
+			result = append(result, m.ID)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"HeapAlloc", "HeapCount", "HeapIdle", "HeapInuse"}, ids(models.MetricsFilter{Prefix: "Heap"}))
+	assert.Equal(t, []string{"HeapAlloc", "HeapIdle", "HeapInuse"}, ids(models.MetricsFilter{Prefix: "Heap", Type: models.Gauge}))
+	assert.Equal(t, []string{"HeapIdle", "HeapInuse"}, ids(models.MetricsFilter{Match: "Heap[!AC]*"}))
+	assert.Equal(t, []string{"HeapAlloc", "Mallocs"}, ids(models.MetricsFilter{Match: "re:(?i)alloc"}))
+	assert.Equal(t, []string{"HeapIdle", "HeapInuse"}, ids(models.MetricsFilter{Type: models.Gauge, Cursor: "HeapAlloc", Limit: 2}))
+
+	_, err := s.ListMetrics(ctx, models.MetricsFilter{Match: "re:("})
+	assert.ErrorIs(t, err, myerrors.ErrInvalidFilter)
+}
+
+func TestTypeChangeReplacesMetric(t *testing.T) {
+	ctx := context.Background()
+	s := mem.NewStorage()
+
+	delta, value := int64(5), 2.5
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "Jobs", MType: models.Counter, Delta: &delta}))
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "Jobs", MType: models.Gauge, Value: &value}))
+
+	got, err := s.GetMetric(ctx, "Jobs")
+	require.NoError(t, err)
+	assert.Equal(t, models.Gauge, got.MType)
+	assert.Nil(t, got.Delta)
+
+	// Счётчик после gauge начинается заново
+	delta = 3
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "Jobs", MType: models.Counter, Delta: &delta}))
+	got, err = s.GetMetric(ctx, "Jobs")
+	require.NoError(t, err)
+	assert.Equal(t, models.Counter, got.MType)
+	assert.Equal(t, int64(3), *got.Delta)
+}
+
+func TestDeleteMetricsWithBatchChecksType(t *testing.T) {
+	ctx := context.Background()
+	s := mem.NewStorage()
+
+	delta, value := int64(1), 1.0
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "Hits", MType: models.Counter, Delta: &delta}))
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "Load", MType: models.Gauge, Value: &value}))
+
+	require.NoError(t, s.DeleteMetricsWithBatch(ctx, []models.Metrics{
+		{ID: "Hits", MType: models.Gauge},
+		{ID: "Load", MType: models.Gauge},
+		{ID: "Missing", MType: models.Gauge},
+	}))
+
+	_, err := s.GetMetric(ctx, "Hits")
+	assert.NoError(t, err, "Метрика другого типа не удаляется")
+	_, err = s.GetMetric(ctx, "Load")
+	assert.ErrorIs(t, err, myerrors.ErrMetricNotFound)
+}
+
+func TestDeleteMetricChecksType(t *testing.T) {
+	ctx := context.Background()
+	s := mem.NewStorage()
+
+	delta := int64(1)
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "Hits", MType: models.Counter, Delta: &delta}))
+
+	assert.ErrorIs(t, s.DeleteMetric(ctx, "Hits", models.Gauge), myerrors.ErrMetricNotFound)
+	_, err := s.GetMetric(ctx, "Hits")
+	assert.NoError(t, err, "Метрика другого типа не удаляется")
+
+	require.NoError(t, s.DeleteMetric(ctx, "Hits", models.Counter))
+	_, err = s.GetMetric(ctx, "Hits")
+	assert.ErrorIs(t, err, myerrors.ErrMetricNotFound)
+}
+
+func TestReplaceMetrics(t *testing.T) {
+	ctx := context.Background()
+	teamA := tenant.WithTenant(ctx, "teamA")
+	s := mem.NewStorage()
+
+	delta, value := int64(10), 1.0
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "Hits", MType: models.Counter, Delta: &delta}))
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "Old", MType: models.Gauge, Value: &value}))
+	require.NoError(t, s.UpdateMetric(teamA, models.Metrics{ID: "Hits", MType: models.Counter, Delta: &delta}))
+
+	// Некорректная метрика отменяет замену целиком
+	require.Error(t, s.ReplaceMetrics(ctx, []models.Metrics{
+		{ID: "Hits", MType: models.Counter, Delta: &delta},
+		{ID: "Bad", MType: "summary"},
+	}))
+	_, err := s.GetMetric(ctx, "Old")
+	require.NoError(t, err)
+
+	replaced := int64(3)
+	require.NoError(t, s.ReplaceMetrics(ctx, []models.Metrics{{ID: "Hits", MType: models.Counter, Delta: &replaced}}))
+
+	metric, err := s.GetMetric(ctx, "Hits")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *metric.Delta, "Счётчик заменяется, а не суммируется")
+	_, err = s.GetMetric(ctx, "Old")
+	assert.ErrorIs(t, err, myerrors.ErrMetricNotFound)
+
+	metric, err = s.GetMetric(teamA, "Hits")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), *metric.Delta, "Другие арендаторы не затрагиваются")
+}
+
+func TestPartialMetaKeepsStoredFields(t *testing.T) {
+	s := mem.NewStorage()
+	ctx := context.Background()
+
+	value := 1.0
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "HeapAlloc", MType: models.Gauge, Value: &value,
+		Meta: &models.MetricMeta{Unit: "bytes", Help: "heap bytes", Source: "runtime"}}))
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "HeapAlloc", MType: models.Gauge, Value: &value,
+		Meta: &models.MetricMeta{Help: "allocated heap bytes"}}))
+
+	got, err := s.GetMetric(ctx, "HeapAlloc")
+	require.NoError(t, err)
+	require.NotNil(t, got.Meta)
+	assert.Equal(t, models.MetricMeta{Unit: "bytes", Help: "allocated heap bytes", Source: "runtime"}, *got.Meta,
+		"Пустые поля обновления не затирают сохранённое описание")
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/zetcan333/metrics-collector/internal/lib/pgretry"
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
 	"github.com/zetcan333/metrics-collector/internal/models"
 	"github.com/zetcan333/metrics-collector/pkg/myerrors"
 )
@@ -38,14 +42,42 @@ func (p *PgStorage) Ping(ctx context.Context) error {
 func (p *PgStorage) InitTable(ctx context.Context) error {
 	const op = "internal.repo.storage.postgres.InitTable"
 
-	query := `
+	// Метрики разделены по арендаторам: первичный ключ (tenant, id).
+	// Таблицы, созданные до появления арендаторов, мигрируются на месте.
+	queries := []string{`
 	CREATE TABLE IF NOT EXISTS metrics (
-        ID TEXT PRIMARY KEY,
+		tenant TEXT NOT NULL DEFAULT 'default',
+        ID TEXT NOT NULL,
 		type TEXT NOT NULL,
         value DOUBLE PRECISION NOT NULL,
-        delta INT8 NOT NULL
+        delta INT8 NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (tenant, id)
     );
-	`
+	`, `
+	ALTER TABLE metrics ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT 'default';
+	`, `
+	ALTER TABLE metrics ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+	`, `
+	ALTER TABLE metrics
+		ADD COLUMN IF NOT EXISTS unit TEXT,
+		ADD COLUMN IF NOT EXISTS help TEXT,
+		ADD COLUMN IF NOT EXISTS source TEXT,
+		ADD COLUMN IF NOT EXISTS histogram JSONB;
+	`, `
+	CREATE INDEX IF NOT EXISTS metrics_updated_at_idx ON metrics (updated_at);
+	`, `
+	DO $$
+	BEGIN
+		IF NOT EXISTS (
+			SELECT 1 FROM information_schema.key_column_usage
+			WHERE table_name = 'metrics' AND constraint_name = 'metrics_pkey' AND column_name = 'tenant'
+		) THEN
+			ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_pkey;
+			ALTER TABLE metrics ADD PRIMARY KEY (tenant, id);
+		END IF;
+	END $$;
+	`}
 	_, err := pgretry.Retry(ctx, op, func() (struct{}, error) {
 		tx, err := p.db.Begin(ctx)
 		if err != nil {
@@ -53,9 +85,11 @@ func (p *PgStorage) InitTable(ctx context.Context) error {
 		}
 		defer tx.Rollback(ctx)
 
-		if _, err := tx.Exec(ctx, query); err != nil {
-			tx.Rollback(ctx)
-			return struct{}{}, fmt.Errorf("%s: %w", op, err)
+		for _, query := range queries {
+			if _, err := tx.Exec(ctx, query); err != nil {
+				tx.Rollback(ctx)
+				return struct{}{}, fmt.Errorf("%s: %w", op, err)
+			}
 		}
 		if err := tx.Commit(ctx); err != nil {
 			return struct{}{}, fmt.Errorf("%s: %w", op, err)
@@ -64,31 +98,126 @@ func (p *PgStorage) InitTable(ctx context.Context) error {
 	})
 	return err
 }
+
+// Метаданные (unit, help, source) перезаписываются только если пришли в обновлении.
+// Запись с другим типом, как и в памяти, заменяет метрику: значения прежнего типа сбрасываются.
+const (
+	upsertGaugeQuery = `
+	INSERT INTO metrics (tenant, id, type, value, delta, unit, help, source)
+	VALUES ($1, $2, $3, $4, 0, $5, $6, $7)
+	ON CONFLICT (tenant, id) DO UPDATE
+	SET type = $3, value = $4, delta = 0, histogram = NULL, updated_at = now(),
+		unit = COALESCE(EXCLUDED.unit, metrics.unit),
+		help = COALESCE(EXCLUDED.help, metrics.help),
+		source = COALESCE(EXCLUDED.source, metrics.source)
+	`
+	upsertCounterQuery = `
+	INSERT INTO metrics (tenant, id, type, value, delta, unit, help, source)
+	VALUES ($1, $2, $3, 0, $4, $5, $6, $7)
+	ON CONFLICT (tenant, id) DO UPDATE
+	SET type = $3, value = 0, histogram = NULL, updated_at = now(),
+		delta = CASE WHEN metrics.type = $3 THEN metrics.delta + $4 ELSE $4 END,
+		unit = COALESCE(EXCLUDED.unit, metrics.unit),
+		help = COALESCE(EXCLUDED.help, metrics.help),
+		source = COALESCE(EXCLUDED.source, metrics.source)
+	`
+	upsertHistogramQuery = `
+	INSERT INTO metrics (tenant, id, type, value, delta, histogram, unit, help, source)
+	VALUES ($1, $2, $3, 0, 0, $4, $5, $6, $7)
+	ON CONFLICT (tenant, id) DO UPDATE
+	SET type = $3, value = 0, delta = 0, histogram = $4, updated_at = now(),
+		unit = COALESCE(EXCLUDED.unit, metrics.unit),
+		help = COALESCE(EXCLUDED.help, metrics.help),
+		source = COALESCE(EXCLUDED.source, metrics.source)
+	`
+)
+
+// querier — общий интерфейс пула и транзакции
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func upsertMetric(ctx context.Context, db querier, name string, metric models.Metrics) error {
+	unit, help, source := metaArgs(metric.Meta)
+
+	var err error
+	switch metric.MType {
+	case models.Gauge:
+		_, err = db.Exec(ctx, upsertGaugeQuery, name, metric.ID, metric.MType, *metric.Value, unit, help, source)
+	case models.Counter:
+		_, err = db.Exec(ctx, upsertCounterQuery, name, metric.ID, metric.MType, *metric.Delta, unit, help, source)
+	case models.Histogram:
+		var merged *models.HistogramData
+		merged, err = mergeHistogram(ctx, db, name, metric)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(ctx, upsertHistogramQuery, name, metric.ID, metric.MType, merged, unit, help, source)
+	}
+	return err
+}
+
+// mergeHistogram блокирует строку гистограммы и добавляет к ней обновление, вызывать внутри транзакции.
+// FOR UPDATE не блокирует отсутствующую строку, поэтому сначала вставляется пустая:
+// параллельная первая запись дождётся её коммита и сольётся с результатом, а не затрёт его.
+func mergeHistogram(ctx context.Context, db querier, name string, metric models.Metrics) (*models.HistogramData, error) {
+	_, err := db.Exec(ctx, `
+	INSERT INTO metrics (tenant, id, type, value, delta) VALUES ($1, $2, $3, 0, 0)
+	ON CONFLICT (tenant, id) DO NOTHING
+	`, name, metric.ID, models.Histogram)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		storedType string
+		stored     *models.HistogramData
+	)
+	err = db.QueryRow(ctx, `
+	SELECT type, histogram FROM metrics WHERE tenant = $1 AND id = $2 FOR UPDATE
+	`, name, metric.ID).Scan(&storedType, &stored)
+	if err != nil {
+		return nil, err
+	}
+	if storedType != models.Histogram {
+		stored = nil
+	}
+
+	var in models.HistogramData
+	if metric.Histogram != nil {
+		in = *metric.Histogram
+	}
+	return models.MergeHistograms(stored, in)
+}
+
+// metaArgs возвращает поля описания для upsert. Пустое поле передаётся как NULL,
+// чтобы COALESCE в запросе сохранил ранее записанное значение.
+func metaArgs(meta *models.MetricMeta) (unit, help, source *string) {
+	if meta == nil {
+		return nil, nil, nil
+	}
+	return nonEmpty(meta.Unit), nonEmpty(meta.Help), nonEmpty(meta.Source)
+}
+
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
 func (p *PgStorage) UpdateMetric(ctx context.Context, metric models.Metrics) error {
 	const op = "internal.repo.storage.postgres.UpdateMetric"
+	name := tenant.FromContext(ctx)
 
-	_, err := pgretry.Retry(ctx, op, func() (struct{}, error) {
+	// Гистограмма обновляется через read-modify-write, ей нужна транзакция
+	if metric.MType == models.Histogram {
+		return p.UpdateMetricsWithBatch(ctx, []models.Metrics{metric})
+	}
 
-		var err error
-
-		switch metric.MType {
-		case models.Gauge:
-			_, err = p.db.Exec(ctx, `
-			INSERT INTO metrics (id, type, value, delta) 
-			VALUES ($1, $2, $3, 0) 
-			ON CONFLICT (id) DO UPDATE 
-			SET value = $3
-		`, metric.ID, metric.MType, *metric.Value)
-
-		case models.Counter:
-			_, err = p.db.Exec(ctx, `
-			INSERT INTO metrics (id, type, value, delta)
-			VALUES ($1, $2, 0, $3)
-			ON CONFLICT (id) DO UPDATE
-			SET delta = metrics.delta + $3
-		`, metric.ID, metric.MType, *metric.Delta)
-		}
-		if err != nil {
+	_, err := pgretry.Retry(ctx, op, func() (struct{}, error) {
+		if err := upsertMetric(ctx, p.db, name, metric); err != nil {
 			return struct{}{}, fmt.Errorf("%s: %w", op, err)
 		}
 		return struct{}{}, nil
@@ -97,119 +226,225 @@ func (p *PgStorage) UpdateMetric(ctx context.Context, metric models.Metrics) err
 }
 func (p *PgStorage) GetMetric(ctx context.Context, id string) (models.Metrics, error) {
 	const op = "internal.repo.storage.postgres.GetMetric"
-	var (
-		metric     models.Metrics
-		metricType string
-		value      float64
-		delta      int64
-	)
+
 	return pgretry.Retry(ctx, op, func() (models.Metrics, error) {
-		err := p.db.QueryRow(ctx, `SELECT id, type, value, delta FROM metrics WHERE id = $1`, id).
-			Scan(&metric.ID, &metricType, &value, &delta)
+		metric, err := scanMetric(p.db.QueryRow(ctx, `SELECT `+metricColumns+` FROM metrics WHERE tenant = $1 AND id = $2`, tenant.FromContext(ctx), id))
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return models.Metrics{}, myerrors.ErrMetricNotFound
 			}
 			return models.Metrics{}, fmt.Errorf("%s: %w", op, err)
 		}
-		metric.MType = metricType
-
-		if metricType == models.Gauge {
-			metric.Value = &value
-		} else if metricType == models.Counter {
-			metric.Delta = &delta
-		}
 		return metric, nil
 	})
 
 }
 
-func (p *PgStorage) GetAllGauges(ctx context.Context) (map[string]float64, error) {
-	const op = "internal.repo.storage.postgres.GetAllGauges"
+// ListMetrics возвращает метрики арендатора, подходящие под фильтр, упорядоченные по ID.
+// Фильтрация и пагинация выполняются в запросе. ID сравниваются побайтово (COLLATE "C"),
+// как в хранилище в памяти: курсор не должен зависеть от правил сортировки базы.
+func (p *PgStorage) ListMetrics(ctx context.Context, filter models.MetricsFilter) ([]models.Metrics, error) {
+	const op = "internal.repo.storage.postgres.ListMetrics"
 
-	return pgretry.Retry(ctx, op, func() (map[string]float64, error) {
-		rows, err := p.db.Query(ctx, `
-		SELECT id, value FROM metrics WHERE type = $1
-	`, models.Gauge)
+	query, args, err := listQuery(tenant.FromContext(ctx), filter)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return pgretry.Retry(ctx, op, func() ([]models.Metrics, error) {
+		rows, err := p.db.Query(ctx, query, args...)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 		defer rows.Close()
 
-		gauges := make(map[string]float64)
+		metrics := make([]models.Metrics, 0)
 		for rows.Next() {
-			var id string
-			var value float64
-			if err := rows.Scan(&id, &value); err != nil {
+			metric, err := scanMetric(rows)
+			if err != nil {
 				return nil, fmt.Errorf("%s: %w", op, err)
 			}
-			gauges[id] = value
+			metrics = append(metrics, metric)
 		}
-
 		if err := rows.Err(); err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		return gauges, nil
+		return metrics, nil
 	})
 }
 
-func (p *PgStorage) GetAllCounters(ctx context.Context) (map[string]int64, error) {
-	const op = "internal.repo.storage.postgres.GetAllCounters"
+func listQuery(name string, filter models.MetricsFilter) (string, []any, error) {
+	pattern, err := filter.MatchRegexp()
+	if err != nil {
+		return "", nil, err
+	}
+	if pattern != "" {
+		if pattern, err = toPostgresRegexp(pattern); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var b strings.Builder
+	args := []any{name}
+	b.WriteString(`SELECT ` + metricColumns + ` FROM metrics WHERE tenant = $1`)
+
+	cond := func(sql string, arg any) {
+		args = append(args, arg)
+		fmt.Fprintf(&b, sql, len(args))
+	}
+	if filter.Type != "" {
+		cond(` AND type = $%d`, filter.Type)
+	}
+	if filter.Prefix != "" {
+		cond(` AND id LIKE $%d`, likeEscaper.Replace(filter.Prefix)+"%")
+	}
+	if pattern != "" {
+		cond(` AND id ~ $%d`, pattern)
+	}
+	if filter.Cursor != "" {
+		cond(` AND id COLLATE "C" > $%d`, filter.Cursor)
+	}
+	b.WriteString(` ORDER BY id COLLATE "C"`)
+	if filter.Limit > 0 {
+		cond(` LIMIT $%d`, filter.Limit)
+	}
+	return b.String(), args, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+const metricColumns = `id, type, value, delta, updated_at, unit, help, source, histogram`
+
+// scanMetric читает строку с колонками metricColumns
+func scanMetric(row pgx.Row) (models.Metrics, error) {
+	var (
+		metric    models.Metrics
+		value     float64
+		delta     int64
+		updatedAt time.Time
+		unit      *string
+		help      *string
+		source    *string
+		histogram *models.HistogramData
+	)
+	if err := row.Scan(&metric.ID, &metric.MType, &value, &delta, &updatedAt, &unit, &help, &source, &histogram); err != nil {
+		return models.Metrics{}, err
+	}
+	metric.UpdatedAt = &updatedAt
+	metric.Meta = metaFromColumns(unit, help, source)
+
+	switch metric.MType {
+	case models.Gauge:
+		metric.Value = &value
+	case models.Counter:
+		metric.Delta = &delta
+	case models.Histogram:
+		metric.Histogram = histogram
+	}
+	return metric, nil
+}
+
+func metaFromColumns(unit, help, source *string) *models.MetricMeta {
+	if unit == nil && help == nil && source == nil {
+		return nil
+	}
+	meta := &models.MetricMeta{}
+	if unit != nil {
+		meta.Unit = *unit
+	}
+	if help != nil {
+		meta.Help = *help
+	}
+	if source != nil {
+		meta.Source = *source
+	}
+	return meta
+}
+
+func (p *PgStorage) UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error {
+	const op = "internal.repo.storage.postgres.UpdateMetricsWithBatch"
+	name := tenant.FromContext(ctx)
+
+	_, err := pgretry.Retry(ctx, op, func() (struct{}, error) {
 
-	return pgretry.Retry(ctx, op, func() (map[string]int64, error) {
-		rows, err := p.db.Query(ctx, `
-	SELECT id, delta FROM metrics WHERE type = $1
-	`, models.Counter)
+		tx, err := p.db.Begin(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+			return struct{}{}, fmt.Errorf("%s: %w", op, err)
 		}
-		defer rows.Close()
-		counters := make(map[string]int64)
-		for rows.Next() {
-			var id string
-			var delta int64
-			if err := rows.Scan(&id, &delta); err != nil {
-				return nil, fmt.Errorf("%s: %w", op, err)
+		defer tx.Rollback(ctx)
+		for _, metric := range metrics {
+			if err := upsertMetric(ctx, tx, name, metric); err != nil {
+				tx.Rollback(ctx)
+				return struct{}{}, fmt.Errorf("%s: %w", op, err)
 			}
-			counters[id] = delta
 		}
-		if err := rows.Err(); err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+		if err := tx.Commit(ctx); err != nil {
+			return struct{}{}, fmt.Errorf("%s: %w", op, err)
 		}
-		return counters, nil
+		return struct{}{}, nil
 	})
+	return err
 }
 
-func (p *PgStorage) UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error {
-	const op = "internal.repo.storage.postgres.UpdateMetricsWithBatch"
+// DeleteMetric удаляет метрику с совпадающими ID и типом
+func (p *PgStorage) DeleteMetric(ctx context.Context, id, metricType string) error {
+	const op = "internal.repo.storage.postgres.DeleteMetric"
 
 	_, err := pgretry.Retry(ctx, op, func() (struct{}, error) {
+		tag, err := p.db.Exec(ctx, `DELETE FROM metrics WHERE tenant = $1 AND id = $2 AND type = $3`, tenant.FromContext(ctx), id, metricType)
+		if err != nil {
+			return struct{}{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if tag.RowsAffected() == 0 {
+			return struct{}{}, myerrors.ErrMetricNotFound
+		}
+		return struct{}{}, nil
+	})
+	if errors.Is(err, myerrors.ErrMetricNotFound) {
+		return myerrors.ErrMetricNotFound
+	}
+	return err
+}
+
+// DeleteMetricsWithBatch удаляет метрики с совпадающими ID и типом
+func (p *PgStorage) DeleteMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error {
+	const op = "internal.repo.storage.postgres.DeleteMetricsWithBatch"
+
+	ids := make([]string, 0, len(metrics))
+	types := make([]string, 0, len(metrics))
+	for _, metric := range metrics {
+		ids = append(ids, metric.ID)
+		types = append(types, metric.MType)
+	}
+
+	_, err := pgretry.Retry(ctx, op, func() (struct{}, error) {
+		_, err := p.db.Exec(ctx, `
+		DELETE FROM metrics
+		WHERE tenant = $1 AND (id, type) IN (SELECT * FROM unnest($2::text[], $3::text[]))
+		`, tenant.FromContext(ctx), ids, types)
+		if err != nil {
+			return struct{}{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return struct{}{}, nil
+	})
+	return err
+}
+
+// ReplaceMetrics заменяет все метрики арендатора на metrics в одной транзакции
+func (p *PgStorage) ReplaceMetrics(ctx context.Context, metrics []models.Metrics) error {
+	const op = "internal.repo.storage.postgres.ReplaceMetrics"
+	name := tenant.FromContext(ctx)
 
+	_, err := pgretry.Retry(ctx, op, func() (struct{}, error) {
 		tx, err := p.db.Begin(ctx)
 		if err != nil {
 			return struct{}{}, fmt.Errorf("%s: %w", op, err)
 		}
 		defer tx.Rollback(ctx)
+		if _, err := tx.Exec(ctx, `DELETE FROM metrics WHERE tenant = $1`, name); err != nil {
+			return struct{}{}, fmt.Errorf("%s: %w", op, err)
+		}
 		for _, metric := range metrics {
-			var err error
-			switch metric.MType {
-			case models.Gauge:
-				_, err = tx.Exec(ctx, `
-				INSERT INTO metrics (id, type, value, delta) 
-				VALUES ($1, $2, $3, 0) 
-				ON CONFLICT (id) DO UPDATE 
-				SET value = $3
-			`, metric.ID, metric.MType, *metric.Value)
-			case models.Counter:
-				_, err = tx.Exec(ctx, `
-				INSERT INTO metrics (id, type, value, delta)
-				VALUES ($1, $2, 0, $3)
-				ON CONFLICT (id) DO UPDATE
-				SET delta = metrics.delta + $3
-			`, metric.ID, metric.MType, *metric.Delta)
-			}
-			if err != nil {
-				tx.Rollback(ctx)
+			if err := upsertMetric(ctx, tx, name, metric); err != nil {
 				return struct{}{}, fmt.Errorf("%s: %w", op, err)
 			}
 		}
@@ -221,6 +456,19 @@ func (p *PgStorage) UpdateMetricsWithBatch(ctx context.Context, metrics []models
 	return err
 }
 
+// DeleteStaleMetrics удаляет метрики всех арендаторов, не обновлявшиеся с момента before
+func (p *PgStorage) DeleteStaleMetrics(ctx context.Context, before time.Time) (int64, error) {
+	const op = "internal.repo.storage.postgres.DeleteStaleMetrics"
+
+	return pgretry.Retry(ctx, op, func() (int64, error) {
+		tag, err := p.db.Exec(ctx, `DELETE FROM metrics WHERE updated_at < $1`, before)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return tag.RowsAffected(), nil
+	})
+}
+
 func (p *PgStorage) Close() {
 	if p.db != nil {
 		p.db.Close()
@@ -0,0 +1,165 @@
+package postgres_test
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/internal/repo/storage/postgres"
+)
+
+// Тесты работают с настоящей базой и пропускаются без TEST_DATABASE_DSN
+func newStorage(t *testing.T) *postgres.PgStorage {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	s, err := postgres.NewStorage(context.Background(), dsn)
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+	require.NoError(t, s.InitTable(context.Background()))
+	return s
+}
+
+func TestTypeChangeReplacesMetric(t *testing.T) {
+	s := newStorage(t)
+	ctx := tenant.WithTenant(context.Background(), t.Name())
+	t.Cleanup(func() { s.DeleteMetricsWithBatch(ctx, []models.Metrics{{ID: "Jobs", MType: models.Counter}}) })
+
+	delta, value := int64(5), 2.5
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "Jobs", MType: models.Counter, Delta: &delta}))
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "Jobs", MType: models.Gauge, Value: &value}))
+
+	got, err := s.GetMetric(ctx, "Jobs")
+	require.NoError(t, err)
+	assert.Equal(t, models.Gauge, got.MType)
+	assert.Equal(t, 2.5, *got.Value)
+
+	// Счётчик после gauge начинается заново, как в хранилище в памяти
+	delta = 3
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "Jobs", MType: models.Counter, Delta: &delta}))
+	got, err = s.GetMetric(ctx, "Jobs")
+	require.NoError(t, err)
+	assert.Equal(t, models.Counter, got.MType)
+	assert.Equal(t, int64(3), *got.Delta)
+}
+
+func TestReplaceMetrics(t *testing.T) {
+	s := newStorage(t)
+	ctx := tenant.WithTenant(context.Background(), t.Name())
+	t.Cleanup(func() { s.ReplaceMetrics(ctx, nil) })
+
+	delta, value := int64(10), 1.0
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "Hits", MType: models.Counter, Delta: &delta}))
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "Old", MType: models.Gauge, Value: &value}))
+
+	replaced := int64(3)
+	require.NoError(t, s.ReplaceMetrics(ctx, []models.Metrics{{ID: "Hits", MType: models.Counter, Delta: &replaced}}))
+
+	metrics, err := s.ListMetrics(ctx, models.MetricsFilter{})
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "Hits", metrics[0].ID)
+	assert.Equal(t, int64(3), *metrics[0].Delta, "Счётчик заменяется, а не суммируется")
+}
+
+func TestConcurrentFirstHistogramWrites(t *testing.T) {
+	s := newStorage(t)
+	ctx := tenant.WithTenant(context.Background(), t.Name())
+	t.Cleanup(func() { s.ReplaceMetrics(ctx, nil) })
+
+	const writers = 8
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.UpdateMetric(ctx, models.Metrics{
+				ID:        "Latency",
+				MType:     models.Histogram,
+				Histogram: &models.HistogramData{Bounds: []float64{1}, Observations: []float64{0.5}},
+			}))
+		}()
+	}
+	wg.Wait()
+
+	got, err := s.GetMetric(ctx, "Latency")
+	require.NoError(t, err)
+	require.NotNil(t, got.Histogram)
+	assert.Equal(t, uint64(writers), got.Histogram.Count, "Ни одна из первых записей не должна потеряться")
+}
+
+func TestListMetricsMatchesMemSemantics(t *testing.T) {
+	s := newStorage(t)
+	ctx := tenant.WithTenant(context.Background(), t.Name())
+	t.Cleanup(func() { s.ReplaceMetrics(ctx, nil) })
+
+	value := 1.0
+	ids := []string{"b", "Alloc", "alloc_bytes", "_x", "Z", "heap2", "Frees"}
+	for _, id := range ids {
+		require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: id, MType: models.Gauge, Value: &value}))
+	}
+
+	// Страницы идут в побайтовом порядке, как в хранилище в памяти, независимо от правил сортировки базы
+	var got []string
+	cursor := ""
+	for {
+		page, err := s.ListMetrics(ctx, models.MetricsFilter{Limit: 2, Cursor: cursor})
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		for _, m := range page {
+			got = append(got, m.ID)
+		}
+		cursor = page[len(page)-1].ID
+	}
+	assert.Equal(t, []string{"Alloc", "Frees", "Z", "_x", "alloc_bytes", "b", "heap2"}, got)
+
+	// Синтаксис RE2 переводится, а не передаётся в Postgres как есть
+	tests := []struct {
+		match string
+		want  []string
+	}{
+		{match: `re:(?i)^alloc`, want: []string{"Alloc", "alloc_bytes"}},
+		{match: `re:\d`, want: []string{"heap2"}},
+		{match: `re:\balloc\b`, want: nil},
+		{match: `re:^[[:upper:]]\w*$`, want: []string{"Alloc", "Frees", "Z"}},
+		{match: `*s`, want: []string{"Frees", "alloc_bytes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.match, func(t *testing.T) {
+			metrics, err := s.ListMetrics(ctx, models.MetricsFilter{Match: tt.match})
+			require.NoError(t, err)
+			var ids []string
+			for _, m := range metrics {
+				ids = append(ids, m.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
+
+func TestPartialMetaKeepsStoredFields(t *testing.T) {
+	s := newStorage(t)
+	ctx := tenant.WithTenant(context.Background(), t.Name())
+	t.Cleanup(func() { s.ReplaceMetrics(ctx, nil) })
+
+	value := 1.0
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "HeapAlloc", MType: models.Gauge, Value: &value,
+		Meta: &models.MetricMeta{Unit: "bytes", Help: "heap bytes", Source: "runtime"}}))
+	require.NoError(t, s.UpdateMetric(ctx, models.Metrics{ID: "HeapAlloc", MType: models.Gauge, Value: &value,
+		Meta: &models.MetricMeta{Help: "allocated heap bytes"}}))
+
+	got, err := s.GetMetric(ctx, "HeapAlloc")
+	require.NoError(t, err)
+	require.NotNil(t, got.Meta)
+	assert.Equal(t, models.MetricMeta{Unit: "bytes", Help: "allocated heap bytes", Source: "runtime"}, *got.Meta,
+		"Пустые поля обновления не затирают сохранённое описание")
+}
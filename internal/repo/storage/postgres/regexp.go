@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+
+	"github.com/zetcan333/metrics-collector/pkg/myerrors"
+)
+
+// maxARERepeat — предел счётчика повторений {n,m} в регулярных выражениях Postgres
+const maxARERepeat = 255
+
+// wordClass — символы слова для \b, как в RE2 (только ASCII)
+const wordClass = `[0-9A-Za-z_]`
+
+// toPostgresRegexp переводит регулярное выражение Go (RE2) в эквивалентное выражение
+// Postgres (ARE) для оператора ~. Синтаксис и флаги RE2 не передаются как есть: шаблон
+// разбирается и собирается заново из явных классов символов, поэтому (?i), \d, \b и т.п.
+// совпадают с тем же множеством ID, что и в хранилище в памяти.
+func toPostgresRegexp(pattern string) (string, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", myerrors.ErrInvalidFilter, err)
+	}
+	var b strings.Builder
+	if err := writeARE(&b, re); err != nil {
+		return "", fmt.Errorf("%w: %v", myerrors.ErrInvalidFilter, err)
+	}
+	return b.String(), nil
+}
+
+func writeARE(b *strings.Builder, re *syntax.Regexp) error {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		b.WriteString(`(?!x)x`)
+	case syntax.OpEmptyMatch:
+		b.WriteString(`(?:)`)
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			switch {
+			case r == 0:
+				// NUL в тексте Postgres не встречается
+				b.WriteString(`(?!x)x`)
+			case re.Flags&syntax.FoldCase != 0:
+				writeFoldedRune(b, r)
+			default:
+				writeRune(b, r)
+			}
+		}
+	case syntax.OpCharClass:
+		writeClass(b, re.Rune)
+	case syntax.OpAnyCharNotNL:
+		b.WriteString(`[^\n]`)
+	case syntax.OpAnyChar:
+		// Без флагов n/p точка в Postgres совпадает и с переводом строки
+		b.WriteString(`.`)
+	case syntax.OpBeginLine:
+		b.WriteString(`(?:^|(?<=\n))`)
+	case syntax.OpEndLine:
+		b.WriteString(`(?:$|(?=\n))`)
+	case syntax.OpBeginText:
+		b.WriteString(`^`)
+	case syntax.OpEndText:
+		b.WriteString(`$`)
+	case syntax.OpWordBoundary:
+		b.WriteString(`(?:(?<=` + wordClass + `)(?!` + wordClass + `)|(?<!` + wordClass + `)(?=` + wordClass + `))`)
+	case syntax.OpNoWordBoundary:
+		b.WriteString(`(?:(?<=` + wordClass + `)(?=` + wordClass + `)|(?<!` + wordClass + `)(?!` + wordClass + `))`)
+	case syntax.OpCapture:
+		return writeGroup(b, re.Sub[0], "")
+	case syntax.OpStar:
+		return writeGroup(b, re.Sub[0], "*")
+	case syntax.OpPlus:
+		return writeGroup(b, re.Sub[0], "+")
+	case syntax.OpQuest:
+		return writeGroup(b, re.Sub[0], "?")
+	case syntax.OpRepeat:
+		// Жадность не влияет на факт совпадения, который проверяет ~
+		if re.Min > maxARERepeat || re.Max > maxARERepeat {
+			return fmt.Errorf("repeat count above %d", maxARERepeat)
+		}
+		if re.Max == -1 {
+			return writeGroup(b, re.Sub[0], fmt.Sprintf("{%d,}", re.Min))
+		}
+		return writeGroup(b, re.Sub[0], fmt.Sprintf("{%d,%d}", re.Min, re.Max))
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if err := writeGroup(b, sub, ""); err != nil {
+				return err
+			}
+		}
+	case syntax.OpAlternate:
+		b.WriteString(`(?:`)
+		for i, sub := range re.Sub {
+			if i > 0 {
+				b.WriteString(`|`)
+			}
+			if err := writeARE(b, sub); err != nil {
+				return err
+			}
+		}
+		b.WriteString(`)`)
+	default:
+		return fmt.Errorf("unsupported regexp operator %v", re.Op)
+	}
+	return nil
+}
+
+func writeGroup(b *strings.Builder, re *syntax.Regexp, suffix string) error {
+	b.WriteString(`(?:`)
+	if err := writeARE(b, re); err != nil {
+		return err
+	}
+	b.WriteString(`)` + suffix)
+	return nil
+}
+
+// writeFoldedRune записывает класс из руны и всех её вариантов регистра, как (?i) в RE2
+func writeFoldedRune(b *strings.Builder, r rune) {
+	ranges := []rune{r, r}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		ranges = append(ranges, f, f)
+	}
+	writeClass(b, ranges)
+}
+
+// writeClass записывает класс из пар границ диапазонов, как в syntax.Regexp.Rune.
+// NUL в тексте Postgres не встречается, поэтому из диапазонов он исключается.
+func writeClass(b *strings.Builder, ranges []rune) {
+	var class strings.Builder
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := max(ranges[i], 1), ranges[i+1]
+		if lo > hi {
+			continue
+		}
+		writeRune(&class, lo)
+		if hi != lo {
+			class.WriteString(`-`)
+			writeRune(&class, hi)
+		}
+	}
+	if class.Len() == 0 {
+		b.WriteString(`(?!x)x`)
+		return
+	}
+	b.WriteString(`[` + class.String() + `]`)
+}
+
+// writeRune записывает руну как есть, если это ASCII-буква или цифра, иначе —
+// escape-последовательностью ARE, одинаково понятной внутри и вне класса символов
+func writeRune(b *strings.Builder, r rune) {
+	if r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+		b.WriteRune(r)
+		return
+	}
+	if r <= 0xFFFF {
+		fmt.Fprintf(b, `\u%04x`, r)
+		return
+	}
+	fmt.Fprintf(b, `\U%08x`, r)
+}
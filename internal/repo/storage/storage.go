@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/zetcan333/metrics-collector/internal/models"
 )
@@ -9,9 +10,12 @@ import (
 type Storage interface {
 	UpdateMetric(ctx context.Context, metric models.Metrics) error
 	GetMetric(ctx context.Context, id string) (models.Metrics, error)
-	GetAllGauges(ctx context.Context) (map[string]float64, error)
-	GetAllCounters(ctx context.Context) (map[string]int64, error)
+	ListMetrics(ctx context.Context, filter models.MetricsFilter) ([]models.Metrics, error)
 	UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error
+	DeleteMetric(ctx context.Context, id, metricType string) error
+	DeleteMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error
+	ReplaceMetrics(ctx context.Context, metrics []models.Metrics) error
+	DeleteStaleMetrics(ctx context.Context, before time.Time) (int64, error)
 	SaveBkpToFile(path string) error
 	LoadBkpFromFile(path string) error
 	Ping(ctx context.Context) error
@@ -11,35 +11,86 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/zetcan333/metrics-collector/internal/flags"
 	"github.com/zetcan333/metrics-collector/internal/handlers"
+	"github.com/zetcan333/metrics-collector/internal/handlers/admin"
+	"github.com/zetcan333/metrics-collector/internal/handlers/alerts"
+	"github.com/zetcan333/metrics-collector/internal/handlers/dashboard"
+	mwAdmin "github.com/zetcan333/metrics-collector/internal/handlers/middleware/admin"
 	"github.com/zetcan333/metrics-collector/internal/handlers/middleware/compressor/gziprespose"
 	"github.com/zetcan333/metrics-collector/internal/handlers/middleware/compressor/mygzip"
 	mwLogger "github.com/zetcan333/metrics-collector/internal/handlers/middleware/logger"
+	mwTenant "github.com/zetcan333/metrics-collector/internal/handlers/middleware/tenant"
 	"github.com/zetcan333/metrics-collector/internal/handlers/ping"
+	hStream "github.com/zetcan333/metrics-collector/internal/handlers/stream"
+	"github.com/zetcan333/metrics-collector/internal/usecase/alerting"
 	"github.com/zetcan333/metrics-collector/internal/usecase/backup"
+	"github.com/zetcan333/metrics-collector/internal/usecase/expiry"
+	"github.com/zetcan333/metrics-collector/internal/usecase/stream"
+	"github.com/zetcan333/metrics-collector/internal/usecase/transfer"
 	"go.uber.org/zap"
 )
 
+// Option подключает к роутеру сервера дополнительные маршруты
+type Option func(r chi.Router)
+
+// WithRoute регистрирует обработчик; к нему применяются все middleware сервера
+func WithRoute(method, pattern string, h http.HandlerFunc) Option {
+	return func(r chi.Router) {
+		r.Method(method, pattern, h)
+	}
+}
+
 type Server struct {
 	log    *zap.Logger
 	router *chi.Mux
 	flags  *flags.ServerFlags
 	backup *backup.BackupUsecase
+	expiry *expiry.ExpiryUsecase
+	alerts *alerting.AlertingUsecase
 }
 
-func NewServer(log *zap.Logger, handlers *handlers.ServerHandler, ping *ping.PingHandler, flags *flags.ServerFlags, backup *backup.BackupUsecase) *Server {
+func NewServer(log *zap.Logger, handlers *handlers.ServerHandler, ping *ping.PingHandler, flags *flags.ServerFlags, backup *backup.BackupUsecase, tenantTokens map[string]string, expiry *expiry.ExpiryUsecase, alertsUsecase *alerting.AlertingUsecase, hub *stream.Hub, transferUsecase *transfer.TransferUsecase, opts ...Option) *Server {
 	router := chi.NewRouter()
 
 	router.Use(mwLogger.New(log))
 	router.Use(mygzip.GzipMiddleware)
 	router.Use(gziprespose.GzipResponseMiddleware)
 
-	router.Route("/", func(r chi.Router) {
-		r.Get("/", handlers.GetAllMetrics)
+	// Страница дашборда статична и открывается без арендатора: токен она спрашивает сама
+	// и передаёт его в запросах к /api/metrics
+	router.Get("/", dashboard.New().Index)
+
+	router.Group(func(r chi.Router) {
+		r.Use(mwTenant.New(tenantTokens, flags.AllowAnonymous))
+
+		r.Get("/api/metrics", handlers.ListMetrics)
 
 		if ping != nil {
 			r.Get("/ping", ping.Ping)
 		}
 
+		if alertsUsecase != nil {
+			r.Get("/alerts", alerts.New(alertsUsecase).Alerts)
+		}
+
+		if hub != nil {
+			r.Get("/stream", hStream.New(hub).Stream)
+		}
+
+		// Выгрузка и замена метрик доступны только с токеном администратора: без него
+		// в режиме заголовков любой клиент мог бы прочитать или стереть чужого арендатора
+		if transferUsecase != nil && flags.AdminToken != "" {
+			adminHandler := admin.New(log, transferUsecase)
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(mwAdmin.New(flags.AdminToken))
+				r.Get("/export", adminHandler.Export)
+				r.Post("/import", adminHandler.Import)
+			})
+		}
+
+		for _, opt := range opts {
+			opt(r)
+		}
+
 		r.Route("/update", func(r chi.Router) {
 			r.Post("/{type}/{name}/{value}", handlers.UpdateMetric)
 			r.Post("/", handlers.UpdateViaModel)
@@ -49,14 +100,25 @@ func NewServer(log *zap.Logger, handlers *handlers.ServerHandler, ping *ping.Pin
 
 		r.Route("/value", func(r chi.Router) {
 			r.Get("/{type}/{name}", handlers.GetMetric)
+			r.Delete("/{type}/{name}", handlers.DeleteMetric)
 			r.Post("/", handlers.GetViaModel)
+			r.Delete("/", handlers.DeleteMetricsWithBatch)
 		})
 	})
 
-	return &Server{log: log, router: router, flags: flags, backup: backup}
+	return &Server{log: log, router: router, flags: flags, backup: backup, expiry: expiry, alerts: alertsUsecase}
+}
+
+// Handler возвращает роутер сервера со всеми middleware
+func (s *Server) Handler() http.Handler {
+	return s.router
 }
 
-func (s *Server) Start(ctx context.Context) {
+// Start запускает сервер и фоновые задачи и блокируется до отмены ctx или сигнала остановки.
+// При остановке сервер перестаёт принимать запросы, затем вызываются beforeBackup (например,
+// остановка приёмников других протоколов с их последним сбросом) и только потом сохраняется
+// итоговая резервная копия. Ошибка запуска HTTP-сервера тоже приводит к остановке и возвращается.
+func (s *Server) Start(ctx context.Context, beforeBackup ...func()) error {
 
 	if s.backup != nil {
 		if s.flags.Restore {
@@ -75,11 +137,11 @@ func (s *Server) Start(ctx context.Context) {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
+	serveErr := make(chan error, 1)
 	go func() {
 		s.log.Sugar().Infoln("Starting server...")
-		err := server.ListenAndServe()
-		if err != nil && err != http.ErrServerClosed {
-			s.log.Sugar().Fatalln("failed to start server", zap.Error(err))
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serveErr <- err
 		}
 	}()
 
@@ -105,13 +167,64 @@ func (s *Server) Start(ctx context.Context) {
 		}()
 	}
 
+	if s.expiry != nil && s.flags.TTLSweepPeriod > 0 {
+		ticker := time.NewTicker(s.flags.TTLSweepPeriod)
+		defer ticker.Stop()
+
+		go func() {
+			for {
+				select {
+				case <-ticker.C:
+					deleted, err := s.expiry.Sweep(ctx)
+					if err != nil {
+						s.log.Sugar().Errorln("Failed to delete stale metrics", zap.Error(err))
+					} else if deleted > 0 {
+						s.log.Sugar().Infoln("Stale metrics deleted:", deleted)
+					}
+
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	if s.alerts != nil && s.flags.AlertInterval > 0 {
+		ticker := time.NewTicker(s.flags.AlertInterval)
+		defer ticker.Stop()
+
+		go func() {
+			for {
+				select {
+				case now := <-ticker.C:
+					if err := s.alerts.Evaluate(ctx, now); err != nil {
+						s.log.Sugar().Errorln("Failed to evaluate alerting rules", zap.Error(err))
+					}
+
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	var err error
 	select {
 	case <-ctx.Done():
 	case <-stop:
+	case err = <-serveErr:
+		s.log.Sugar().Errorln("failed to start server", zap.Error(err))
 	}
 
 	s.log.Sugar().Infoln("Shutting down server...")
 
+	// Копия сохраняется последней, когда записи через HTTP и другие приёмники уже завершены
+	if err := server.Shutdown(context.Background()); err != nil {
+		s.log.Sugar().Errorln("Failed to shutdown server", zap.Error(err))
+	}
+	for _, fn := range beforeBackup {
+		fn()
+	}
 	if s.backup != nil {
 		if err := s.backup.SaveBackup(s.flags.FileStoragePath); err != nil {
 			s.log.Sugar().Errorln("Failed to save final backup", zap.Error(err))
@@ -119,7 +232,5 @@ func (s *Server) Start(ctx context.Context) {
 			s.log.Sugar().Infoln("Final backup saved")
 		}
 	}
-	if err := server.Shutdown(context.Background()); err != nil {
-		s.log.Sugar().Errorln("Failed to shutdown server", zap.Error(err))
-	}
+	return err
 }
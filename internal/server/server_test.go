@@ -0,0 +1,39 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zetcan333/metrics-collector/internal/flags"
+	"github.com/zetcan333/metrics-collector/internal/handlers"
+	"github.com/zetcan333/metrics-collector/internal/lib/zapdiscard"
+	"github.com/zetcan333/metrics-collector/internal/repo/storage/mem"
+	"github.com/zetcan333/metrics-collector/internal/server"
+	"github.com/zetcan333/metrics-collector/internal/usecase"
+)
+
+func TestDashboardInTokenMode(t *testing.T) {
+	log := zapdiscard.NewDiscardLogger()
+	h := handlers.NewServerHandler(log, usecase.NewSeverUsecase(mem.NewStorage()))
+	srv := httptest.NewServer(server.NewServer(log, h, nil, &flags.ServerFlags{}, nil, map[string]string{"t1": "teamA"}, nil, nil, nil, nil).Handler())
+	t.Cleanup(srv.Close)
+
+	get := func(path, token string) int {
+		r, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		assert.NoError(t, err)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := srv.Client().Do(r)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Страница открывается без токена, а данные отдаются только с ним
+	assert.Equal(t, http.StatusOK, get("/", ""))
+	assert.Equal(t, http.StatusUnauthorized, get("/api/metrics", ""))
+	assert.Equal(t, http.StatusOK, get("/api/metrics", "t1"))
+}
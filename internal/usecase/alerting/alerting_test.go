@@ -0,0 +1,200 @@
+package alerting_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+	"github.com/zetcan333/metrics-collector/internal/lib/zapdiscard"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/internal/repo/storage/mem"
+	"github.com/zetcan333/metrics-collector/internal/usecase/alerting"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := alerting.ParseRules(strings.NewReader(`
+# память
+HighHeap: HeapAlloc > 1e9 for 2m
+teamA/NoPolls: rate(PollCount) < 0.1 for 5m
+SlowGC: p99(GCPauseNs) >= 5e6
+`))
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+
+	assert.Equal(t, alerting.Rule{
+		Name: "HighHeap", Tenant: tenant.Default, Func: alerting.FuncValue, Metric: "HeapAlloc",
+		Op: ">", Threshold: 1e9, For: 2 * time.Minute, Expr: "HeapAlloc > 1e9 for 2m",
+	}, rules[0])
+	assert.Equal(t, "teamA", rules[1].Tenant)
+	assert.Equal(t, alerting.FuncRate, rules[1].Func)
+	assert.Equal(t, "p99", rules[2].Func)
+	assert.Equal(t, time.Duration(0), rules[2].For)
+
+	_, err = alerting.ParseRules(strings.NewReader("Bad: HeapAlloc >> 1"))
+	assert.Error(t, err)
+}
+
+type recorder struct {
+	alerts []alerting.Alert
+}
+
+func (r *recorder) Notify(alert alerting.Alert) {
+	r.alerts = append(r.alerts, alert)
+}
+
+func TestEvaluateStateMachine(t *testing.T) {
+	ctx := context.Background()
+	storage := mem.NewStorage()
+	rules, err := alerting.ParseRules(strings.NewReader("HighHeap: HeapAlloc > 100 for 2m"))
+	require.NoError(t, err)
+
+	notifier := &recorder{}
+	engine := alerting.NewAlertingUsecase(storage, notifier, rules)
+
+	setHeap := func(v float64) {
+		require.NoError(t, storage.UpdateMetric(ctx, models.Metrics{ID: "HeapAlloc", MType: models.Gauge, Value: &v}))
+	}
+	state := func() string { return engine.Alerts()[0].State }
+
+	start := time.Now()
+	require.NoError(t, engine.Evaluate(ctx, start))
+	assert.Equal(t, alerting.StateInactive, state(), "Метрики ещё нет")
+
+	setHeap(200)
+	require.NoError(t, engine.Evaluate(ctx, start))
+	assert.Equal(t, alerting.StatePending, state())
+
+	require.NoError(t, engine.Evaluate(ctx, start.Add(time.Minute)))
+	assert.Equal(t, alerting.StatePending, state())
+
+	require.NoError(t, engine.Evaluate(ctx, start.Add(2*time.Minute)))
+	assert.Equal(t, alerting.StateFiring, state())
+
+	setHeap(50)
+	require.NoError(t, engine.Evaluate(ctx, start.Add(3*time.Minute)))
+	assert.Equal(t, alerting.StateResolved, state())
+
+	require.Len(t, notifier.alerts, 2)
+	assert.Equal(t, alerting.StateFiring, notifier.alerts[0].State)
+	assert.Equal(t, alerting.StateResolved, notifier.alerts[1].State)
+}
+
+func TestEvaluateRate(t *testing.T) {
+	ctx := context.Background()
+	storage := mem.NewStorage()
+	rules, err := alerting.ParseRules(strings.NewReader("FastPolls: rate(PollCount) > 1"))
+	require.NoError(t, err)
+	engine := alerting.NewAlertingUsecase(storage, nil, rules)
+
+	add := func(d int64) {
+		require.NoError(t, storage.UpdateMetric(ctx, models.Metrics{ID: "PollCount", MType: models.Counter, Delta: &d}))
+	}
+
+	start := time.Now()
+	add(10)
+	require.NoError(t, engine.Evaluate(ctx, start))
+	assert.Equal(t, alerting.StateInactive, engine.Alerts()[0].State, "Для rate нужно два значения")
+
+	add(50)
+	require.NoError(t, engine.Evaluate(ctx, start.Add(10*time.Second)))
+	alert := engine.Alerts()[0]
+	assert.Equal(t, alerting.StateFiring, alert.State)
+	require.NotNil(t, alert.Value)
+	assert.InDelta(t, 5.0, *alert.Value, 1e-9)
+
+	// После пересоздания счётчик меньше прежнего: это сброс, а не отрицательная скорость
+	require.NoError(t, storage.DeleteMetric(ctx, "PollCount", models.Counter))
+	add(20)
+	require.NoError(t, engine.Evaluate(ctx, start.Add(20*time.Second)))
+	alert = engine.Alerts()[0]
+	require.NotNil(t, alert.Value)
+	assert.InDelta(t, 2.0, *alert.Value, 1e-9)
+}
+
+// slowGetter задерживает чтение метрики, пока тест не закроет release
+type slowGetter struct {
+	alerting.MetricGetter
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (g *slowGetter) GetMetric(ctx context.Context, id string) (models.Metrics, error) {
+	close(g.entered)
+	<-g.release
+	return g.MetricGetter.GetMetric(ctx, id)
+}
+
+func TestAlertsDoNotWaitForStorage(t *testing.T) {
+	rules, err := alerting.ParseRules(strings.NewReader("HighHeap: HeapAlloc > 100"))
+	require.NoError(t, err)
+	getter := &slowGetter{MetricGetter: mem.NewStorage(), entered: make(chan struct{}), release: make(chan struct{})}
+	engine := alerting.NewAlertingUsecase(getter, nil, rules)
+
+	done := make(chan error)
+	go func() { done <- engine.Evaluate(context.Background(), time.Now()) }()
+	<-getter.entered
+
+	// Вычисление ждёт хранилище, но состояние алертов по-прежнему читается
+	alerts := make(chan []alerting.Alert)
+	go func() { alerts <- engine.Alerts() }()
+	select {
+	case got := <-alerts:
+		assert.Equal(t, alerting.StateInactive, got[0].State)
+	case <-time.After(time.Second):
+		t.Fatal("Alerts ждёт чтения метрик")
+	}
+
+	close(getter.release)
+	require.NoError(t, <-done)
+}
+
+func TestWebhookNotifierDeduplicates(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []alerting.Alert
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert alerting.Alert
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&alert))
+		mu.Lock()
+		received = append(received, alert)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	notifier := alerting.NewWebhookNotifier(zapdiscard.NewDiscardLogger(), []string{server.URL})
+	go notifier.Run(ctx)
+
+	firedAt := time.Now()
+	alert := alerting.Alert{Name: "HighHeap", Tenant: tenant.Default, State: alerting.StateFiring, FiredAt: &firedAt}
+	notifier.Notify(alert)
+	notifier.Notify(alert)
+
+	resolvedAt := firedAt.Add(time.Minute)
+	alert.State, alert.ResolvedAt = alerting.StateResolved, &resolvedAt
+	notifier.Notify(alert)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, received, 2, "Повторное срабатывание не должно доставляться")
+	assert.Equal(t, alerting.StateResolved, received[1].State)
+}
@@ -0,0 +1,230 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/pkg/myerrors"
+)
+
+// Состояния алерта
+const (
+	StateInactive = "inactive"
+	StatePending  = "pending"
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+type MetricGetter interface {
+	GetMetric(ctx context.Context, id string) (models.Metrics, error)
+}
+
+// Notifier доставляет переходы алертов в firing и resolved
+type Notifier interface {
+	Notify(alert Alert)
+}
+
+// Alert — текущее состояние правила
+type Alert struct {
+	Name       string     `json:"name"`
+	Tenant     string     `json:"tenant"`
+	Expr       string     `json:"expr"`
+	State      string     `json:"state"`
+	Value      *float64   `json:"value,omitempty"`
+	ActiveAt   *time.Time `json:"active_at,omitempty"`
+	FiredAt    *time.Time `json:"fired_at,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+type sample struct {
+	value float64
+	at    time.Time
+}
+
+type ruleState struct {
+	rule  Rule
+	alert Alert
+	last  *sample // предыдущее значение counter для rate()
+}
+
+// AlertingUsecase периодически вычисляет правила и ведёт машину состояний
+// inactive -> pending -> firing -> resolved
+type AlertingUsecase struct {
+	repo     MetricGetter
+	notifier Notifier
+
+	evalMu sync.Mutex   // одно вычисление за раз: rate() зависит от порядка замеров
+	mu     sync.RWMutex // защищает состояние алертов; не держится во время чтения хранилища
+	states []*ruleState
+}
+
+func NewAlertingUsecase(repo MetricGetter, notifier Notifier, rules []Rule) *AlertingUsecase {
+	states := make([]*ruleState, 0, len(rules))
+	for _, rule := range rules {
+		states = append(states, &ruleState{
+			rule: rule,
+			alert: Alert{
+				Name:   rule.Name,
+				Tenant: rule.Tenant,
+				Expr:   rule.Expr,
+				State:  StateInactive,
+			},
+		})
+	}
+	return &AlertingUsecase{repo: repo, notifier: notifier, states: states}
+}
+
+// fetched — метрика правила, прочитанная из хранилища
+type fetched struct {
+	metric models.Metrics
+	found  bool
+	err    error
+}
+
+// Evaluate вычисляет все правила на момент now. Метрики читаются из хранилища до
+// блокировки состояния, чтобы GET /alerts не ждал ввода-вывода.
+func (a *AlertingUsecase) Evaluate(ctx context.Context, now time.Time) error {
+	a.evalMu.Lock()
+	defer a.evalMu.Unlock()
+
+	// Правила не меняются после создания, поэтому их можно читать без a.mu
+	metrics := make([]fetched, len(a.states))
+	for i, st := range a.states {
+		metric, err := a.repo.GetMetric(tenant.WithTenant(ctx, st.rule.Tenant), st.rule.Metric)
+		switch {
+		case err == nil:
+			metrics[i] = fetched{metric: metric, found: true}
+		case !errors.Is(err, myerrors.ErrMetricNotFound):
+			metrics[i] = fetched{err: err}
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var errs []error
+	for i, st := range a.states {
+		if metrics[i].err != nil {
+			errs = append(errs, metrics[i].err)
+			continue
+		}
+		value, ok := a.value(st, metrics[i].metric, metrics[i].found, now)
+
+		active := ok && st.rule.compare(value)
+		if ok {
+			st.alert.Value = &value
+		} else {
+			st.alert.Value = nil
+		}
+		a.transition(st, active, now)
+	}
+	return errors.Join(errs...)
+}
+
+func (a *AlertingUsecase) transition(st *ruleState, active bool, now time.Time) {
+	alert := &st.alert
+
+	switch {
+	case active && (alert.State == StateInactive || alert.State == StateResolved):
+		alert.State = StatePending
+		alert.ActiveAt = &now
+		alert.FiredAt = nil
+		alert.ResolvedAt = nil
+		if st.rule.For == 0 {
+			a.fire(alert, now)
+		}
+
+	case active && alert.State == StatePending:
+		if now.Sub(*alert.ActiveAt) >= st.rule.For {
+			a.fire(alert, now)
+		}
+
+	case !active && alert.State == StatePending:
+		alert.State = StateInactive
+		alert.ActiveAt = nil
+
+	case !active && alert.State == StateFiring:
+		alert.State = StateResolved
+		alert.ResolvedAt = &now
+		if a.notifier != nil {
+			a.notifier.Notify(*alert)
+		}
+	}
+}
+
+func (a *AlertingUsecase) fire(alert *Alert, now time.Time) {
+	alert.State = StateFiring
+	alert.FiredAt = &now
+	if a.notifier != nil {
+		a.notifier.Notify(*alert)
+	}
+}
+
+// value возвращает значение выражения правила по прочитанной метрике; ok=false,
+// если значения пока нет. Вызывается под a.mu.
+func (a *AlertingUsecase) value(st *ruleState, metric models.Metrics, found bool, now time.Time) (float64, bool) {
+	if !found {
+		st.last = nil
+		return 0, false
+	}
+
+	switch st.rule.Func {
+	case FuncValue:
+		switch {
+		case metric.Value != nil:
+			return *metric.Value, true
+		case metric.Delta != nil:
+			return float64(*metric.Delta), true
+		}
+
+	case FuncRate:
+		if metric.Delta == nil {
+			return 0, false
+		}
+		current := sample{value: float64(*metric.Delta), at: now}
+		prev := st.last
+		st.last = &current
+		if prev == nil || !now.After(prev.at) {
+			return 0, false
+		}
+		increase := current.value - prev.value
+		if current.value < prev.value {
+			// Счётчик сброшен, удалён или пересоздан после TTL: он снова начался с нуля,
+			// и весь его текущий итог накоплен за интервал
+			increase = current.value
+		}
+		return increase / now.Sub(prev.at).Seconds(), true
+
+	default:
+		if metric.Histogram == nil || metric.Histogram.Count == 0 {
+			return 0, false
+		}
+		q := metric.Histogram.Quantile(quantileFuncs[st.rule.Func])
+		return q, !math.IsNaN(q)
+	}
+	return 0, false
+}
+
+// Alerts возвращает состояние всех правил, отсортированное по арендатору и имени
+func (a *AlertingUsecase) Alerts() []Alert {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	alerts := make([]Alert, 0, len(a.states))
+	for _, st := range a.states {
+		alerts = append(alerts, st.alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Tenant != alerts[j].Tenant {
+			return alerts[i].Tenant < alerts[j].Tenant
+		}
+		return alerts[i].Name < alerts[j].Name
+	})
+	return alerts
+}
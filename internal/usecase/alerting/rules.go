@@ -0,0 +1,156 @@
+package alerting
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+)
+
+// Функции над метрикой, допустимые в выражении правила
+const (
+	FuncValue = ""     // значение gauge или накопленное значение counter
+	FuncRate  = "rate" // скорость роста counter в секунду между вычислениями
+)
+
+// Rule — пороговое правило вида "HeapAlloc > 1e9 for 2m"
+type Rule struct {
+	Name      string
+	Tenant    string
+	Func      string // FuncValue, FuncRate или квантиль гистограммы: p50, p90, p95, p99
+	Metric    string
+	Op        string
+	Threshold float64
+	For       time.Duration
+	Expr      string
+}
+
+var (
+	// [tenant/]name: expr
+	ruleLine = regexp.MustCompile(`^(?:([A-Za-z0-9_-]+)/)?([^:]+):\s*(.+)$`)
+	// func(metric) op threshold [for duration]
+	ruleExpr = regexp.MustCompile(`^(?:(rate|p50|p90|p95|p99)\(\s*([^()\s]+)\s*\)|([^()\s<>=!]+))\s*(>=|<=|==|!=|>|<)\s*(\S+)(?:\s+for\s+(\S+))?$`)
+)
+
+var quantileFuncs = map[string]float64{"p50": 0.5, "p90": 0.9, "p95": 0.95, "p99": 0.99}
+
+// LoadRules читает правила из файла
+func LoadRules(path string) ([]Rule, error) {
+	const op = "internal.usecase.alerting.LoadRules"
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer file.Close()
+
+	rules, err := ParseRules(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return rules, nil
+}
+
+// ParseRules разбирает правила по одному в строке:
+//
+//	# комментарий
+//	HighHeap: HeapAlloc > 1e9 for 2m
+//	teamA/NoPolls: rate(PollCount) < 0.1 for 5m
+//	SlowGC: p99(GCPauseNs) > 5e6
+//
+// Префикс "tenant/" привязывает правило к арендатору, по умолчанию tenant.Default.
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule, err := parseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		key := rule.Tenant + "/" + rule.Name
+		if seen[key] {
+			return nil, fmt.Errorf("line %d: duplicate rule %q", lineNum, rule.Name)
+		}
+		seen[key] = true
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func parseRule(line string) (Rule, error) {
+	m := ruleLine.FindStringSubmatch(line)
+	if m == nil {
+		return Rule{}, fmt.Errorf("expected \"name: expression\", got %q", line)
+	}
+
+	rule := Rule{
+		Tenant: m[1],
+		Name:   strings.TrimSpace(m[2]),
+		Expr:   strings.TrimSpace(m[3]),
+	}
+	if rule.Tenant == "" {
+		rule.Tenant = tenant.Default
+	}
+
+	e := ruleExpr.FindStringSubmatch(rule.Expr)
+	if e == nil {
+		return Rule{}, fmt.Errorf("invalid expression %q", rule.Expr)
+	}
+
+	rule.Func, rule.Metric = e[1], e[2]
+	if rule.Func == FuncValue {
+		rule.Metric = e[3]
+	}
+	rule.Op = e[4]
+
+	threshold, err := strconv.ParseFloat(e[5], 64)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid threshold %q: %w", e[5], err)
+	}
+	rule.Threshold = threshold
+
+	if e[6] != "" {
+		rule.For, err = time.ParseDuration(e[6])
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid duration %q: %w", e[6], err)
+		}
+	}
+	return rule, nil
+}
+
+func (r Rule) compare(value float64) bool {
+	switch r.Op {
+	case ">":
+		return value > r.Threshold
+	case ">=":
+		return value >= r.Threshold
+	case "<":
+		return value < r.Threshold
+	case "<=":
+		return value <= r.Threshold
+	case "==":
+		return value == r.Threshold
+	case "!=":
+		return value != r.Threshold
+	}
+	return false
+}
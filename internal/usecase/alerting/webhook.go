@@ -0,0 +1,106 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/lib/retry"
+	"go.uber.org/zap"
+)
+
+const defaultQueueSize = 256
+
+// WebhookNotifier отправляет переходы алертов POST-запросом с JSON-телом Alert
+// на каждый из адресов. Доставка асинхронная, с повторами; одно и то же
+// событие не доставляется на адрес дважды.
+type WebhookNotifier struct {
+	log    *zap.Logger
+	urls   []string
+	poster *retry.Poster
+	queue  chan Alert
+
+	mu        sync.Mutex
+	delivered map[string]string // url|tenant/name -> отпечаток последнего доставленного события
+}
+
+func NewWebhookNotifier(log *zap.Logger, urls []string) *WebhookNotifier {
+	return &WebhookNotifier{
+		log:       log,
+		urls:      urls,
+		poster:    &retry.Poster{Client: &http.Client{Timeout: 10 * time.Second}},
+		queue:     make(chan Alert, defaultQueueSize),
+		delivered: make(map[string]string),
+	}
+}
+
+// Notify ставит событие в очередь; при переполнении событие отбрасывается, чтобы не блокировать вычисление правил
+func (n *WebhookNotifier) Notify(alert Alert) {
+	select {
+	case n.queue <- alert:
+	default:
+		n.log.Sugar().Errorln("alert notification queue is full, dropping", alert.Tenant, alert.Name, alert.State)
+	}
+}
+
+// Run доставляет события из очереди до отмены контекста
+func (n *WebhookNotifier) Run(ctx context.Context) {
+	for {
+		select {
+		case alert := <-n.queue:
+			for _, url := range n.urls {
+				n.deliver(ctx, url, alert)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (n *WebhookNotifier) deliver(ctx context.Context, url string, alert Alert) {
+	key := url + "|" + alert.Tenant + "/" + alert.Name
+	fingerprint := fingerprint(alert)
+
+	n.mu.Lock()
+	duplicate := n.delivered[key] == fingerprint
+	n.mu.Unlock()
+	if duplicate {
+		return
+	}
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		n.log.Sugar().Errorln("failed to encode alert", zap.Error(err))
+		return
+	}
+
+	_, err = retry.Do(ctx, retry.Delays, func() (bool, error) {
+		retriable, err := n.poster.Send(ctx, url, body, nil)
+		if retriable {
+			n.log.Sugar().Infoln("alert delivery attempt failed", url, zap.Error(err))
+		}
+		return retriable, err
+	})
+	if err != nil {
+		n.log.Sugar().Errorln("failed to deliver alert", url, zap.Error(err))
+		return
+	}
+
+	n.mu.Lock()
+	n.delivered[key] = fingerprint
+	n.mu.Unlock()
+}
+
+// fingerprint различает события: одно срабатывание и его разрешение доставляются по одному разу
+func fingerprint(alert Alert) string {
+	at := alert.FiredAt
+	if alert.State == StateResolved {
+		at = alert.ResolvedAt
+	}
+	if at == nil {
+		return alert.State
+	}
+	return alert.State + "@" + at.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,25 @@
+package expiry
+
+import (
+	"context"
+	"time"
+)
+
+type StaleMetricsDeleter interface {
+	DeleteStaleMetrics(ctx context.Context, before time.Time) (int64, error)
+}
+
+// ExpiryUsecase удаляет метрики, которые не обновлялись дольше ttl
+type ExpiryUsecase struct {
+	repo StaleMetricsDeleter
+	ttl  time.Duration
+}
+
+func NewExpiryUsecase(repo StaleMetricsDeleter, ttl time.Duration) *ExpiryUsecase {
+	return &ExpiryUsecase{repo: repo, ttl: ttl}
+}
+
+// Sweep удаляет устаревшие метрики и возвращает их количество
+func (e *ExpiryUsecase) Sweep(ctx context.Context) (int64, error) {
+	return e.repo.DeleteStaleMetrics(ctx, time.Now().Add(-e.ttl))
+}
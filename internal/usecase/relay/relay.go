@@ -0,0 +1,202 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/lib/retry"
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"go.uber.org/zap"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+// Relay пересылает принятые сервером обновления на вышестоящие коллекторы.
+// У каждого upstream своя ограниченная очередь: медленный или недоступный
+// upstream теряет обновления сверх очереди, но не тормозит приём и другие upstream.
+//
+// Без токенов арендатор передаётся только заголовком X-Tenant-ID, который upstream
+// с файлом токенов игнорирует. Для таких upstream нужен WithTenantTokens.
+type Relay struct {
+	log           *zap.Logger
+	poster        *retry.Poster
+	dc            string
+	batchSize     int
+	flushInterval time.Duration
+	upstreams     []*upstream
+	tokens        map[string]string // tenant -> токен; nil — токены не используются
+}
+
+// Option настраивает Relay
+type Option func(*Relay)
+
+// WithTenantTokens задаёт токены арендаторов на upstream в формате mwTenant.LoadTokens
+// (token -> tenant). Метрики отправляются с Authorization: Bearer токена своего арендатора,
+// метрики арендаторов без токена не пересылаются. Токены общие для всех upstream.
+func WithTenantTokens(tokens map[string]string) Option {
+	return func(r *Relay) {
+		r.tokens = make(map[string]string, len(tokens))
+		for token, name := range tokens {
+			// У арендатора может быть несколько токенов: выбираем детерминированно
+			if current, ok := r.tokens[name]; !ok || token < current {
+				r.tokens[name] = token
+			}
+		}
+	}
+}
+
+type entry struct {
+	tenant string
+	metric models.Metrics
+}
+
+type upstream struct {
+	url     string
+	queue   chan entry
+	dropped atomic.Uint64
+}
+
+func NewRelay(log *zap.Logger, urls []string, key, dc string, queueSize, batchSize int, flushInterval time.Duration, opts ...Option) *Relay {
+	r := &Relay{
+		log:           log,
+		poster:        &retry.Poster{Client: &http.Client{Timeout: 10 * time.Second}, Key: key, Gzip: true},
+		dc:            dc,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+	}
+	for _, u := range urls {
+		r.upstreams = append(r.upstreams, &upstream{url: updatesURL(u), queue: make(chan entry, queueSize)})
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// updatesURL принимает адрес коллектора в виде host:port или базового URL
+func updatesURL(u string) string {
+	if !strings.Contains(u, "://") {
+		u = "http://" + u
+	}
+	return strings.TrimRight(u, "/") + "/updates/"
+}
+
+// OnUpdate реализует usecase.UpdateObserver и никогда не блокируется
+func (r *Relay) OnUpdate(ctx context.Context, metrics []models.Metrics) {
+	name := tenant.FromContext(ctx)
+	for _, metric := range metrics {
+		if r.dc != "" {
+			id, err := models.WithLabels(metric.ID, map[string]string{"dc": r.dc})
+			if err != nil {
+				r.log.Sugar().Errorln("relay: cannot add dc label", metric.ID, zap.Error(err))
+			} else {
+				metric.ID = id
+			}
+		}
+		for _, up := range r.upstreams {
+			select {
+			case up.queue <- entry{tenant: name, metric: metric}:
+			default:
+				up.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// Run отправляет очереди до отмены контекста, затем один раз пытается досылать остаток
+func (r *Relay) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, up := range r.upstreams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.runUpstream(ctx, up)
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *Relay) runUpstream(ctx context.Context, up *upstream) {
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]entry, 0, r.batchSize)
+	for {
+		select {
+		case e := <-up.queue:
+			batch = append(batch, e)
+			if len(batch) >= r.batchSize {
+				r.flush(ctx, up, batch)
+				batch = batch[:0]
+			}
+
+		case <-ticker.C:
+			if dropped := up.dropped.Swap(0); dropped > 0 {
+				r.log.Sugar().Errorln("relay queue is full, updates dropped:", up.url, dropped)
+			}
+			if len(batch) > 0 {
+				r.flush(ctx, up, batch)
+				batch = batch[:0]
+			}
+
+		case <-ctx.Done():
+			for len(up.queue) > 0 {
+				batch = append(batch, <-up.queue)
+			}
+			if len(batch) > 0 {
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+				r.flush(shutdownCtx, up, batch)
+				cancel()
+			}
+			return
+		}
+	}
+}
+
+// flush отправляет пачку: метрики разных арендаторов уходят разными запросами
+func (r *Relay) flush(ctx context.Context, up *upstream, batch []entry) {
+	byTenant := make(map[string][]models.Metrics)
+	for _, e := range batch {
+		byTenant[e.tenant] = append(byTenant[e.tenant], e.metric)
+	}
+
+	for name, metrics := range byTenant {
+		if err := r.deliver(ctx, up.url, name, metrics); err != nil {
+			r.log.Sugar().Errorln("relay: failed to forward metrics", up.url, name, len(metrics), zap.Error(err))
+		}
+	}
+}
+
+func (r *Relay) deliver(ctx context.Context, url, name string, metrics []models.Metrics) error {
+	body, err := json.Marshal(metrics)
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics: %w", err)
+	}
+
+	header := http.Header{}
+	if r.tokens != nil {
+		token, ok := r.tokens[name]
+		if !ok {
+			return fmt.Errorf("no upstream token for tenant %q", name)
+		}
+		header.Set("Authorization", "Bearer "+token)
+	} else {
+		header.Set("X-Tenant-ID", name)
+	}
+	_, err = retry.Do(ctx, retry.Delays, func() (bool, error) {
+		// Начатый запрос не прерываем при остановке: иначе потеряется уже вынутая из очереди пачка
+		retriable, err := r.poster.Send(context.WithoutCancel(ctx), url, body, header)
+		if retriable {
+			r.log.Sugar().Infoln("relay delivery attempt failed", url, zap.Error(err))
+		}
+		return retriable, err
+	})
+	return err
+}
@@ -0,0 +1,132 @@
+package relay_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/handlers"
+	"github.com/zetcan333/metrics-collector/internal/handlers/middleware/compressor/mygzip"
+	mwTenant "github.com/zetcan333/metrics-collector/internal/handlers/middleware/tenant"
+	"github.com/zetcan333/metrics-collector/internal/lib/sign"
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+	"github.com/zetcan333/metrics-collector/internal/lib/zapdiscard"
+	"github.com/zetcan333/metrics-collector/internal/repo/storage/mem"
+	"github.com/zetcan333/metrics-collector/internal/usecase"
+	"github.com/zetcan333/metrics-collector/internal/usecase/relay"
+)
+
+// upstream поднимает настоящий коллектор в процессе; первые failures запросов получают 503
+func upstream(t *testing.T, failures int32) (*httptest.Server, *usecase.SeverUsecase) {
+	return upstreamWithTokens(t, failures, nil)
+}
+
+func upstreamWithTokens(t *testing.T, failures int32, tokens map[string]string) (*httptest.Server, *usecase.SeverUsecase) {
+	uc := usecase.NewSeverUsecase(mem.NewStorage())
+	h := handlers.NewServerHandler(zapdiscard.NewDiscardLogger(), uc)
+
+	router := chi.NewRouter()
+	router.Use(mygzip.GzipMiddleware)
+	router.Use(mwTenant.New(tokens, false))
+	router.Post("/updates/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, sign.Sum(body, "secret"), r.Header.Get(sign.Header))
+
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		h.UpdateMetricsWithBatch(w, r)
+	})
+
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	return srv, uc
+}
+
+func TestRelayForwardsBatches(t *testing.T) {
+	healthy, healthyUC := upstream(t, 0)
+	flaky, flakyUC := upstream(t, 1)
+
+	r := relay.NewRelay(zapdiscard.NewDiscardLogger(), []string{healthy.URL, flaky.URL}, "secret", "eu1", 100, 2, 50*time.Millisecond)
+	local := usecase.NewSeverUsecase(mem.NewStorage(), usecase.WithUpdateObservers(r))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	teamA := tenant.WithTenant(context.Background(), "teamA")
+	require.NoError(t, local.UpdateMetric(context.Background(), "counter", "Polls", "2"))
+	require.NoError(t, local.UpdateMetric(context.Background(), "counter", "Polls", "3"))
+	require.NoError(t, local.UpdateMetric(teamA, "gauge", "Alloc", "0.5"))
+
+	for _, uc := range []*usecase.SeverUsecase{healthyUC, flakyUC} {
+		assert.Eventually(t, func() bool {
+			value, err := uc.GetMetric(context.Background(), "counter", `Polls{dc="eu1"}`)
+			return err == nil && value == "5"
+		}, 3*time.Second, 20*time.Millisecond)
+		assert.Eventually(t, func() bool {
+			value, err := uc.GetMetric(teamA, "gauge", `Alloc{dc="eu1"}`)
+			return err == nil && value == "0.5"
+		}, 3*time.Second, 20*time.Millisecond)
+	}
+
+	// Остаток очереди досылается при остановке
+	require.NoError(t, local.UpdateMetric(context.Background(), "gauge", "Last", "1"))
+	cancel()
+	<-done
+	value, err := healthyUC.GetMetric(context.Background(), "gauge", `Last{dc="eu1"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+}
+
+func TestRelayTenantTokens(t *testing.T) {
+	tokens := map[string]string{"default-token": tenant.Default, "a-token": "teamA"}
+	up, upUC := upstreamWithTokens(t, 0, tokens)
+
+	r := relay.NewRelay(zapdiscard.NewDiscardLogger(), []string{up.URL}, "secret", "", 100, 10, 20*time.Millisecond,
+		relay.WithTenantTokens(tokens))
+	local := usecase.NewSeverUsecase(mem.NewStorage(), usecase.WithUpdateObservers(r))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	teamA := tenant.WithTenant(context.Background(), "teamA")
+	teamB := tenant.WithTenant(context.Background(), "teamB")
+	require.NoError(t, local.UpdateMetric(context.Background(), "gauge", "Alloc", "1"))
+	require.NoError(t, local.UpdateMetric(teamA, "gauge", "Alloc", "2"))
+	require.NoError(t, local.UpdateMetric(teamB, "gauge", "Other", "3"))
+	cancel()
+	<-done
+
+	// Каждый арендатор попадает в своё пространство upstream по своему токену
+	value, err := upUC.GetMetric(context.Background(), "gauge", "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+	value, err = upUC.GetMetric(teamA, "gauge", "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, "2", value)
+
+	// Арендатор без токена не пересылается и не смешивается с арендатором по умолчанию
+	_, err = upUC.GetMetric(context.Background(), "gauge", "Other")
+	assert.Error(t, err)
+	_, err = upUC.GetMetric(teamB, "gauge", "Other")
+	assert.Error(t, err)
+}
@@ -0,0 +1,174 @@
+package stream
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+// DefaultMaxPending — сколько разных метрик может ждать отправки медленному подписчику
+const DefaultMaxPending = 1024
+
+// Filter отбирает обновления для подписчика
+type Filter struct {
+	Tenant  string
+	Pattern *regexp.Regexp  // шаблон ID из models.MetricsFilter.MatchRegexp, nil — все метрики
+	Types   map[string]bool // пустой — все типы
+}
+
+func (f Filter) match(name string, metric models.Metrics) bool {
+	if name != f.Tenant {
+		return false
+	}
+	if len(f.Types) > 0 && !f.Types[metric.MType] {
+		return false
+	}
+	if f.Pattern != nil && !f.Pattern.MatchString(metric.ID) {
+		return false
+	}
+	return true
+}
+
+// Hub раздаёт принятые обновления метрик подписчикам.
+// Публикация никогда не блокируется: обновления одной метрики для медленного
+// подписчика схлопываются, а новые метрики сверх лимита отбрасываются.
+type Hub struct {
+	maxPending int
+
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+func NewHub(maxPending int) *Hub {
+	if maxPending <= 0 {
+		maxPending = DefaultMaxPending
+	}
+	return &Hub{
+		maxPending: maxPending,
+		subs:       make(map[*Subscription]struct{}),
+	}
+}
+
+// OnUpdate реализует usecase.UpdateObserver
+func (h *Hub) OnUpdate(ctx context.Context, metrics []models.Metrics) {
+	name := tenant.FromContext(ctx)
+	now := time.Now()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subs {
+		for _, metric := range metrics {
+			if sub.filter.match(name, metric) {
+				metric.UpdatedAt = &now
+				sub.push(metric)
+			}
+		}
+	}
+}
+
+// Subscribe регистрирует подписчика; после использования нужно вызвать Unsubscribe
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	sub := &Subscription{
+		filter:     filter,
+		maxPending: h.maxPending,
+		pending:    make(map[string]models.Metrics),
+		ready:      make(chan struct{}, 1),
+	}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	delete(h.subs, sub)
+	h.mu.Unlock()
+}
+
+// Subscription — очередь обновлений одного подписчика
+type Subscription struct {
+	filter     Filter
+	maxPending int
+	dropped    atomic.Uint64
+
+	mu      sync.Mutex
+	pending map[string]models.Metrics
+	order   []string
+	ready   chan struct{}
+}
+
+// Ready сигнализирует, что есть обновления для Drain
+func (s *Subscription) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Dropped — сколько обновлений отброшено из-за переполнения
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Drain забирает накопленные обновления в порядке поступления
+func (s *Subscription) Drain() []models.Metrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metrics := make([]models.Metrics, 0, len(s.order))
+	for _, id := range s.order {
+		metrics = append(metrics, s.pending[id])
+	}
+	s.pending = make(map[string]models.Metrics)
+	s.order = s.order[:0]
+	return metrics
+}
+
+func (s *Subscription) push(metric models.Metrics) {
+	s.mu.Lock()
+	if current, ok := s.pending[metric.ID]; ok {
+		s.pending[metric.ID] = coalesce(current, metric)
+	} else if len(s.order) < s.maxPending {
+		s.pending[metric.ID] = metric
+		s.order = append(s.order, metric.ID)
+	} else {
+		s.dropped.Add(1)
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// coalesce объединяет два ещё не отправленных обновления одной метрики
+func coalesce(current, next models.Metrics) models.Metrics {
+	if current.MType != next.MType {
+		return next
+	}
+	switch next.MType {
+	case models.Counter:
+		if current.Delta != nil && next.Delta != nil {
+			sum := *current.Delta + *next.Delta
+			next.Delta = &sum
+		}
+	case models.Histogram:
+		if current.Histogram != nil && next.Histogram != nil {
+			// Сырые наблюдения current сначала раскладываются по корзинам:
+			// MergeHistograms считает гистограмму без Counts пустой
+			base, err := models.MergeHistograms(nil, *current.Histogram)
+			if err == nil {
+				if merged, err := models.MergeHistograms(base, *next.Histogram); err == nil {
+					next.Histogram = merged
+				}
+			}
+		}
+	}
+	return next
+}
@@ -0,0 +1,86 @@
+package stream_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/lib/tenant"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/internal/repo/storage/mem"
+	"github.com/zetcan333/metrics-collector/internal/usecase"
+	"github.com/zetcan333/metrics-collector/internal/usecase/stream"
+)
+
+func TestHubFilterAndCoalesce(t *testing.T) {
+	hub := stream.NewHub(2)
+	uc := usecase.NewSeverUsecase(mem.NewStorage(), usecase.WithUpdateObservers(hub))
+	ctx := context.Background()
+
+	sub := hub.Subscribe(stream.Filter{
+		Tenant:  tenant.Default,
+		Pattern: regexp.MustCompile(`^Poll`),
+		Types:   map[string]bool{models.Counter: true},
+	})
+	defer hub.Unsubscribe(sub)
+
+	require.NoError(t, uc.UpdateMetric(ctx, "counter", "PollCount", "2"))
+	require.NoError(t, uc.UpdateMetric(ctx, "counter", "PollCount", "3"))
+	require.NoError(t, uc.UpdateMetric(ctx, "gauge", "PollGauge", "1"))
+	require.NoError(t, uc.UpdateMetric(ctx, "counter", "Other", "1"))
+	require.NoError(t, uc.UpdateMetric(tenant.WithTenant(ctx, "teamA"), "counter", "PollCount", "1"))
+
+	<-sub.Ready()
+	updates := sub.Drain()
+	require.Len(t, updates, 1)
+	assert.Equal(t, "PollCount", updates[0].ID)
+	assert.Equal(t, int64(5), *updates[0].Delta)
+	assert.NotNil(t, updates[0].UpdatedAt)
+
+	// Лимит ожидающих метрик: лишние отбрасываются, публикация не блокируется
+	require.NoError(t, uc.UpdateMetricsWithBatch(ctx, []models.Metrics{
+		{ID: "PollA", MType: models.Counter, Delta: int64Ptr(1)},
+		{ID: "PollB", MType: models.Counter, Delta: int64Ptr(1)},
+		{ID: "PollC", MType: models.Counter, Delta: int64Ptr(1)},
+	}))
+	updates = sub.Drain()
+	assert.Len(t, updates, 2)
+	assert.Equal(t, uint64(1), sub.Dropped())
+}
+
+func TestHubSkipsFailedUpdates(t *testing.T) {
+	hub := stream.NewHub(0)
+	uc := usecase.NewSeverUsecase(mem.NewStorage(), usecase.WithUpdateObservers(hub))
+
+	sub := hub.Subscribe(stream.Filter{Tenant: tenant.Default})
+	defer hub.Unsubscribe(sub)
+
+	assert.Error(t, uc.UpdateMetric(context.Background(), "gauge", "Alloc", "abc"))
+	assert.Empty(t, sub.Drain())
+}
+
+func TestHubCoalescesRawHistogramObservations(t *testing.T) {
+	hub := stream.NewHub(0)
+	uc := usecase.NewSeverUsecase(mem.NewStorage(), usecase.WithUpdateObservers(hub))
+	ctx := context.Background()
+
+	sub := hub.Subscribe(stream.Filter{Tenant: tenant.Default})
+	defer hub.Unsubscribe(sub)
+
+	// Два необработанных обновления одной гистограммы ждут медленного подписчика
+	require.NoError(t, uc.UpdateMetric(ctx, models.Histogram, "Latency", "0.5"))
+	require.NoError(t, uc.UpdateMetric(ctx, models.Histogram, "Latency", "3"))
+
+	updates := sub.Drain()
+	require.Len(t, updates, 1)
+	h := updates[0].Histogram
+	require.NotNil(t, h)
+	assert.Equal(t, uint64(2), h.Count, "Наблюдения первого обновления не должны теряться")
+	assert.Equal(t, 3.5, h.Sum)
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
@@ -0,0 +1,116 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/pkg/myerrors"
+)
+
+// ChunkSize — размер страницы экспорта и пачки записи при импорте в режиме merge
+const ChunkSize = 500
+
+type Mode string
+
+const (
+	// ModeMerge применяет импорт как обычные обновления: gauge перезаписываются,
+	// counter и histogram прибавляются, остальные метрики не трогаются
+	ModeMerge Mode = "merge"
+	// ModeReplace атомарно заменяет все метрики арендатора импортом, результат совпадает с экспортом
+	ModeReplace Mode = "replace"
+)
+
+// ErrEmptyReplace — импорт в режиме replace без метрик: он удалил бы все метрики арендатора
+var ErrEmptyReplace = errors.New("replace import contains no metrics")
+
+// MetricsStore — операции SeverUsecase, через которые идут экспорт и импорт
+type MetricsStore interface {
+	ListMetrics(ctx context.Context, filter models.MetricsFilter) ([]models.Metrics, string, error)
+	UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error
+	ReplaceMetrics(ctx context.Context, metrics []models.Metrics) error
+}
+
+type TransferUsecase struct {
+	store MetricsStore
+}
+
+func NewTransferUsecase(store MetricsStore) *TransferUsecase {
+	return &TransferUsecase{store: store}
+}
+
+// Export постранично обходит все метрики арендатора и передаёт их в fn
+func (t *TransferUsecase) Export(ctx context.Context, fn func(models.Metrics) error) error {
+	filter := models.MetricsFilter{Limit: ChunkSize}
+	for {
+		page, next, err := t.store.ListMetrics(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("failed to export metrics: %w", err)
+		}
+		for _, metric := range page {
+			if err := fn(metric); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		filter.Cursor = next
+	}
+}
+
+// Import записывает метрики и возвращает число записанных. В режиме merge запись идёт пачками
+// по ChunkSize, в режиме replace — одной операцией, чтобы сбой не оставил арендатора без метрик.
+// Вычисляемые сервером поля (время обновления, квантили) игнорируются.
+func (t *TransferUsecase) Import(ctx context.Context, metrics []models.Metrics, mode Mode) (int, error) {
+	// Проверяем всё до записи, чтобы некорректный файл не был применён частично
+	for i := range metrics {
+		if err := check(metrics[i]); err != nil {
+			return 0, fmt.Errorf("metric %d (%s): %w", i, metrics[i].ID, err)
+		}
+		metrics[i].UpdatedAt = nil
+		if metrics[i].Histogram != nil {
+			metrics[i].Histogram.Quantiles = nil
+		}
+	}
+
+	if mode == ModeReplace {
+		if len(metrics) == 0 {
+			return 0, ErrEmptyReplace
+		}
+		if err := t.store.ReplaceMetrics(ctx, metrics); err != nil {
+			return 0, fmt.Errorf("failed to replace metrics: %w", err)
+		}
+		return len(metrics), nil
+	}
+
+	written := 0
+	for start := 0; start < len(metrics); start += ChunkSize {
+		end := min(start+ChunkSize, len(metrics))
+		if err := t.store.UpdateMetricsWithBatch(ctx, metrics[start:end]); err != nil {
+			return written, fmt.Errorf("failed to import metrics %d-%d: %w", start, end-1, err)
+		}
+		written = end
+	}
+	return written, nil
+}
+
+func check(metric models.Metrics) error {
+	switch {
+	case metric.ID == "":
+		return fmt.Errorf("%w: empty id", myerrors.ErrInvalidMetricType)
+	case metric.MType == models.Gauge && metric.Value == nil:
+		return myerrors.ErrInvalidGaugeValue
+	case metric.MType == models.Counter && metric.Delta == nil:
+		return myerrors.ErrInvalidCounterValue
+	case metric.MType == models.Histogram:
+		if metric.Histogram == nil {
+			return myerrors.ErrInvalidHistogramValue
+		}
+		return metric.Histogram.Validate()
+	case metric.MType != models.Gauge && metric.MType != models.Counter:
+		return myerrors.ErrInvalidMetricType
+	}
+	return nil
+}
@@ -2,10 +2,9 @@ package usecase
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"html/template"
 	"strconv"
-	"strings"
 
 	"github.com/zetcan333/metrics-collector/internal/lib/format/float"
 	"github.com/zetcan333/metrics-collector/internal/models"
@@ -15,29 +14,58 @@ import (
 type ServerRepository interface {
 	UpdateMetric(ctx context.Context, metric models.Metrics) error
 	GetMetric(ctx context.Context, id string) (models.Metrics, error)
-	GetAllGauges(ctx context.Context) (map[string]float64, error)
-	GetAllCounters(ctx context.Context) (map[string]int64, error)
+	ListMetrics(ctx context.Context, filter models.MetricsFilter) ([]models.Metrics, error)
 	UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error
+	DeleteMetric(ctx context.Context, id, metricType string) error
+	DeleteMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error
+	ReplaceMetrics(ctx context.Context, metrics []models.Metrics) error
+}
+
+// UpdateObserver получает метрики, успешно записанные в хранилище.
+// OnUpdate вызывается синхронно на пути записи и не должен блокироваться.
+type UpdateObserver interface {
+	OnUpdate(ctx context.Context, metrics []models.Metrics)
 }
 
 type SeverUsecase struct {
-	repo ServerRepository
+	repo             ServerRepository
+	histogramBuckets []float64
+	observers        []UpdateObserver
+}
+
+// Option настраивает SeverUsecase
+type Option func(*SeverUsecase)
+
+// WithHistogramBuckets задаёт границы корзин для новых гистограмм без явных bounds
+func WithHistogramBuckets(bounds []float64) Option {
+	return func(s *SeverUsecase) {
+		s.histogramBuckets = bounds
+	}
 }
 
-func NewSeverUsecase(repo ServerRepository) *SeverUsecase {
-	return &SeverUsecase{repo: repo}
+// WithUpdateObservers подписывает наблюдателей на принятые обновления метрик
+func WithUpdateObservers(observers ...UpdateObserver) Option {
+	return func(s *SeverUsecase) {
+		s.observers = append(s.observers, observers...)
+	}
 }
 
-var ctx = context.Background()
+func NewSeverUsecase(repo ServerRepository, opts ...Option) *SeverUsecase {
+	s := &SeverUsecase{repo: repo, histogramBuckets: models.DefaultHistogramBounds}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
 
-func (s *SeverUsecase) UpdateMetric(metricType, metricName, metricValue string) error {
+func (s *SeverUsecase) UpdateMetric(ctx context.Context, metricType, metricName, metricValue string) error {
 	switch metricType {
 	case "gauge":
 		value, err := strconv.ParseFloat(metricValue, 64)
 		if err != nil {
 			return fmt.Errorf("%w: %v", myerrors.ErrInvalidGaugeValue, err)
 		}
-		s.repo.UpdateMetric(ctx, models.Metrics{
+		return s.update(ctx, models.Metrics{
 			MType: "gauge",
 			ID:    metricName,
 			Value: &value,
@@ -48,23 +76,45 @@ func (s *SeverUsecase) UpdateMetric(metricType, metricName, metricValue string)
 		if err != nil {
 			return fmt.Errorf("%w: %v", myerrors.ErrInvalidCounterValue, err)
 		}
-		s.repo.UpdateMetric(ctx, models.Metrics{
+		return s.update(ctx, models.Metrics{
 			MType: "counter",
 			ID:    metricName,
 			Delta: &value,
 		})
 
+	case models.Histogram:
+		// В URL передаётся одно наблюдение
+		value, err := strconv.ParseFloat(metricValue, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %v", myerrors.ErrInvalidHistogramValue, err)
+		}
+		metric := models.Metrics{
+			MType:     models.Histogram,
+			ID:        metricName,
+			Histogram: &models.HistogramData{Observations: []float64{value}},
+		}
+		if err := s.prepareHistogram(&metric); err != nil {
+			return err
+		}
+		return s.update(ctx, metric)
+
 	default:
 		return myerrors.ErrInvalidMetricType
 	}
-	return nil
 }
 
-func (s *SeverUsecase) GetMetric(metricType, metricName string) (string, error) {
+func (s *SeverUsecase) GetMetric(ctx context.Context, metricType, metricName string) (string, error) {
+	if !isValidType(metricType) {
+		return "", myerrors.ErrInvalidMetricType
+	}
+
 	metric, err := s.repo.GetMetric(ctx, metricName)
 	if err != nil {
 		return "", err
 	}
+	if metric.MType != metricType {
+		return "", myerrors.ErrMetricNotFound
+	}
 
 	switch metricType {
 	case "gauge":
@@ -74,108 +124,185 @@ func (s *SeverUsecase) GetMetric(metricType, metricName string) (string, error)
 		return fmt.Sprintf("%d", *metric.Delta), nil
 
 	default:
-		return "", myerrors.ErrInvalidMetricType
+		body, err := json.Marshal(withQuantiles(metric).Histogram)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode histogram: %w", err)
+		}
+		return string(body), nil
 	}
 }
 
-func (s *SeverUsecase) UpdateViaModel(metric models.Metrics) (models.Metrics, error) {
-	if metric.MType != "gauge" && metric.MType != "counter" {
+func (s *SeverUsecase) UpdateViaModel(ctx context.Context, metric models.Metrics) (models.Metrics, error) {
+	if !isValidType(metric.MType) {
 		return models.Metrics{}, myerrors.ErrInvalidMetricType
 	}
-	switch metric.MType {
+	if err := s.validate(&metric); err != nil {
+		return models.Metrics{}, err
+	}
 
-	case "gauge":
-		if metric.Value == nil {
-			return models.Metrics{}, myerrors.ErrInvalidGaugeValue
-		}
-		s.repo.UpdateMetric(ctx, metric)
+	if err := s.update(ctx, metric); err != nil {
+		return models.Metrics{}, err
+	}
 
-		updatedMetric, err := s.repo.GetMetric(ctx, metric.ID)
-		if err != nil {
-			return models.Metrics{}, err
-		}
-		return updatedMetric, nil
+	updatedMetric, err := s.repo.GetMetric(ctx, metric.ID)
+	if err != nil {
+		return models.Metrics{}, err
+	}
+	return withQuantiles(updatedMetric), nil
+}
 
-	case "counter":
-		if metric.Delta == nil {
-			return models.Metrics{}, myerrors.ErrInvalidCounterValue
-		}
+func (s *SeverUsecase) GetViaModel(ctx context.Context, metric models.Metrics) (models.Metrics, error) {
 
-		s.repo.UpdateMetric(ctx, metric)
+	if !isValidType(metric.MType) {
+		return models.Metrics{}, myerrors.ErrInvalidMetricType
+	}
 
-		updatedMetric, err := s.repo.GetMetric(ctx, metric.ID)
-		if err != nil {
-			return models.Metrics{}, err
+	storedMetric, err := s.repo.GetMetric(ctx, metric.ID)
+	if err != nil {
+		return models.Metrics{}, err
+	}
+
+	return withQuantiles(storedMetric), nil
+}
+
+func (s *SeverUsecase) update(ctx context.Context, metric models.Metrics) error {
+	if err := s.repo.UpdateMetric(ctx, metric); err != nil {
+		return err
+	}
+	s.notify(ctx, []models.Metrics{metric})
+	return nil
+}
+
+// notify передаёт наблюдателям принятые значения (дельты, а не итоговые суммы)
+func (s *SeverUsecase) notify(ctx context.Context, metrics []models.Metrics) {
+	for _, observer := range s.observers {
+		observer.OnUpdate(ctx, metrics)
+	}
+}
+
+func isValidType(metricType string) bool {
+	return metricType == models.Gauge || metricType == models.Counter || metricType == models.Histogram
+}
+
+// validate проверяет значение метрики в зависимости от её типа
+func (s *SeverUsecase) validate(metric *models.Metrics) error {
+	switch metric.MType {
+	case models.Gauge:
+		if metric.Value == nil {
+			return myerrors.ErrInvalidGaugeValue
 		}
-		return updatedMetric, nil
+	case models.Counter:
+		if metric.Delta == nil {
+			return myerrors.ErrInvalidCounterValue
+		}
+	case models.Histogram:
+		return s.prepareHistogram(metric)
+	default:
+		return myerrors.ErrInvalidMetricType
 	}
+	return nil
+}
 
-	return models.Metrics{}, myerrors.ErrInvalidMetricType
+// prepareHistogram проверяет обновление гистограммы и подставляет корзины по умолчанию
+func (s *SeverUsecase) prepareHistogram(metric *models.Metrics) error {
+	if metric.Histogram == nil {
+		return myerrors.ErrInvalidHistogramValue
+	}
+	if err := metric.Histogram.Validate(); err != nil {
+		return err
+	}
+	if len(metric.Histogram.Bounds) == 0 && len(metric.Histogram.Counts) == 0 {
+		h := *metric.Histogram
+		h.Bounds = s.histogramBuckets
+		metric.Histogram = &h
+	}
+	return nil
 }
 
-func (s *SeverUsecase) GetViaModel(metric models.Metrics) (models.Metrics, error) {
+// withQuantiles дополняет копию гистограммы квантилями, не трогая данные хранилища
+func withQuantiles(metric models.Metrics) models.Metrics {
+	if metric.Histogram != nil {
+		metric.Histogram = metric.Histogram.WithQuantiles()
+	}
+	return metric
+}
 
-	if metric.MType != "gauge" && metric.MType != "counter" {
-		return models.Metrics{}, myerrors.ErrInvalidMetricType
+// ListMetrics возвращает страницу метрик арендатора, упорядоченных по имени,
+// и курсор следующей страницы (пустой, если страница последняя)
+func (s *SeverUsecase) ListMetrics(ctx context.Context, filter models.MetricsFilter) ([]models.Metrics, string, error) {
+	if filter.Type != "" && !isValidType(filter.Type) {
+		return nil, "", myerrors.ErrInvalidMetricType
+	}
+	if filter.Limit < 0 {
+		return nil, "", fmt.Errorf("%w: negative limit", myerrors.ErrInvalidFilter)
+	}
+	if _, err := filter.MatchRegexp(); err != nil {
+		return nil, "", err
 	}
 
-	storedMetric, err := s.repo.GetMetric(ctx, metric.ID)
+	// Запрашиваем на одну метрику больше, чтобы понять, есть ли следующая страница
+	limit := filter.Limit
+	if limit > 0 {
+		filter.Limit++
+	}
+	metrics, err := s.repo.ListMetrics(ctx, filter)
 	if err != nil {
-		return models.Metrics{}, err
+		return nil, "", fmt.Errorf("failed to list metrics: %w", err)
 	}
 
-	return storedMetric, nil
+	var next string
+	if limit > 0 && len(metrics) > limit {
+		metrics = metrics[:limit]
+		next = metrics[limit-1].ID
+	}
+	for i := range metrics {
+		metrics[i] = withQuantiles(metrics[i])
+	}
+	return metrics, next, nil
 }
 
-func (s *SeverUsecase) GetAllMetrics() (string, error) {
-	gauges, err := s.repo.GetAllGauges(ctx)
-	if err != nil {
-		return "", fmt.Errorf("failed to get all gauges: %w", err)
+func (s *SeverUsecase) UpdateMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error {
+	for i := range metrics {
+		if err := s.validate(&metrics[i]); err != nil {
+			return err
+		}
 	}
-	counters, err := s.repo.GetAllCounters(ctx)
-	if err != nil {
-		return "", fmt.Errorf("failed to get all counters: %w", err)
-	}
-	tmpl := `<html>
-		<head><title>Metrics</title></head>
-		<body>
-			<h1>Metrics</h1>
-			<ul>
-			{{ range $key, $value := .Gauges }}
-				<li>Gauge {{$key}}: {{ printf "%.2f" $value }}</li>
-			{{ end }}
-			{{ range $key, $value := .Counters }}
-				<li>Counter {{$key}}: {{$value}}</li>
-			{{ end }}
-			</ul>
-		</body>
-		</html>`
-	data := struct {
-		Gauges   map[string]float64
-		Counters map[string]int64
-	}{
-		Gauges:   gauges,
-		Counters: counters,
-	}
-	t, err := template.New("metrics").Parse(tmpl)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %w", err)
+	if err := s.repo.UpdateMetricsWithBatch(ctx, metrics); err != nil {
+		return err
 	}
+	s.notify(ctx, metrics)
+	return nil
+}
 
-	var buf strings.Builder
-	if err := t.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("failed to execute template: %w", err)
+// ReplaceMetrics заменяет все метрики арендатора на metrics одной операцией хранилища:
+// при ошибке прежние метрики остаются нетронутыми.
+// Наблюдатели не уведомляются: metrics — итоговые значения, а не приращения,
+// и relay или /stream прибавили бы счётчики повторно.
+func (s *SeverUsecase) ReplaceMetrics(ctx context.Context, metrics []models.Metrics) error {
+	for i := range metrics {
+		if err := s.validate(&metrics[i]); err != nil {
+			return err
+		}
 	}
+	return s.repo.ReplaceMetrics(ctx, metrics)
+}
 
-	return buf.String(), nil
+// DeleteMetric удаляет метрику, если её тип совпадает с запрошенным. Тип проверяется
+// хранилищем в той же операции, что и удаление: метрику, сменившую тип, удалить нельзя.
+func (s *SeverUsecase) DeleteMetric(ctx context.Context, metricType, metricName string) error {
+	if !isValidType(metricType) {
+		return myerrors.ErrInvalidMetricType
+	}
+	return s.repo.DeleteMetric(ctx, metricName, metricType)
 }
 
-func (s *SeverUsecase) UpdateMetricsWithBatch(metrics []models.Metrics) error {
+// DeleteMetricsWithBatch удаляет метрики по паре (id, type); метрики другого типа
+// с тем же ID, как и в DeleteMetric, не затрагиваются
+func (s *SeverUsecase) DeleteMetricsWithBatch(ctx context.Context, metrics []models.Metrics) error {
 	for _, metric := range metrics {
-		if metric.MType != "gauge" && metric.MType != "counter" {
+		if !isValidType(metric.MType) {
 			return myerrors.ErrInvalidMetricType
 		}
-
 	}
-	return s.repo.UpdateMetricsWithBatch(ctx, metrics)
+	return s.repo.DeleteMetricsWithBatch(ctx, metrics)
 }
@@ -0,0 +1,68 @@
+package usecase_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/internal/repo/storage/mem"
+	"github.com/zetcan333/metrics-collector/internal/usecase"
+)
+
+// Чтение гистограммы не должно менять данные хранилища: тест имеет смысл под -race
+func TestGetHistogramConcurrentWithUpdate(t *testing.T) {
+	ctx := context.Background()
+	storage := mem.NewStorage()
+	uc := usecase.NewSeverUsecase(storage)
+	require.NoError(t, uc.UpdateMetric(ctx, models.Histogram, "Latency", "0.2"))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			assert.NoError(t, uc.UpdateMetric(ctx, models.Histogram, "Latency", "0.3"))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			body, err := uc.GetMetric(ctx, models.Histogram, "Latency")
+			if assert.NoError(t, err) {
+				var h models.HistogramData
+				assert.NoError(t, json.Unmarshal([]byte(body), &h))
+				assert.Contains(t, h.Quantiles, "0.5")
+			}
+		}
+	}()
+	wg.Wait()
+
+	stored, err := storage.GetMetric(ctx, "Latency")
+	require.NoError(t, err)
+	assert.Nil(t, stored.Histogram.Quantiles, "quantiles must not be written to storage")
+}
+
+type recordingObserver struct {
+	updates [][]models.Metrics
+}
+
+func (o *recordingObserver) OnUpdate(_ context.Context, metrics []models.Metrics) {
+	o.updates = append(o.updates, metrics)
+}
+
+func TestReplaceMetricsDoesNotNotify(t *testing.T) {
+	ctx := context.Background()
+	observer := &recordingObserver{}
+	uc := usecase.NewSeverUsecase(mem.NewStorage(), usecase.WithUpdateObservers(observer))
+
+	require.NoError(t, uc.UpdateMetric(ctx, models.Counter, "Polls", "5"))
+	require.Len(t, observer.updates, 1)
+
+	total := int64(100)
+	require.NoError(t, uc.ReplaceMetrics(ctx, []models.Metrics{{ID: "Polls", MType: models.Counter, Delta: &total}}))
+	assert.Len(t, observer.updates, 1, "replace must not send absolute totals as increments")
+}
@@ -0,0 +1,301 @@
+// Package client отправляет метрики приложения на сервер метрик.
+//
+//	c := client.New("http://localhost:8080", client.WithKey(key))
+//	defer c.Close()
+//
+//	c.Gauge("queue_size").Set(42)
+//	c.Counter("orders_total").Add(1)
+//
+// Значения копятся в памяти и отправляются пачками на /updates/ в фоне.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zetcan333/metrics-collector/internal/lib/retry"
+	"github.com/zetcan333/metrics-collector/internal/models"
+)
+
+const (
+	DefaultFlushInterval = 10 * time.Second
+	DefaultBatchSize     = 500
+)
+
+// DefaultRetryDelays — паузы между повторами отправки, как у агента
+var DefaultRetryDelays = retry.Delays
+
+var (
+	ErrClosed = errors.New("client is closed")
+	// ErrNotFinite передаётся в обработчик ошибок, когда gauge получает NaN или ±Inf: такое значение не отправляется
+	ErrNotFinite = errors.New("gauge value is not finite")
+)
+
+type Option func(*Client)
+
+// WithKey включает подпись тела запроса HMAC-SHA256 в заголовке HashSHA256
+func WithKey(key string) Option {
+	return func(c *Client) { c.key = key }
+}
+
+// WithTenant задаёт арендатора заголовком X-Tenant-ID
+func WithTenant(tenant string) Option {
+	return func(c *Client) { c.tenant = tenant }
+}
+
+// WithToken задаёт токен арендатора для заголовка Authorization: Bearer
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+func WithFlushInterval(d time.Duration) Option {
+	return func(c *Client) { c.flushInterval = d }
+}
+
+// WithBatchSize ограничивает число метрик в одном запросе; при накоплении пачки отправка начинается раньше интервала
+func WithBatchSize(n int) Option {
+	return func(c *Client) { c.batchSize = n }
+}
+
+// WithRetryDelays задаёт паузы между повторами; число повторов равно числу пауз
+func WithRetryDelays(delays ...time.Duration) Option {
+	return func(c *Client) { c.retryDelays = append([]time.Duration{}, delays...) }
+}
+
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) { c.http = h }
+}
+
+// WithErrorHandler получает ошибки фоновой отправки; по умолчанию они отбрасываются
+func WithErrorHandler(fn func(error)) Option {
+	return func(c *Client) { c.onError = fn }
+}
+
+// Client копит значения метрик и отправляет их пачками. Безопасен для конкурентного использования.
+type Client struct {
+	updatesURL    string
+	key           string
+	tenant        string
+	token         string
+	flushInterval time.Duration
+	batchSize     int
+	retryDelays   []time.Duration
+	http          *http.Client
+	onError       func(error)
+	poster        *retry.Poster
+	header        http.Header
+
+	mu       sync.Mutex
+	gauges   map[string]float64
+	counters map[string]int64
+	closed   bool
+
+	sendMu sync.Mutex // отправки идут по одной, чтобы не переупорядочивать значения gauge
+	full   chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// New создаёт клиент и запускает фоновую отправку; Close останавливает её
+func New(serverURL string, opts ...Option) *Client {
+	c := &Client{
+		updatesURL:    strings.TrimRight(serverURL, "/") + "/updates/",
+		flushInterval: DefaultFlushInterval,
+		batchSize:     DefaultBatchSize,
+		retryDelays:   DefaultRetryDelays,
+		http:          &http.Client{Timeout: 15 * time.Second},
+		onError:       func(error) {},
+		gauges:        make(map[string]float64),
+		counters:      make(map[string]int64),
+		full:          make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.poster = &retry.Poster{Client: c.http, Key: c.key, Gzip: true, Delays: c.retryDelays}
+	c.header = http.Header{}
+	if c.tenant != "" {
+		c.header.Set("X-Tenant-ID", c.tenant)
+	}
+	if c.token != "" {
+		c.header.Set("Authorization", "Bearer "+c.token)
+	}
+	go c.run()
+	return c
+}
+
+// Gauge — метрика с последним установленным значением
+type Gauge struct {
+	c  *Client
+	id string
+}
+
+// Gauge возвращает gauge с именем name; labels — пары ключ, значение
+func (c *Client) Gauge(name string, labels ...string) Gauge {
+	return Gauge{c: c, id: metricID(name, labels)}
+}
+
+// Set запоминает значение; NaN и ±Inf не отправляются и передаются в обработчик ошибок как ErrNotFinite
+func (g Gauge) Set(value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		g.c.onError(fmt.Errorf("%w: %s = %v", ErrNotFinite, g.id, value))
+		return
+	}
+	g.c.mu.Lock()
+	if !g.c.closed {
+		g.c.gauges[g.id] = value
+	}
+	g.c.mu.Unlock()
+	g.c.checkFull()
+}
+
+// Counter — счётчик, приращения которого суммируются до отправки
+type Counter struct {
+	c  *Client
+	id string
+}
+
+// Counter возвращает счётчик с именем name; labels — пары ключ, значение
+func (c *Client) Counter(name string, labels ...string) Counter {
+	return Counter{c: c, id: metricID(name, labels)}
+}
+
+func (k Counter) Add(delta int64) {
+	k.c.mu.Lock()
+	if !k.c.closed {
+		k.c.counters[k.id] += delta
+	}
+	k.c.mu.Unlock()
+	k.c.checkFull()
+}
+
+func (k Counter) Inc() {
+	k.Add(1)
+}
+
+func metricID(name string, labels []string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	m := make(map[string]string, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		m[labels[i]] = labels[i+1]
+	}
+	return models.FormatID(name, m)
+}
+
+// Flush отправляет накопленные значения. Пачки, не доставленные из-за сетевых ошибок
+// или ответов 5xx, возвращаются в буфер до следующей отправки; отвергнутые сервером отбрасываются.
+func (c *Client) Flush(ctx context.Context) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	metrics := c.take()
+	var errs []error
+	for start := 0; start < len(metrics); start += c.batchSize {
+		batch := metrics[start:min(start+c.batchSize, len(metrics))]
+		if retriable, err := c.send(ctx, batch); err != nil {
+			if retriable {
+				c.restore(batch)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close останавливает фоновую отправку и отправляет оставшиеся значения
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	close(c.stop)
+	<-c.done
+	return c.Flush(context.Background())
+}
+
+func (c *Client) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.full:
+		case <-c.stop:
+			return
+		}
+		if err := c.Flush(context.Background()); err != nil {
+			c.onError(err)
+		}
+	}
+}
+
+func (c *Client) checkFull() {
+	c.mu.Lock()
+	full := len(c.gauges)+len(c.counters) >= c.batchSize
+	c.mu.Unlock()
+	if full {
+		select {
+		case c.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c *Client) take() []models.Metrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	metrics := make([]models.Metrics, 0, len(c.gauges)+len(c.counters))
+	for id, value := range c.gauges {
+		metrics = append(metrics, models.Metrics{ID: id, MType: models.Gauge, Value: &value})
+	}
+	for id, delta := range c.counters {
+		metrics = append(metrics, models.Metrics{ID: id, MType: models.Counter, Delta: &delta})
+	}
+	c.gauges = make(map[string]float64)
+	c.counters = make(map[string]int64)
+	return metrics
+}
+
+// restore возвращает неотправленное: приращения складываются с новыми, gauge не затирает более свежее значение
+func (c *Client) restore(metrics []models.Metrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, m := range metrics {
+		switch m.MType {
+		case models.Gauge:
+			if _, ok := c.gauges[m.ID]; !ok {
+				c.gauges[m.ID] = *m.Value
+			}
+		case models.Counter:
+			c.counters[m.ID] += *m.Delta
+		}
+	}
+}
+
+// send отправляет пачку с повторами; retriable сообщает, имеет ли смысл отправить её позже
+func (c *Client) send(ctx context.Context, metrics []models.Metrics) (bool, error) {
+	body, err := json.Marshal(metrics)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode metrics: %w", err)
+	}
+	return c.poster.Post(ctx, c.updatesURL, body, c.header)
+}
@@ -0,0 +1,149 @@
+package client_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zetcan333/metrics-collector/internal/lib/sign"
+	"github.com/zetcan333/metrics-collector/internal/models"
+	"github.com/zetcan333/metrics-collector/pkg/client"
+)
+
+type upstream struct {
+	mu       sync.Mutex
+	fail     int // сколько первых запросов отвечать 503
+	requests int
+	gauges   map[string]float64
+	counters map[string]int64
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.requests++
+	if u.requests <= u.fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	gz, err := gzip.NewReader(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	body, _ := io.ReadAll(gz)
+	if r.URL.Path != "/updates/" || r.Header.Get(sign.Header) != sign.Sum(body, "secret") || r.Header.Get("X-Tenant-ID") != "teamA" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var metrics []models.Metrics
+	if err := json.Unmarshal(body, &metrics); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, m := range metrics {
+		switch m.MType {
+		case models.Gauge:
+			u.gauges[m.ID] = *m.Value
+		case models.Counter:
+			u.counters[m.ID] += *m.Delta
+		}
+	}
+}
+
+func TestClientFlushesOnClose(t *testing.T) {
+	u := &upstream{fail: 1, gauges: map[string]float64{}, counters: map[string]int64{}}
+	srv := httptest.NewServer(u)
+	defer srv.Close()
+
+	errs := make(chan error, 10)
+	c := client.New(srv.URL,
+		client.WithKey("secret"),
+		client.WithTenant("teamA"),
+		client.WithFlushInterval(time.Hour),
+		client.WithRetryDelays(time.Millisecond),
+		client.WithErrorHandler(func(err error) { errs <- err }),
+	)
+
+	c.Gauge("queue_size").Set(1)
+	c.Gauge("queue_size").Set(42)
+	orders := c.Counter("orders_total", "shop", "eu")
+	orders.Add(2)
+	orders.Inc()
+
+	require.NoError(t, c.Close())
+	assert.ErrorIs(t, c.Close(), client.ErrClosed)
+	assert.Empty(t, errs)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	assert.Equal(t, 2, u.requests, "Первая попытка получила 503 и была повторена")
+	assert.Equal(t, map[string]float64{"queue_size": 42}, u.gauges)
+	assert.Equal(t, map[string]int64{`orders_total{shop="eu"}`: 3}, u.counters)
+}
+
+func TestClientBackgroundFlushKeepsUnsent(t *testing.T) {
+	u := &upstream{fail: 1, gauges: map[string]float64{}, counters: map[string]int64{}}
+	srv := httptest.NewServer(u)
+	defer srv.Close()
+
+	errs := make(chan error, 10)
+	c := client.New(srv.URL,
+		client.WithKey("secret"),
+		client.WithTenant("teamA"),
+		client.WithBatchSize(2),
+		client.WithRetryDelays(),
+		client.WithErrorHandler(func(err error) { errs <- err }),
+	)
+	defer c.Close()
+
+	// Пачка набрана — фоновая отправка начинается без ожидания интервала
+	c.Counter("jobs").Add(5)
+	c.Gauge("temp").Set(20)
+	require.Error(t, <-errs)
+
+	c.Counter("jobs").Add(1)
+	require.Eventually(t, func() bool {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		return u.counters["jobs"] == 6 && u.gauges["temp"] == 20
+	}, time.Second, 10*time.Millisecond, "Неотправленные приращения сохраняются до следующей отправки")
+}
+
+func TestGaugeRejectsNonFinite(t *testing.T) {
+	u := &upstream{gauges: map[string]float64{}, counters: map[string]int64{}}
+	srv := httptest.NewServer(u)
+	defer srv.Close()
+
+	errs := make(chan error, 10)
+	c := client.New(srv.URL,
+		client.WithKey("secret"),
+		client.WithTenant("teamA"),
+		client.WithFlushInterval(time.Hour),
+		client.WithErrorHandler(func(err error) { errs <- err }),
+	)
+
+	c.Gauge("temp").Set(20)
+	c.Gauge("temp").Set(math.NaN())
+	c.Gauge("load").Set(math.Inf(1))
+	c.Counter("jobs").Inc()
+	require.Len(t, errs, 2)
+	assert.ErrorIs(t, <-errs, client.ErrNotFinite)
+
+	// Некорректное значение не мешает отправить остальные метрики пачки
+	require.NoError(t, c.Close())
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	assert.Equal(t, map[string]float64{"temp": 20}, u.gauges)
+	assert.Equal(t, map[string]int64{"jobs": 1}, u.counters)
+}
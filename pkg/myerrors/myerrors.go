@@ -3,8 +3,10 @@ package myerrors
 import "errors"
 
 var (
-	ErrInvalidMetricType   = errors.New("invalid metric type")
-	ErrInvalidGaugeValue   = errors.New("invalid gauge value")
-	ErrInvalidCounterValue = errors.New("invalid counter value")
-	ErrMetricNotFound      = errors.New("metric not found")
+	ErrInvalidMetricType     = errors.New("invalid metric type")
+	ErrInvalidGaugeValue     = errors.New("invalid gauge value")
+	ErrInvalidCounterValue   = errors.New("invalid counter value")
+	ErrInvalidHistogramValue = errors.New("invalid histogram value")
+	ErrMetricNotFound        = errors.New("metric not found")
+	ErrInvalidFilter         = errors.New("invalid metrics filter")
 )